@@ -0,0 +1,172 @@
+package app
+
+import (
+	"crypto/rand"
+	"encoding/base32"
+	"errors"
+	"fmt"
+	. "remind0/db"
+	r "remind0/repository"
+	"strconv"
+	"strings"
+	"time"
+
+	telegramClient "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"gorm.io/gorm"
+)
+
+/**
+ *   __ _  ___ ___ ___  ___ ___
+ *  / _` |/ __/ __/ _ \/ __/ __|
+ * | (_| | (_| (_|  __/\__ \__ \
+ *  \__,_|\___\___\___||___/___/
+ *
+ * Who gets to use the bot. Admins always do, blocked users never do and
+ * others depending on the access mode. The check happens before a user is
+ * created, so rejected senders leave nothing behind.
+ */
+
+type AccessMode string
+
+const (
+	AccessOpen      AccessMode = "open"      // Anyone can use the bot.
+	AccessAllowlist AccessMode = "allowlist" // Only admins and allowlisted users.
+	AccessInvite    AccessMode = "invite"    // Existing users, and new ones with an invite code.
+)
+
+func (mode AccessMode) valid() bool {
+	return mode == AccessOpen || mode == AccessAllowlist || mode == AccessInvite
+}
+
+type accessPolicy struct {
+	mode    AccessMode
+	admins  map[int64]bool
+	allowed map[int64]bool
+}
+
+var access = accessPolicy{mode: AccessOpen}
+
+/**
+ * Apply the access settings of the configuration.
+ */
+func ConfigureAccess(config *Config) {
+	access = accessPolicy{mode: config.Access.Mode, admins: map[int64]bool{}, allowed: map[int64]bool{}}
+	for _, id := range config.Access.AdminIDs {
+		access.admins[id] = true
+	}
+	for _, id := range config.Access.AllowedIDs {
+		access.allowed[id] = true
+	}
+}
+
+func isAdmin(tgUserID int64) bool {
+	return access.admins[tgUserID]
+}
+
+const (
+	inviteValidity    = 7 * 24 * time.Hour
+	maxInviteValidity = 90 * 24 * time.Hour
+)
+
+/**
+ * Decide whether a Telegram user may use the bot. New users joining an
+ * invite-only bot get the invite they're joining with, to be used up once
+ * they're created. Rejected users get the reason to reply with, in lang.
+ */
+func admit(repos *r.Repositories, sender *telegramClient.User, body string, lang Language) (invite *Invite, rejection string, err error) {
+	if isAdmin(sender.ID) {
+		return nil, "", nil
+	}
+
+	user, err := repos.UserRepo().GetByTelegramID(sender.ID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, "", err
+	}
+	known := err == nil
+
+	if known && user.Blocked {
+		return nil, lang.T("⛔ You have been blocked from using this bot."), nil
+	}
+
+	if access.mode == AccessOpen || access.allowed[sender.ID] {
+		return nil, "", nil
+	}
+
+	if access.mode == AccessAllowlist {
+		return nil, lang.Tf("⛔ This bot is private. Ask its admin to let your Telegram ID (%d) in.", sender.ID), nil
+	}
+
+	// Invite-only: whoever got in already stays in.
+	if known {
+		return nil, "", nil
+	}
+
+	code, ok := joinCode(body)
+	if !ok {
+		return nil, lang.T("⛔ This bot is invite-only. Send !join <code> with the invite code you were given."), nil
+	}
+
+	invite, err = repos.InviteRepo().GetUsable(code, time.Now())
+	if errors.Is(err, r.ErrInviteInvalid) {
+		return nil, lang.T("⛔ This invite code is unknown, already used or expired."), nil
+	}
+	if err != nil {
+		return nil, "", err
+	}
+	return invite, "", nil
+}
+
+/**
+ * Invite code of a join request: !join <code>, or /start <code> when
+ * following a t.me/<bot>?start=<code> link.
+ */
+func joinCode(body string) (string, bool) {
+	fields := strings.Fields(body)
+	if len(fields) != 2 || (fields[0] != "!join" && fields[0] != "/start") {
+		return "", false
+	}
+	return strings.ToUpper(fields[1]), true
+}
+
+/**
+ * Generate an invite code, for admins only.
+ * Format: <days?>
+ */
+func createInvite(args []string, ctx MessageContext) CommandResult {
+	if !ctx.Admin {
+		return CommandResult{Command: Invites, Error: fmt.Errorf("not an admin"), UserError: "Only admins can create invite codes."}
+	}
+
+	validity := inviteValidity
+	if len(args) > 0 {
+		days, err := strconv.Atoi(args[0])
+		if err != nil || days <= 0 || time.Duration(days)*24*time.Hour > maxInviteValidity {
+			return CommandResult{Command: Invites, Error: fmt.Errorf("invalid validity: %v", args), UserError: userErrors[Invites]}
+		}
+		validity = time.Duration(days) * 24 * time.Hour
+	}
+
+	code, err := generateInviteCode()
+	if err != nil {
+		return CommandResult{Command: Invites, Error: err, UserError: userErrors[Unknown]}
+	}
+
+	inv := &Invite{Code: code, CreatedByID: ctx.UserID, ExpiresAt: ctx.Timestamp.Add(validity)}
+	if err := ctx.Repos.InviteRepo().Create(inv); err != nil {
+		return CommandResult{Command: Invites, Error: err, UserError: userErrors[Unknown]}
+	}
+
+	return CommandResult{Command: Invites, UserInfo: ctx.Language.Tf(
+		"Invite code: %s\nValid once, until %s.\n\nThe new user sends the bot:\n!join %s",
+		inv.Code, inv.ExpiresAt.Format("2006-01-02 15:04"), inv.Code,
+	)}
+}
+
+// Random code that is easy to read out and type, e.g. 7GQ2LX4M.
+func generateInviteCode() (string, error) {
+	b := make([]byte, 5)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base32.StdEncoding.EncodeToString(b), nil
+}
@@ -0,0 +1,251 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"regexp"
+	. "remind0/db"
+	r "remind0/repository"
+	"strings"
+	"time"
+)
+
+/**
+ *                                        _
+ *   __ _  ___ ___ ___  _   _ _ __ | |_ ___
+ *  / _` |/ __/ __/ _ \| | | | '_ \| __/ __|
+ * | (_| | (_| (_| (_) | |_| | | | | |_\__ \
+ *  \__,_|\___\___\___/ \__,_|_| |_|\__|___/
+ *
+ * Wallets, cards and savings accounts money moves through.
+ */
+
+var accountTypes = map[string]AccountType{
+	"cash":    AccountCash,
+	"debit":   AccountDebit,
+	"credit":  AccountCredit,
+	"savings": AccountSavings,
+}
+
+// Account names are referenced as @name, so keep them to a single simple word that doesn't read as a date.
+var accountNamePattern = regexp.MustCompile(`^[a-z0-9_-]{1,20}$`)
+
+type AccountBalance struct {
+	Name     string
+	Type     AccountType
+	Currency string
+	Balance  int64 // In minor units of the currency.
+}
+
+func accountID(account *Account) *uint {
+	if account == nil {
+		return nil
+	}
+	return &account.ID
+}
+
+/**
+ * Look up the account marked in a transaction, if any, and work out the
+ * transaction's currency: accounts hold a single currency, so unmarked
+ * amounts follow the account's currency and other currencies are rejected.
+ */
+func resolveAccount(ctx MessageContext, parsed ParsedTx) (*Account, string, error) {
+	if parsed.Account == "" {
+		return nil, parsed.Currency, nil
+	}
+
+	account, err := ctx.Repos.AccountRepo().GetByName(ctx.UserID, parsed.Account)
+	if err != nil {
+		return nil, "", fmt.Errorf("account %q not found: %w", parsed.Account, err)
+	}
+
+	if parsed.ExplicitCurrency && parsed.Currency != account.Currency {
+		return nil, "", fmt.Errorf("account %q holds %s, not %s", account.Name, account.Currency, parsed.Currency)
+	}
+
+	return account, account.Currency, nil
+}
+
+// Names of the user's accounts, to tell them apart from other @ markers.
+func accountNames(ctx MessageContext) (map[string]bool, error) {
+	accounts, err := ctx.Repos.AccountRepo().GetAll(ctx.UserID)
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool, len(accounts))
+	for _, account := range accounts {
+		names[account.Name] = true
+	}
+	return names, nil
+}
+
+/**
+ * Manage accounts: add, remove or list them along with their balances.
+ */
+func accounts(args []string, ctx MessageContext) CommandResult {
+	if len(args) == 0 {
+		return accountBalances(ctx, Accounts)
+	}
+
+	switch action := args[0]; action {
+	case "add", "a", "new":
+		return addAccount(args[1:], ctx)
+	case "remove", "rm", "delete", "del":
+		return removeAccount(args[1:], ctx)
+	case "list", "ls", "l":
+		return accountBalances(ctx, Accounts)
+	default:
+		return CommandResult{Command: Accounts, Error: fmt.Errorf("unknown account action: %s", action), UserError: userErrors[Accounts]}
+	}
+}
+
+/**
+ * Format: <name> <type> <opening balance?> $<currency?>
+ */
+func addAccount(args []string, ctx MessageContext) CommandResult {
+	userId := ctx.UserID
+
+	if len(args) < 2 || len(args) > 4 {
+		return CommandResult{Command: Accounts, Error: fmt.Errorf("invalid arguments: %v", args), UserError: userErrors[Accounts]}
+	}
+
+	user, err := ctx.Repos.UserRepo().GetByID(userId)
+	if err != nil {
+		return CommandResult{Command: Accounts, Error: err, UserError: userErrors[Unknown]}
+	}
+
+	name := strings.ToLower(strings.TrimPrefix(args[0], "@"))
+	if _, isDate := parseDateExpression(name, time.Now()); isDate || !accountNamePattern.MatchString(name) {
+		return CommandResult{Command: Accounts, Error: fmt.Errorf("invalid account name %q", name), UserError: userErrors[Accounts]}
+	}
+
+	accountType, exists := accountTypes[strings.ToLower(args[1])]
+	if !exists {
+		return CommandResult{Command: Accounts, Error: fmt.Errorf("invalid account type %q", args[1]), UserError: userErrors[Accounts]}
+	}
+
+	rest, currency := extractCurrency(args[2:], user.PreferredCurrency)
+
+	var opening int64
+	if len(rest) == 1 {
+		if opening, err = parseMoney(rest[0], currency, ctx.Numbers); err != nil {
+			return CommandResult{Command: Accounts, Error: fmt.Errorf("invalid opening balance %q", rest[0]), UserError: userErrors[Accounts]}
+		}
+	} else if len(rest) > 1 {
+		return CommandResult{Command: Accounts, Error: fmt.Errorf("invalid arguments: %v", rest), UserError: userErrors[Accounts]}
+	}
+
+	account := &Account{UserID: userId, Name: name, Type: accountType, OpeningBalance: opening, Currency: currency}
+	err = ctx.Repos.AccountRepo().Create(account)
+	if IsDuplicate(err) {
+		return CommandResult{Command: Accounts, Error: err, UserError: "An account with that name already exists."}
+	}
+	if err != nil {
+		return CommandResult{Command: Accounts, Error: err, UserError: userErrors[Unknown]}
+	}
+
+	return accountBalances(ctx, Accounts)
+}
+
+func removeAccount(args []string, ctx MessageContext) CommandResult {
+	userId := ctx.UserID
+
+	if len(args) != 1 {
+		return CommandResult{Command: Accounts, Error: fmt.Errorf("expected a single account"), UserError: userErrors[Accounts]}
+	}
+
+	account, err := ctx.Repos.AccountRepo().GetByName(userId, strings.ToLower(strings.TrimPrefix(args[0], "@")))
+	if err != nil {
+		return CommandResult{Command: Accounts, Error: err, UserError: userErrors[Accounts]}
+	}
+
+	if err := ctx.Repos.AccountRepo().Delete(account); err != nil {
+		if errors.Is(err, r.ErrAccountInUse) {
+			return CommandResult{Command: Accounts, Error: err, UserError: "Accounts with transactions or transfers can't be removed."}
+		}
+		return CommandResult{Command: Accounts, Error: err, UserError: userErrors[Unknown]}
+	}
+
+	return accountBalances(ctx, Accounts)
+}
+
+/**
+ * Move money between two accounts of the same currency. Transfers are
+ * neither income nor spending, so they only affect balances.
+ * Format: <amount> @<from> @<to> <notes?>
+ */
+func transfer(args []string, ctx MessageContext) CommandResult {
+	if len(args) < 3 || !strings.HasPrefix(args[1], "@") || !strings.HasPrefix(args[2], "@") {
+		return CommandResult{Command: TransferFunds, Error: fmt.Errorf("invalid arguments: %v", args), UserError: userErrors[TransferFunds]}
+	}
+
+	from, err := ctx.Repos.AccountRepo().GetByName(ctx.UserID, strings.ToLower(strings.TrimPrefix(args[1], "@")))
+	if err != nil {
+		return CommandResult{Command: TransferFunds, Error: fmt.Errorf("account %s: %w", args[1], err), UserError: userErrors[TransferFunds]}
+	}
+	to, err := ctx.Repos.AccountRepo().GetByName(ctx.UserID, strings.ToLower(strings.TrimPrefix(args[2], "@")))
+	if err != nil {
+		return CommandResult{Command: TransferFunds, Error: fmt.Errorf("account %s: %w", args[2], err), UserError: userErrors[TransferFunds]}
+	}
+
+	if from.ID == to.ID || from.Currency != to.Currency {
+		return CommandResult{Command: TransferFunds, Error: fmt.Errorf("cannot transfer from %s to %s", from.Name, to.Name), UserError: userErrors[TransferFunds]}
+	}
+
+	amount, err := parseMoney(args[0], from.Currency, ctx.Numbers)
+	if err != nil || amount <= 0 {
+		return CommandResult{Command: TransferFunds, Error: fmt.Errorf("invalid amount %q", args[0]), UserError: userErrors[TransferFunds]}
+	}
+
+	transfer := &Transfer{
+		UserID:        ctx.UserID,
+		FromAccountID: from.ID,
+		ToAccountID:   to.ID,
+		Amount:        amount,
+		Currency:      from.Currency,
+		Notes:         strings.Join(args[3:], " "),
+		Timestamp:     ctx.Timestamp,
+	}
+	if err := ctx.Repos.AccountRepo().CreateTransfer(transfer); err != nil {
+		return CommandResult{Command: TransferFunds, Error: err, UserError: userErrors[Unknown]}
+	}
+
+	return accountBalances(ctx, TransferFunds)
+}
+
+/**
+ * Current balance of every account: opening balance plus income, minus
+ * spending, plus transfers in, minus transfers out.
+ */
+func accountBalances(ctx MessageContext, command Command) CommandResult {
+	userId := ctx.UserID
+
+	accounts, err := ctx.Repos.AccountRepo().GetAll(userId)
+	if err != nil {
+		return CommandResult{Command: command, Error: err, UserError: userErrors[Unknown]}
+	}
+
+	movements, err := ctx.Repos.AccountRepo().GetMovements(userId, incomeCategory())
+	if err != nil {
+		return CommandResult{Command: command, Error: err, UserError: userErrors[Unknown]}
+	}
+
+	balances := make([]AccountBalance, 0, len(accounts))
+	for _, account := range accounts {
+		balances = append(balances, AccountBalance{
+			Name:     account.Name,
+			Type:     account.Type,
+			Currency: account.Currency,
+			Balance:  account.OpeningBalance + movements[account.ID],
+		})
+	}
+
+	return CommandResult{Command: command, Accounts: balances}
+}
+
+// Name of the only category that brings money in.
+func incomeCategory() string {
+	name, _ := findCategory("$")
+	return name
+}
@@ -0,0 +1,284 @@
+package app
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	. "remind0/db"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	telegramClient "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+/**
+ *            _           _
+ *           | |         (_)
+ *   __ _  __| |_ __ ___  _ _ __
+ *  / _` |/ _` | '_ ` _ \| | '_ \
+ * | (_| | (_| | | | | | | | | | |
+ *  \__,_|\__,_|_| |_| |_|_|_| |_|
+ *
+ * Commands for the admins running the bot.
+ */
+
+const statsWeeks = 8 // Weeks of activity shown by the stats.
+
+/**
+ * Announcement to deliver once the command is done.
+ */
+type Broadcast struct {
+	Text     string
+	ChatIDs  []int64  // Private chats of the recipients, i.e. their Telegram IDs.
+	Language Language // Of the admin, who is told how the delivery went.
+}
+
+/**
+ * Format: <stats|users|block|unblock|broadcast> <args?>
+ */
+func admin(body string, ctx MessageContext) CommandResult {
+	if !ctx.Admin {
+		return CommandResult{Command: Admin, Error: fmt.Errorf("not an admin"), UserError: "Only admins can use this command."}
+	}
+
+	fields := strings.Fields(body)
+	if len(fields) == 0 {
+		return CommandResult{Command: Admin, Error: fmt.Errorf("missing action"), UserError: userErrors[Admin]}
+	}
+
+	switch action := strings.ToLower(fields[0]); action {
+	case "stats":
+		return adminStats(ctx)
+	case "users":
+		return adminUsers(ctx)
+	case "block", "unblock":
+		if len(fields) != 2 {
+			return CommandResult{Command: Admin, Error: fmt.Errorf("missing user"), UserError: userErrors[Admin]}
+		}
+		return setBlocked(fields[1], action == "block", ctx)
+	case "broadcast":
+		// Keep the line breaks of the announcement.
+		return broadcast(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(body), fields[0])), ctx)
+	default:
+		return CommandResult{Command: Admin, Error: fmt.Errorf("unknown admin action: %s", action), UserError: userErrors[Admin]}
+	}
+}
+
+func adminStats(ctx MessageContext) CommandResult {
+	users, err := ctx.Repos.UserRepo().GetAll()
+	if err != nil {
+		return CommandResult{Command: Admin, Error: err, UserError: userErrors[Unknown]}
+	}
+	txCount, err := ctx.Repos.TxRepo().Count()
+	if err != nil {
+		return CommandResult{Command: Admin, Error: err, UserError: userErrors[Unknown]}
+	}
+
+	blocked := 0
+	for _, user := range users {
+		if user.Blocked {
+			blocked++
+		}
+	}
+
+	var info strings.Builder
+	fmt.Fprintf(&info, "Users: %d (%d blocked)\n", len(users), blocked)
+	fmt.Fprintf(&info, "Transactions: %d\n\n", txCount)
+	info.WriteString("Active users (with transactions) per week:\n")
+
+	// Weeks start on Monday.
+	now := ctx.Timestamp
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	weekStart := today.AddDate(0, 0, -(int(today.Weekday())+6)%7)
+
+	for i := 0; i < statsWeeks; i++ {
+		from := weekStart.AddDate(0, 0, -7*i)
+		active, err := ctx.Repos.TxRepo().CountActiveUsers(from, from.AddDate(0, 0, 7))
+		if err != nil {
+			return CommandResult{Command: Admin, Error: err, UserError: userErrors[Unknown]}
+		}
+		fmt.Fprintf(&info, "• Week of %s: %d\n", from.Format("2006-01-02"), active)
+	}
+
+	return CommandResult{Command: Admin, UserInfo: info.String()}
+}
+
+func adminUsers(ctx MessageContext) CommandResult {
+	users, err := ctx.Repos.UserRepo().GetAll()
+	if err != nil {
+		return CommandResult{Command: Admin, Error: err, UserError: userErrors[Unknown]}
+	}
+	counts, err := ctx.Repos.TxRepo().CountByUser()
+	if err != nil {
+		return CommandResult{Command: Admin, Error: err, UserError: userErrors[Unknown]}
+	}
+
+	var info strings.Builder
+	fmt.Fprintf(&info, "%d user(s):\n", len(users))
+	for _, user := range users {
+		name := strings.TrimSpace(user.FirstName + " " + user.LastName)
+		fmt.Fprintf(&info, "• %s", name)
+		if user.Username != "" {
+			fmt.Fprintf(&info, " @%s", user.Username)
+		}
+		fmt.Fprintf(&info, " (%d), %d transaction(s)", user.UserID, counts[user.ID])
+		if isAdmin(user.UserID) {
+			info.WriteString(" 👑")
+		}
+		if user.Blocked {
+			info.WriteString(" ⛔")
+		}
+		info.WriteString("\n")
+	}
+
+	return CommandResult{Command: Admin, UserInfo: info.String()}
+}
+
+/**
+ * Block or unblock a user, given by @username or Telegram ID.
+ */
+func setBlocked(target string, blocked bool, ctx MessageContext) CommandResult {
+	var user *User
+	var err error
+	if tgUserID, parseErr := strconv.ParseInt(target, 10, 64); parseErr == nil {
+		user, err = ctx.Repos.UserRepo().GetByTelegramID(tgUserID)
+	} else {
+		user, err = ctx.Repos.UserRepo().GetByUsername(strings.TrimPrefix(target, "@"))
+	}
+	if err != nil {
+		return CommandResult{Command: Admin, Error: err, UserError: "Unknown user. Use their @username or Telegram ID, see !admin users."}
+	}
+
+	if blocked && isAdmin(user.UserID) {
+		return CommandResult{Command: Admin, Error: errors.New("can't block an admin"), UserError: "Admins can't be blocked."}
+	}
+
+	if err := ctx.Repos.UserRepo().SetBlocked(user.ID, blocked); err != nil {
+		return CommandResult{Command: Admin, Error: err, UserError: userErrors[Unknown]}
+	}
+
+	name := strings.TrimSpace(user.FirstName + " " + user.LastName)
+	if !blocked {
+		ctx.Log.Info("Unblocked user", "blocked_tg_user_id", user.UserID)
+		return CommandResult{Command: Admin, UserInfo: ctx.Language.Tf("Unblocked %s (%d).", name, user.UserID)}
+	}
+	ctx.Log.Info("Blocked user", "blocked_tg_user_id", user.UserID)
+	return CommandResult{Command: Admin, UserInfo: ctx.Language.Tf("Blocked %s (%d).", name, user.UserID)}
+}
+
+/**
+ * Announce something to every user who isn't blocked.
+ */
+func broadcast(text string, ctx MessageContext) CommandResult {
+	if text == "" {
+		return CommandResult{Command: Admin, Error: fmt.Errorf("empty broadcast"), UserError: userErrors[Admin]}
+	}
+
+	users, err := ctx.Repos.UserRepo().GetAll()
+	if err != nil {
+		return CommandResult{Command: Admin, Error: err, UserError: userErrors[Unknown]}
+	}
+
+	chatIDs := make([]int64, 0, len(users))
+	for _, user := range users {
+		if !user.Blocked {
+			chatIDs = append(chatIDs, user.UserID)
+		}
+	}
+
+	return CommandResult{
+		Command:   Admin,
+		UserInfo:  ctx.Language.Tf("Sending the announcement to %d user(s), you'll be told once it's done.", len(chatIDs)),
+		Broadcast: &Broadcast{Text: text, ChatIDs: chatIDs, Language: ctx.Language},
+	}
+}
+
+const (
+	broadcastInterval   = time.Second / 20 // Well under Telegram's limit of 30 messages per second.
+	maxBroadcastRetries = 3                // When Telegram asks to slow down.
+)
+
+var (
+	broadcastLock  sync.Mutex // A single broadcast at a time, so they share the rate limit.
+	broadcasts     sync.WaitGroup
+	stopBroadcasts = make(chan struct{})
+)
+
+/**
+ * Deliver a broadcast in the background, once the command that sent it is
+ * committed. Shutting down waits for it.
+ */
+func startBroadcast(bot *telegramClient.BotAPI, adminChatID int64, b Broadcast) {
+	broadcasts.Add(1)
+	go func() {
+		defer broadcasts.Done()
+		deliverBroadcast(bot, adminChatID, b)
+	}()
+}
+
+/**
+ * Stop the broadcasts being delivered, waiting until their admins are told
+ * how far they got or ctx expires.
+ */
+func ShutdownBroadcasts(ctx context.Context) error {
+	close(stopBroadcasts)
+
+	done := make(chan struct{})
+	go func() {
+		broadcasts.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("broadcasts still being delivered: %w", ctx.Err())
+	}
+}
+
+/**
+ * Deliver a broadcast at a steady pace, then report back to the admin.
+ * Users who never started a private chat with the bot can't be reached.
+ */
+func deliverBroadcast(bot *telegramClient.BotAPI, adminChatID int64, b Broadcast) {
+	broadcastLock.Lock()
+	defer broadcastLock.Unlock()
+
+	pace := time.NewTicker(broadcastInterval)
+	defer pace.Stop()
+
+	delivered := 0
+	for i, chatID := range b.ChatIDs {
+		select {
+		case <-pace.C:
+		case <-stopBroadcasts:
+			slog.Warn("Broadcast interrupted", "delivered", delivered, "remaining", len(b.ChatIDs)-i)
+			bot.Send(telegramClient.NewMessage(adminChatID, b.Language.Tf("📣 Announcement interrupted by a restart, delivered to %d of %d user(s).", delivered, len(b.ChatIDs))))
+			return
+		}
+		if err := sendAnnouncement(bot, chatID, b.Text); err != nil {
+			slog.Warn("Error delivering broadcast", "chat_id", chatID, "error", err)
+			continue
+		}
+		delivered++
+	}
+
+	slog.Info("Broadcast delivered", "delivered", delivered, "recipients", len(b.ChatIDs))
+	bot.Send(telegramClient.NewMessage(adminChatID, b.Language.Tf("📣 Announcement delivered to %d of %d user(s).", delivered, len(b.ChatIDs))))
+}
+
+func sendAnnouncement(bot *telegramClient.BotAPI, chatID int64, text string) error {
+	for attempt := 0; ; attempt++ {
+		_, err := bot.Send(telegramClient.NewMessage(chatID, "📣 "+text))
+
+		var tgErr *telegramClient.Error
+		if !errors.As(err, &tgErr) || tgErr.RetryAfter == 0 || attempt == maxBroadcastRetries {
+			return err
+		}
+		time.Sleep(time.Duration(tgErr.RetryAfter) * time.Second)
+	}
+}
@@ -0,0 +1,386 @@
+package app
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"regexp"
+	. "remind0/db"
+	r "remind0/repository"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	telegramClient "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"gorm.io/gorm"
+)
+
+/**
+ *  _     _ _ _
+ * | |__ (_) | |___
+ * | '_ \| | | / __|
+ * | |_) | | | \__ \
+ * |_.__/|_|_|_|___/
+ *
+ * Bills paid every month (e.g. !bill add power 180 due 15th). Users are
+ * reminded a few days before each is due, with a button that records the
+ * payment as a transaction and moves the bill on to the next month.
+ */
+
+const (
+	defaultBillRemindDays = 3
+	maxBillRemindDays     = 28
+	billReminderHour      = 9 // Local hour bill reminders are sent from.
+
+	// Callback data of a reminder's paid button: bill-paid:<bill ID>:<due date>
+	billPaidAction = "bill-paid"
+)
+
+// Bills are referenced by name, so keep them to a single simple word.
+var billNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,19}$`)
+
+// Categories a bill can be recorded as, the first one by default.
+var billCategories = []string{"U", "R", "SUB"}
+
+// A day of the month, e.g. 15 or 15th.
+var dueDayPattern = regexp.MustCompile(`^([0-9]{1,2})(st|nd|rd|th)?$`)
+
+func parseDueDay(expr string) (int, bool) {
+	match := dueDayPattern.FindStringSubmatch(strings.ToLower(expr))
+	if match == nil {
+		return 0, false
+	}
+	day, _ := strconv.Atoi(match[1])
+	return day, day >= 1 && day <= 31
+}
+
+// The given day of a month, or its last day when the month is shorter.
+func dueDate(year int, month time.Month, day int) time.Time {
+	last := time.Date(year, month+1, 0, 0, 0, 0, 0, time.UTC).Day()
+	return time.Date(year, month, min(day, last), 0, 0, 0, 0, time.UTC)
+}
+
+// First date the bill is due on from today, today included.
+func firstDueDate(day int, today time.Time) time.Time {
+	year, month, date := today.Date()
+	due := dueDate(year, month, day)
+	if due.Before(time.Date(year, month, date, 0, 0, 0, 0, time.UTC)) {
+		due = dueDate(year, month+1, day)
+	}
+	return due
+}
+
+// Move a bill on to its next due date, to be reminded of afresh.
+func advanceBill(bill *Bill) {
+	year, month, _ := bill.NextDue.Date()
+	bill.NextDue = dueDate(year, month+1, bill.DueDay)
+	bill.RemindedAt = nil
+}
+
+/**
+ * Manage bills: add, remove, list them, or pay or skip the next one.
+ */
+func bills(args []string, ctx MessageContext) CommandResult {
+	if len(args) == 0 {
+		return listBills(ctx, nil)
+	}
+
+	switch action := args[0]; action {
+	case "add", "a", "new":
+		return addBill(args[1:], ctx)
+	case "remove", "rm", "delete", "del":
+		return removeBill(args[1:], ctx)
+	case "paid", "pay":
+		return settleBill(args[1:], ctx, true)
+	case "skip":
+		return settleBill(args[1:], ctx, false)
+	case "list", "ls", "l":
+		return listBills(ctx, nil)
+	default:
+		return CommandResult{Command: Bills, Error: fmt.Errorf("unknown bill action: %s", action), UserError: userErrors[Bills]}
+	}
+}
+
+/**
+ * Format: <name> <amount> due <day> <category?> <remind N?> $<currency?>
+ */
+func addBill(args []string, ctx MessageContext) CommandResult {
+	invalid := CommandResult{Command: Bills, Error: fmt.Errorf("invalid arguments: %v", args), UserError: userErrors[Bills]}
+
+	user, err := ctx.Repos.UserRepo().GetByID(ctx.UserID)
+	if err != nil {
+		return CommandResult{Command: Bills, Error: err, UserError: userErrors[Unknown]}
+	}
+
+	rest, currency := extractCurrency(args, user.PreferredCurrency)
+	if len(rest) < 4 || !strings.EqualFold(rest[2], "due") {
+		return invalid
+	}
+
+	name := strings.ToLower(rest[0])
+	if !billNamePattern.MatchString(name) {
+		return invalid
+	}
+
+	amount, err := parseMoney(rest[1], currency, ctx.Numbers)
+	if err != nil || amount <= 0 {
+		return invalid
+	}
+
+	day, ok := parseDueDay(rest[3])
+	if !ok {
+		return invalid
+	}
+
+	category, _ := findCategory(billCategories[0])
+	remindDays := defaultBillRemindDays
+	for i := 4; i < len(rest); i++ {
+		switch code := strings.ToUpper(rest[i]); {
+		case code == "REMIND" && i+1 < len(rest):
+			days, err := strconv.Atoi(rest[i+1])
+			if err != nil || days < 0 || days > maxBillRemindDays {
+				return invalid
+			}
+			remindDays = days
+			i++
+		case slices.Contains(billCategories, code):
+			category, _ = findCategory(code)
+		default:
+			return invalid
+		}
+	}
+
+	bill := &Bill{
+		UserID:     ctx.UserID,
+		Name:       name,
+		Amount:     amount,
+		Currency:   currency,
+		Category:   category,
+		DueDay:     day,
+		NextDue:    firstDueDate(day, ctx.Timestamp),
+		RemindDays: remindDays,
+	}
+	err = ctx.Repos.BillRepo().Create(bill)
+	if IsDuplicate(err) {
+		return CommandResult{Command: Bills, Error: err, UserError: "A bill with that name already exists."}
+	}
+	if err != nil {
+		return CommandResult{Command: Bills, Error: err, UserError: userErrors[Unknown]}
+	}
+
+	return listBills(ctx, nil)
+}
+
+func removeBill(args []string, ctx MessageContext) CommandResult {
+	if len(args) != 1 {
+		return CommandResult{Command: Bills, Error: fmt.Errorf("expected a single bill"), UserError: userErrors[Bills]}
+	}
+
+	bill, err := ctx.Repos.BillRepo().GetByName(ctx.UserID, strings.ToLower(args[0]))
+	if err != nil {
+		return CommandResult{Command: Bills, Error: err, UserError: userErrors[Bills]}
+	}
+
+	if err := ctx.Repos.BillRepo().Delete(bill); err != nil {
+		return CommandResult{Command: Bills, Error: err, UserError: userErrors[Unknown]}
+	}
+
+	return listBills(ctx, nil)
+}
+
+/**
+ * Pay the next due date of a bill, recording it, or skip it (e.g. when
+ * it was already recorded by hand).
+ */
+func settleBill(args []string, ctx MessageContext, paid bool) CommandResult {
+	if len(args) != 1 {
+		return CommandResult{Command: Bills, Error: fmt.Errorf("expected a single bill"), UserError: userErrors[Bills]}
+	}
+
+	bill, err := ctx.Repos.BillRepo().GetByName(ctx.UserID, strings.ToLower(args[0]))
+	if err != nil {
+		return CommandResult{Command: Bills, Error: err, UserError: userErrors[Bills]}
+	}
+
+	if !paid {
+		advanceBill(bill)
+		if err := ctx.Repos.BillRepo().Update(bill); err != nil {
+			return CommandResult{Command: Bills, Error: err, UserError: userErrors[Unknown]}
+		}
+		return listBills(ctx, nil)
+	}
+
+	tx, err := payBill(ctx.Repos, bill, ctx.Timestamp)
+	if IsDuplicate(err) {
+		return CommandResult{Command: Bills, Error: err, UserError: "That bill was already paid or skipped."}
+	}
+	if err != nil {
+		return CommandResult{Command: Bills, Error: err, UserError: userErrors[Unknown]}
+	}
+	return listBills(ctx, []*Transaction{tx})
+}
+
+/**
+ * Record the payment of a bill's next due date as a transaction in its
+ * category, then move it on to the next month. Each due date is only paid
+ * once, even if two payments race.
+ */
+func payBill(repos *r.Repositories, bill *Bill, at time.Time) (*Transaction, error) {
+	tx := &Transaction{
+		Hash:      generateRecordHash(fmt.Sprintf("bill %d due %s", bill.ID, bill.NextDue.Format(time.DateOnly)), bill.Category, bill.Amount, bill.Name, at, bill.UserID, bill.Currency),
+		Notes:     bill.Name,
+		UserID:    bill.UserID,
+		Amount:    bill.Amount,
+		Currency:  bill.Currency,
+		Category:  bill.Category,
+		Timestamp: at,
+	}
+	if _, err := repos.TxRepo().Create([]*Transaction{tx}, SourceChat); err != nil {
+		return nil, err
+	}
+
+	advanceBill(bill)
+	return tx, repos.BillRepo().Update(bill)
+}
+
+func listBills(ctx MessageContext, paid []*Transaction) CommandResult {
+	bills, err := ctx.Repos.BillRepo().GetAll(ctx.UserID)
+	if err != nil {
+		return CommandResult{Command: Bills, Error: err, UserError: userErrors[Unknown]}
+	}
+	return CommandResult{Command: Bills, Bills: bills, Transactions: paid}
+}
+
+/**
+ * Whether a bill's reminder is due at now, in the user's timezone: from
+ * billReminderHour, RemindDays before the due date, outside quiet hours.
+ * Reminders missed while the bot was down are still sent, even late.
+ */
+func billReminderDue(bill *Bill, quietHours string, now time.Time) bool {
+	year, month, day := bill.NextDue.Date()
+	from := time.Date(year, month, day-bill.RemindDays, billReminderHour, 0, 0, 0, now.Location())
+	return !now.Before(from) && !inQuietHours(quietHours, now.Hour()*60+now.Minute())
+}
+
+func billReminderMessage(bill *Bill, lang Language, numbers NumberFormat, now time.Time) string {
+	year, month, day := now.Date()
+	days := int(bill.NextDue.Sub(time.Date(year, month, day, 0, 0, 0, 0, time.UTC)).Hours() / 24)
+	amount := numbers.formatAmount(bill.Amount, bill.Currency)
+	due := bill.NextDue.Format("02-Jan-2006")
+
+	var msg string
+	switch {
+	case days > 0:
+		msg = lang.Tf("🧾 %s (%s) is due in %d day(s), on %s.", bill.Name, amount, days, due)
+	case days == 0:
+		msg = lang.Tf("🧾 %s (%s) is due today.", bill.Name, amount)
+	default:
+		msg = lang.Tf("🧾 %s (%s) was due on %s.", bill.Name, amount, due)
+	}
+	return msg + "\n" + lang.Tf("Tap Paid once it's paid to record it as %s, or use !bill paid %s.", lang.category(bill.Category), bill.Name)
+}
+
+func billPaidButton(bill *Bill, lang Language) telegramClient.InlineKeyboardMarkup {
+	data := fmt.Sprintf("%s:%d:%s", billPaidAction, bill.ID, bill.NextDue.Format("2006-01-02"))
+	return telegramClient.NewInlineKeyboardMarkup(
+		telegramClient.NewInlineKeyboardRow(telegramClient.NewInlineKeyboardButtonData(lang.T("✅ Paid"), data)),
+	)
+}
+
+/**
+ * Remind every user of the bills coming up, at the pace broadcasts are
+ * delivered. As with nudges, the reminder is stored before it's sent, and
+ * only for the due date the bill was loaded with.
+ */
+func (reminders *Reminders) remindBills(now time.Time) {
+	// Reminders go out at most maxBillRemindDays ahead, and a day more covers every timezone.
+	bills, err := r.BillRepo().GetUnreminded(now.AddDate(0, 0, maxBillRemindDays+1))
+	if err != nil {
+		slog.Error("Error loading the bills to remind of", "error", err)
+		return
+	}
+
+	pace := time.NewTicker(broadcastInterval)
+	defer pace.Stop()
+
+	users := map[uint]*User{}
+	for _, bill := range bills {
+		user, found := users[bill.UserID]
+		if !found {
+			if user, err = r.UserRepo().GetByID(bill.UserID); err != nil {
+				slog.Error("Error loading the user to remind", "user_id", bill.UserID, "error", err)
+				continue
+			}
+			users[bill.UserID] = user
+		}
+
+		local := now.In(userLocation(user))
+		if user.Blocked || !billReminderDue(bill, user.QuietHours, local) {
+			continue
+		}
+
+		// The bill may have been paid or skipped since it was loaded.
+		stored, err := r.BillRepo().SetRemindedAt(bill.ID, bill.NextDue, now)
+		if err != nil {
+			slog.Error("Error storing the bill reminder", "bill_id", bill.ID, "error", err)
+			continue
+		}
+		if !stored {
+			continue
+		}
+
+		select {
+		case <-pace.C:
+		case <-reminders.stop:
+			return
+		}
+
+		lang := userLanguage(user, nil)
+		message := telegramClient.NewMessage(user.UserID, billReminderMessage(bill, lang, userNumberFormat(user, nil), local))
+		message.ReplyMarkup = billPaidButton(bill, lang)
+		if _, err := reminders.bot.Send(message); err != nil {
+			slog.Warn("Error sending a bill reminder", "bill_id", bill.ID, "error", err)
+			continue
+		}
+		slog.Info("Reminded user of a bill", "user_id", user.ID, "bill_id", bill.ID)
+	}
+}
+
+/**
+ * Pay a bill from its reminder's button. The button carries the due date
+ * it's for, so a second tap, or one on an older reminder, doesn't pay
+ * twice. Returns the notice shown to the user.
+ */
+func payBillFromButton(outbox *Outbox, query *telegramClient.CallbackQuery, data string, user *User, repos *r.Repositories) (string, error) {
+	lang := userLanguage(user, query.From)
+
+	id, due, _ := strings.Cut(data, ":")
+	billID, err := strconv.ParseUint(id, 10, 64)
+	if err != nil {
+		return lang.T("That bill no longer exists."), nil
+	}
+
+	bill, err := repos.BillRepo().GetByID(uint(billID), user.ID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return lang.T("That bill no longer exists."), nil
+	}
+	if err != nil {
+		return "", err
+	}
+	if bill.NextDue.Format("2006-01-02") != due {
+		return lang.T("That bill was already paid or skipped."), nil
+	}
+
+	tx, err := payBill(repos, bill, time.Now().In(userLocation(user)))
+	if err != nil {
+		return "", err
+	}
+
+	// The reminder loses its button and says how it was settled.
+	if message := query.Message; message != nil {
+		text := message.Text + "\n\n" + lang.Tf("✅ Paid, recorded as transaction %d. Next due on %s.", tx.ID, bill.NextDue.Format("02-Jan-2006"))
+		outbox.Send(telegramClient.NewEditMessageText(message.Chat.ID, message.MessageID, text))
+	}
+	return lang.T("✅ Paid"), nil
+}
@@ -1,7 +1,9 @@
 package app
 
 import (
+	"errors"
 	"fmt"
+	"log/slog"
 	. "remind0/db"
 	r "remind0/repository"
 	"strconv"
@@ -19,6 +21,26 @@ const (
 	Help          Command = "h"
 	Edit          Command = "e"
 	Configuration Command = "config"
+	History       Command = "history"
+	Balances      Command = "balances"
+	Settle        Command = "settle"
+	Lend          Command = "lend"
+	Borrow        Command = "borrow"
+	Repay         Command = "repay"
+	Debts         Command = "debts"
+	Accounts      Command = "account"
+	TransferFunds Command = "transfer"
+	Receipt       Command = "receipt"
+	Invites       Command = "invite"
+	Admin         Command = "admin"
+	Currencies    Command = "currency"
+	Buy           Command = "buy"
+	Sell          Command = "sell"
+	Prices        Command = "price"
+	Portfolio     Command = "portfolio"
+	Trades        Command = "trade"
+	Goals         Command = "goal"
+	Bills         Command = "bill"
 )
 
 type CommandResult struct {
@@ -28,85 +50,348 @@ type CommandResult struct {
 	Command      Command
 	Transactions []*Transaction           // Optional as not all commands return a transaction.
 	Aggregated   []AggregatedTransactions // Optional as not all commands return aggregated data.
+	Events       []*TransactionEvent      // Optional as only the history command returns events.
+	Balances     []Balance                // Optional as only group ledgers return balances.
+	Payments     []Payment                // Optional as only group ledgers return payments.
+	Debts        []DebtBalance            // Optional as only IOU commands return debts.
+	Accounts     []AccountBalance         // Optional as only account commands return balances.
+	Portfolio    []Holding                // Optional as only investment commands return holdings.
+	Trade        *Trade                   // Optional as only trade commands return the trade recorded or removed.
+	Goals        []GoalProgress           // Optional as only goal commands return progress.
+	Bills        []*Bill                  // Optional as only bill commands return bills.
+	Attachments  []*Attachment            // Optional files to send back along with the message.
+	Broadcast    *Broadcast               // Optional announcement to deliver to every user.
+}
+
+/**
+ * Context of the message being handled.
+ */
+type MessageContext struct {
+	UserID    uint      // Internal ID of the sender.
+	Timestamp time.Time // When the message was sent, in the user's timezone.
+	Group     *Group    // Group ledger of the chat, nil in private chats.
+
+	Attachments []Attachment // Photos and documents sent along with the message.
+
+	Admin    bool         // Whether the sender is one of the configured admins.
+	Language Language     // Language to reply in.
+	Numbers  NumberFormat // How the sender writes amounts.
+	Log      *slog.Logger // Tagged with the update being handled.
+
+	Repos  *r.Repositories // Bound to the transaction the message is handled in.
+	Outbox *Outbox         // What to do once that transaction commits.
 }
 
 /**
  * Dispatcher that handles incoming commands from the user.
  */
-func dispatch(msg string, timestamp time.Time, userId uint) CommandResult {
+func dispatch(msg string, ctx MessageContext) CommandResult {
 	switch content := strings.Fields(msg); content[0] {
 	case "add", "a":
-		return add(strings.Join(content[1:], ""), timestamp, userId)
+		// Keep the arguments apart, they used to be glued together (e.g. "G45lunch"),
+		// along with the line breaks of multi-line requests.
+		return add(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(msg), content[0])), ctx)
 	case "remove", "rm", "r", "delete", "del", "d":
-		return remove(content[1:], userId)
+		return remove(content[1:], ctx)
 	case "list", "ls", "l":
-		return list(content, timestamp, userId)
+		return list(content, ctx)
 	case "help", "h":
-		return help(content)
+		return help(content, ctx)
 	case "config", "c", "cfg":
-		return config(content[1:], userId)
+		return config(content[1:], ctx)
 	case "edit", "e", "update", "u":
-		return CommandResult{Command: Edit, Error: fmt.Errorf("edit not implemented"), UserError: userErrors[Edit]}
+		return edit(content[1:], ctx)
+	case "history", "hist":
+		return history(content[1:], ctx)
+	case "balances", "bal":
+		if ctx.Group != nil {
+			return groupBalances(ctx)
+		}
+		return accountBalances(ctx, Balances)
+	case "account", "acc":
+		return accounts(content[1:], ctx)
+	case "transfer", "tf":
+		return transfer(content[1:], ctx)
+	case "currency", "cur":
+		return currencies(content[1:], ctx)
+	case "receipt", "rc":
+		return receipt(content[1:], ctx)
+	case "settle":
+		return settle(content[1:], ctx)
+	case "lend":
+		return recordDebt(DebtLent, content[1:], ctx)
+	case "borrow":
+		return recordDebt(DebtBorrowed, content[1:], ctx)
+	case "repay":
+		return repay(content[1:], ctx)
+	case "debts", "iou":
+		return debtSummary(content[1:], ctx, Debts)
+	case "buy":
+		return trade(TradeBuy, content[1:], ctx)
+	case "sell":
+		return trade(TradeSell, content[1:], ctx)
+	case "price":
+		return setPrice(content[1:], ctx)
+	case "portfolio", "pf":
+		return portfolio(content[1:], ctx, Portfolio)
+	case "trade", "trades":
+		return trades(content[1:], ctx)
+	case "goal", "goals":
+		return goals(content[1:], ctx)
+	case "bill", "bills":
+		return bills(content[1:], ctx)
+	case "admin":
+		return admin(strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(msg), content[0])), ctx)
+	case "invite":
+		return createInvite(content[1:], ctx)
+	case "join":
+		return CommandResult{Command: Invites, UserInfo: ctx.Language.T("You already have access, no invite code needed.")}
 	default:
 		return CommandResult{Command: Unknown, Error: fmt.Errorf("%s not implemented", content[0]), UserError: userErrors[Unknown]}
 	}
 }
 
-func add(body string, timestamp time.Time, userId uint) CommandResult {
+func add(body string, ctx MessageContext) CommandResult {
 
 	/**
 	 * Get user to retrieve preferred currency.
 	 */
-	user, err := r.UserRepo().GetByID(userId)
+	user, err := ctx.Repos.UserRepo().GetByID(ctx.UserID)
 	if err != nil {
 		return CommandResult{Command: Add, Error: err, UserError: userErrors[Unknown]}
 	}
 
+	/**
+	 * Each line of the message is a request of its own (e.g. a day's receipts).
+	 */
+	lines := messageLines(body)
+	if len(lines) == 0 {
+		return CommandResult{Command: Add, Error: fmt.Errorf("empty add request"), UserError: userErrors[Add]}
+	}
+
+	_txs := []*Transaction{}
+	failures := []string{}
+
+	// Lines are numbered as they appear, blank ones included.
+	for n, line := range strings.Split(body, "\n") {
+		if line = strings.TrimSpace(line); line == "" {
+			continue
+		}
+		txs, userError, err := prepareTxs(line, ctx, user, len(_txs))
+		// A line failing on the database fails the message, so it's tried again.
+		if err != nil && (len(lines) == 1 || errors.Is(err, ErrTransient)) {
+			return CommandResult{Command: Add, Error: err, UserError: userError}
+		}
+		if err != nil {
+			failures = append(failures, ctx.Language.Tf("• Line %d: %s", n+1, ctx.Language.T(userError)))
+			continue
+		}
+		_txs = append(_txs, txs...)
+	}
+
+	// All or nothing, so fixed lines can be sent again without duplicating the rest.
+	if len(failures) > 0 {
+		return CommandResult{
+			Command:   Add,
+			Error:     fmt.Errorf("%d of %d lines failed", len(failures), len(lines)),
+			UserError: ctx.Language.T("Nothing was recorded, please fix these lines:\n") + strings.Join(failures, "\n"),
+		}
+	}
+
+	/**
+	 * Create the transaction(s).
+	 */
+	txs, err := ctx.Repos.TxRepo().Create(_txs, SourceChat)
+	if IsDuplicate(err) {
+		return CommandResult{Command: Add, Error: err, UserError: duplicateTransactionError}
+	}
+	if err != nil {
+		return CommandResult{Command: Add, Error: err, UserError: userErrors[Unknown]}
+	}
+
+	return CommandResult{Transactions: txs, Command: Add, Error: nil}
+}
+
+/**
+ * Turn a single add request into the transaction(s) to be created, along with
+ * the message to show the user when it's invalid. The offset keeps hashes of
+ * identical lines in the same message apart, and the message's attachments
+ * go to its first transaction only.
+ */
+func prepareTxs(body string, ctx MessageContext, user *User, offset int) ([]*Transaction, string, error) {
+	userId, timestamp := ctx.UserID, ctx.Timestamp
+
+	/**
+	 * Group expenses can be split between members (e.g. split @alice @bob).
+	 */
+	body, mentions, isSplit := extractSplit(body)
+	if isSplit && ctx.Group == nil {
+		return nil, groupOnlyError, fmt.Errorf("split requested outside a group")
+	}
+
 	/**
 	 * Process incoming add-request message.
 	 */
-	category, amounts, notes, currency, err := parseAddTx(body, user.PreferredCurrency)
+	accounts, err := accountNames(ctx)
+	if err != nil {
+		return nil, userErrors[Unknown], err
+	}
+	parsed, err := parseAddTx(body, user.PreferredCurrency, timestamp, ctx.Numbers, accounts)
+	if errors.Is(err, errParenthesisedBatch) {
+		return nil, parenthesisedBatchError, err
+	}
 	if err != nil {
-		return CommandResult{Command: Add, Error: err, UserError: userErrors[Add]}
+		return nil, userErrors[Add], err
+	}
+	category, amounts, notes := parsed.Category, parsed.Amounts, parsed.Notes
+
+	/**
+	 * Resolve the account the money moved through, which sets the currency.
+	 */
+	account, currency, err := resolveAccount(ctx, parsed)
+	if err != nil {
+		return nil, userErrors[Accounts], err
+	}
+	goal, currency, err := resolveGoal(ctx, parsed, currency)
+	if err != nil {
+		return nil, userErrors[Goals], err
+	}
+
+	var groupId *uint
+	var mode SplitMode
+	var parts []SplitPart
+	var members []*User
+
+	if ctx.Group != nil {
+		groupId = &ctx.Group.ID
+	}
+
+	if isSplit {
+		if mode, parts, err = parseSplit(mentions, currency, ctx.Numbers); err != nil {
+			return nil, splitError, err
+		}
+		if members, err = ctx.Repos.GroupRepo().GetMembers(ctx.Group.ID); err != nil {
+			return nil, userErrors[Unknown], err
+		}
 	}
 
 	/**
 	 * Setup required transactions to be created.
 	 */
 	_txs := []*Transaction{}
-	for i, amount := range amounts {
+	for i, value := range amounts {
+		amount, err := toMinorUnits(value, currency)
+		if err != nil {
+			return nil, userErrors[Add], err
+		}
+
 		// Hash message to prevent duplicates. Include batch index and currency to allow duplicate amounts.
-		hash := generateMessageHash(category, amount, notes, timestamp, userId, i, currency)
+		hash := generateMessageHash(category, amount, notes, timestamp, userId, offset+i, currency)
 
 		// Validate transaction uniqueness.
-		_tx, err := r.TxRepo().GetByHash(hash, userId)
+		_tx, err := ctx.Repos.TxRepo().GetByHash(hash, userId)
 		if _tx != nil && err == nil {
-			return CommandResult{Command: Add, Error: fmt.Errorf("duplicate transaction"), UserError: userErrors[Unknown]}
+			return nil, duplicateTransactionError, fmt.Errorf("duplicate transaction")
+		}
+
+		var splits []Split
+		if isSplit {
+			if splits, err = computeSplits(amount, currency, mode, parts, user, members); err != nil {
+				return nil, splitError, err
+			}
+			// Attach members so the reply can name them.
+			for j := range splits {
+				splits[j].User = *findMemberByID(splits[j].UserID, user, members)
+			}
+		}
+
+		// A receipt is for a single purchase, the first one recorded.
+		var attachments []Attachment
+		if offset+i == 0 {
+			attachments = ownedAttachments(ctx.Attachments, userId)
 		}
 
 		_txs = append(_txs, &Transaction{
-			Hash:      hash,
-			Notes:     notes,
-			UserID:    userId,
-			Amount:    amount,
-			Currency:  currency,
-			Category:  category,
-			Timestamp: timestamp,
+			Hash:        hash,
+			Notes:       notes,
+			UserID:      userId,
+			Amount:      amount,
+			Currency:    currency,
+			Category:    category,
+			Timestamp:   parsed.Timestamp,
+			GroupID:     groupId,
+			Splits:      splits,
+			AccountID:   accountID(account),
+			Account:     account,
+			GoalID:      goalID(goal),
+			Goal:        goal,
+			Attachments: attachments,
+			Expression:  parsed.Expressions[i],
+			Mentions:    parsed.Mentions,
 		})
 	}
 
-	/**
-	 * Create the transaction(s).
-	 */
-	txs, err := r.TxRepo().Create(_txs)
+	return _txs, "", nil
+}
+
+// Copy the message's attachments for a transaction, as each of them gets its own rows.
+func ownedAttachments(attachments []Attachment, userId uint) []Attachment {
+	owned := make([]Attachment, 0, len(attachments))
+	for _, attachment := range attachments {
+		attachment.UserID = userId
+		owned = append(owned, attachment)
+	}
+	return owned
+}
+
+/**
+ * Send back the files stored for a transaction, or store new ones
+ * when they come along with the command.
+ */
+func receipt(args []string, ctx MessageContext) CommandResult {
+	if len(args) != 1 {
+		return CommandResult{Command: Receipt, Error: fmt.Errorf("expected a single ID"), UserError: userErrors[Receipt]}
+	}
+
+	id, err := strconv.ParseInt(args[0], 10, 64)
 	if err != nil {
-		return CommandResult{Command: Add, Error: err, UserError: userErrors[Unknown]}
+		return CommandResult{Command: Receipt, Error: fmt.Errorf("ID must be a number"), UserError: userErrors[Receipt]}
 	}
 
-	return CommandResult{Transactions: txs, Command: Add, Error: nil}
+	tx, err := ctx.Repos.TxRepo().GetById(id, ctx.UserID)
+	if err != nil {
+		return CommandResult{Command: Receipt, Error: fmt.Errorf("ID %d not found: %s", id, err), UserError: userErrors[Receipt]}
+	}
+
+	if len(ctx.Attachments) > 0 {
+		attachments := []*Attachment{}
+		for _, attachment := range ownedAttachments(ctx.Attachments, ctx.UserID) {
+			attachment.TransactionID = tx.ID
+			attachments = append(attachments, &attachment)
+		}
+		if err := ctx.Repos.AttachmentRepo().Create(attachments); err != nil {
+			return CommandResult{Command: Receipt, Error: err, UserError: userErrors[Unknown]}
+		}
+		return CommandResult{Command: Receipt, UserInfo: ctx.Language.Tf("📎 Attached %d file(s) to transaction %d.", len(attachments), tx.ID)}
+	}
+
+	attachments, err := ctx.Repos.AttachmentRepo().GetByTransaction(tx.ID, ctx.UserID)
+	if err != nil {
+		return CommandResult{Command: Receipt, Error: err, UserError: userErrors[Unknown]}
+	}
+	if len(attachments) == 0 {
+		return CommandResult{Command: Receipt, Error: fmt.Errorf("no attachments for ID %d", id), UserError: userErrors[Receipt]}
+	}
+
+	return CommandResult{
+		Command:     Receipt,
+		UserInfo:    ctx.Language.Tf("📎 %d file(s) for transaction %d.", len(attachments), tx.ID),
+		Attachments: attachments,
+	}
 }
 
-func remove(strIds []string, userId uint) CommandResult {
+func remove(strIds []string, ctx MessageContext) CommandResult {
+	userId := ctx.UserID
 
 	// Slice to hold validated IDs to delete
 	ids := []int64{}
@@ -125,22 +410,162 @@ func remove(strIds []string, userId uint) CommandResult {
 	/**
 	 * Verify the transaction exists
 	 */
-	txs, err := r.TxRepo().GetManyById(ids, userId)
+	txs, err := ctx.Repos.TxRepo().GetManyById(ids, userId)
 	if len(txs) == 0 || err != nil {
 		return CommandResult{Command: Remove, Error: fmt.Errorf("IDs %v not found: %s", ids, err), UserError: userErrors[Remove]}
 	}
 
+	// Trades go along with their transaction, so they're removed together from the trade's end.
+	txIds := make([]uint, 0, len(txs))
+	for _, tx := range txs {
+		txIds = append(txIds, tx.ID)
+	}
+	linked, err := ctx.Repos.InvestmentRepo().GetTradesOf(txIds)
+	if err != nil {
+		return CommandResult{Command: Remove, Error: err, UserError: userErrors[Unknown]}
+	}
+	if len(linked) > 0 {
+		trade := linked[0]
+		return CommandResult{
+			Command:   Remove,
+			Error:     fmt.Errorf("ID %d was recorded by trade %d", *trade.TransactionID, trade.ID),
+			UserError: ctx.Language.Tf("Transaction %d was recorded by trade %d, remove it with !trade rm %d instead.", *trade.TransactionID, trade.ID, trade.ID),
+		}
+	}
+
 	/**
 	 * Delete the transaction
 	 */
-	if err := r.TxRepo().Delete(txs); err != nil {
-		return CommandResult{Command: Remove, Error: fmt.Errorf("failed to delete IDs %v: %s", ids, err), UserError: userErrors[Unknown]}
+	if err := ctx.Repos.TxRepo().Delete(txs, SourceChat); err != nil {
+		return CommandResult{Command: Remove, Error: fmt.Errorf("failed to delete IDs %v: %w", ids, err), UserError: userErrors[Unknown]}
 	}
 
 	return CommandResult{Transactions: txs, Command: Remove, Error: nil}
 }
 
-func list(body []string, timestamp time.Time, userId uint) CommandResult {
+func edit(args []string, ctx MessageContext) CommandResult {
+	userId := ctx.UserID
+
+	if len(args) < 3 {
+		return CommandResult{Command: Edit, Error: fmt.Errorf("missing arguments"), UserError: userErrors[Edit]}
+	}
+
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return CommandResult{Command: Edit, Error: fmt.Errorf("ID must be a number"), UserError: userErrors[Edit]}
+	}
+
+	/**
+	 * Verify the transaction exists
+	 */
+	tx, err := ctx.Repos.TxRepo().GetById(id, userId)
+	if err != nil {
+		return CommandResult{Command: Edit, Error: fmt.Errorf("ID %d not found: %s", id, err), UserError: userErrors[Edit]}
+	}
+
+	// Group expenses may be shared out from the original amount, which would no longer add up.
+	if tx.GroupID != nil {
+		return CommandResult{Command: Edit, Error: fmt.Errorf("ID %d is a group expense", id), UserError: userErrors[Edit]}
+	}
+
+	// So are the units and amount of a trade.
+	linked, err := ctx.Repos.InvestmentRepo().GetTradesOf([]uint{tx.ID})
+	if err != nil {
+		return CommandResult{Command: Edit, Error: err, UserError: userErrors[Unknown]}
+	}
+	if len(linked) > 0 {
+		return CommandResult{
+			Command:   Edit,
+			Error:     fmt.Errorf("ID %d was recorded by trade %d", id, linked[0].ID),
+			UserError: ctx.Language.Tf("Transaction %d was recorded by trade %d, remove it with !trade rm %d and record the trade again instead.", id, linked[0].ID, linked[0].ID),
+		}
+	}
+
+	/**
+	 * The replacement values follow the same format as an add request,
+	 * falling back to the transaction's current currency.
+	 */
+	accounts, err := accountNames(ctx)
+	if err != nil {
+		return CommandResult{Command: Edit, Error: err, UserError: userErrors[Unknown]}
+	}
+	parsed, err := parseAddTx(strings.Join(args[1:], " "), tx.Currency, ctx.Timestamp, ctx.Numbers, accounts)
+	if errors.Is(err, errParenthesisedBatch) {
+		return CommandResult{Command: Edit, Error: err, UserError: parenthesisedBatchError}
+	}
+	if err != nil || len(parsed.Amounts) != 1 {
+		return CommandResult{Command: Edit, Error: fmt.Errorf("invalid edit values: %v", err), UserError: userErrors[Edit]}
+	}
+
+	account, currency, err := resolveAccount(ctx, parsed)
+	if err != nil {
+		return CommandResult{Command: Edit, Error: err, UserError: userErrors[Accounts]}
+	}
+	goal, currency, err := resolveGoal(ctx, parsed, currency)
+	if err != nil {
+		return CommandResult{Command: Edit, Error: err, UserError: userErrors[Goals]}
+	}
+
+	amount, err := toMinorUnits(parsed.Amounts[0], currency)
+	if err != nil {
+		return CommandResult{Command: Edit, Error: err, UserError: userErrors[Edit]}
+	}
+
+	tx.Category = parsed.Category
+	tx.Amount = amount
+	tx.Expression = parsed.Expressions[0]
+	tx.Mentions = parsed.Mentions
+	tx.Notes = parsed.Notes
+	tx.Currency = currency
+	tx.AccountID = accountID(account)
+	tx.GoalID = goalID(goal)
+	if parsed.BackDated {
+		tx.Timestamp = parsed.Timestamp
+	}
+
+	// Hashed as if this message had added the new values, so duplicates match what it now holds.
+	tx.Hash = generateMessageHash(tx.Category, tx.Amount, tx.Notes, ctx.Timestamp, userId, 0, tx.Currency)
+
+	err = ctx.Repos.TxRepo().Update(tx, SourceChat)
+	if IsDuplicate(err) {
+		return CommandResult{Command: Edit, Error: err, UserError: duplicateTransactionError}
+	}
+	if err != nil {
+		return CommandResult{Command: Edit, Error: fmt.Errorf("failed to update ID %d: %w", id, err), UserError: userErrors[Unknown]}
+	}
+	tx.Account, tx.Goal = account, goal
+
+	return CommandResult{Transactions: []*Transaction{tx}, Command: Edit, Error: nil}
+}
+
+func history(args []string, ctx MessageContext) CommandResult {
+	userId := ctx.UserID
+
+	if len(args) != 1 {
+		return CommandResult{Command: History, Error: fmt.Errorf("expected a single ID"), UserError: userErrors[History]}
+	}
+
+	id, err := strconv.ParseInt(args[0], 10, 64)
+	if err != nil {
+		return CommandResult{Command: History, Error: fmt.Errorf("ID must be a number"), UserError: userErrors[History]}
+	}
+
+	/**
+	 * Events outlive their transaction, so deleted transactions still have a history.
+	 */
+	events, err := ctx.Repos.EventRepo().GetByTransaction(id, userId)
+	if err != nil {
+		return CommandResult{Command: History, Error: err, UserError: userErrors[Unknown]}
+	}
+	if len(events) == 0 {
+		return CommandResult{Command: History, Error: fmt.Errorf("no history for ID %d", id), UserError: userErrors[History]}
+	}
+
+	return CommandResult{Command: History, Events: events}
+}
+
+func list(body []string, ctx MessageContext) CommandResult {
+	timestamp, userId := ctx.Timestamp, ctx.UserID
 
 	opts, err := parseListOptions(body, timestamp)
 	if err != nil {
@@ -153,7 +578,7 @@ func list(body []string, timestamp time.Time, userId uint) CommandResult {
 
 	// Handle category filtering
 	if opts.Category != "" {
-		txs, err := r.TxRepo().GetManyByCategory(userId, opts.Category, opts.FromTime, opts.Limit)
+		txs, err := ctx.Repos.TxRepo().GetManyByCategory(userId, opts.Category, opts.FromTime, opts.Limit)
 		if err != nil {
 			return CommandResult{
 				Command:   List,
@@ -169,7 +594,7 @@ func list(body []string, timestamp time.Time, userId uint) CommandResult {
 
 	// Handle currency filtering
 	if opts.Currency != "" {
-		txs, err := r.TxRepo().GetManyByCurrency(userId, opts.Currency, opts.FromTime, opts.Limit)
+		txs, err := ctx.Repos.TxRepo().GetManyByCurrency(userId, opts.Currency, opts.FromTime, opts.Limit)
 		if err != nil {
 			return CommandResult{
 				Command:   List,
@@ -184,7 +609,7 @@ func list(body []string, timestamp time.Time, userId uint) CommandResult {
 	}
 
 	// Get all transactions
-	txs, err := r.TxRepo().GetAll(userId, opts.FromTime, opts.Limit)
+	txs, err := ctx.Repos.TxRepo().GetAll(userId, opts.FromTime, opts.Limit)
 	if err != nil {
 		return CommandResult{
 			Command:   List,
@@ -198,34 +623,61 @@ func list(body []string, timestamp time.Time, userId uint) CommandResult {
 	return CommandResult{Command: List, Transactions: txs}
 }
 
-func help(args []string) CommandResult {
+func help(args []string, ctx MessageContext) CommandResult {
 	if len(args) == 1 {
-		return CommandResult{Command: Help, UserInfo: userHelp[HelpTopic{Command: Help}]}
+		return CommandResult{Command: Help, UserInfo: ctx.Language.help(HelpTopic{Command: Help})}
 	}
 
+	var topic HelpTopic
 	switch args[1] {
 	case "add", "a":
-		return CommandResult{Command: Help, UserInfo: userHelp[HelpTopic{Command: Add}]}
+		topic = HelpTopic{Command: Add}
 	case "remove", "rm", "r", "delete", "del", "d":
-		return CommandResult{Command: Help, UserInfo: userHelp[HelpTopic{Command: Remove}]}
+		topic = HelpTopic{Command: Remove}
 	case "list", "ls", "l":
-		return CommandResult{Command: Help, UserInfo: userHelp[HelpTopic{Command: List}]}
+		topic = HelpTopic{Command: List}
 	case "help", "h":
-		return CommandResult{Command: Help, UserInfo: userHelp[HelpTopic{Command: Help}]}
+		topic = HelpTopic{Command: Help}
 	case "categories", "cats":
-		return CommandResult{Command: Help, UserInfo: userHelp[HelpTopic{Command: Help, Subtopic: "Categories"}]}
-	case "currencies", "curr":
-		return CommandResult{Command: Help, UserInfo: userHelp[HelpTopic{Command: Help, Subtopic: "Currencies"}]}
+		// Listed with their names in the user's language.
+		return CommandResult{Command: Help, UserInfo: getCategoriesMessage(ctx.Language)}
+	case "receipt", "rc", "receipts":
+		topic = HelpTopic{Command: Receipt}
+	case "account", "acc", "accounts", "transfer", "tf", "balances", "bal":
+		topic = HelpTopic{Command: Accounts}
+	case "groups", "group", "settle":
+		topic = HelpTopic{Command: Help, Subtopic: "Groups"}
+	case "debts", "iou", "lend", "borrow", "repay":
+		topic = HelpTopic{Command: Debts}
+	case "currencies", "curr", "currency", "cur":
+		// Custom currencies come and go.
+		return CommandResult{Command: Help, UserInfo: getCurrenciesListMessage(ctx.Language)}
+	case "portfolio", "pf", "buy", "sell", "price", "investments":
+		topic = HelpTopic{Command: Portfolio}
+	case "goal", "goals":
+		topic = HelpTopic{Command: Goals}
+	case "bill", "bills":
+		topic = HelpTopic{Command: Bills}
 	case "config", "cfg":
-		return CommandResult{Command: Help, UserInfo: userHelp[HelpTopic{Command: Configuration}]}
+		topic = HelpTopic{Command: Configuration}
 	case "edit", "e", "update", "u":
-		return CommandResult{Command: Help, UserError: userErrors[Edit]}
+		topic = HelpTopic{Command: Edit}
+	case "history", "hist":
+		topic = HelpTopic{Command: History}
+	case "invite", "join":
+		topic = HelpTopic{Command: Invites}
+	case "admin":
+		topic = HelpTopic{Command: Admin}
 	default:
-		return CommandResult{Command: Help, UserError: "Unknown command. Available commands are: add, rm, ls, help, config, edit."}
+		return CommandResult{Command: Help, UserError: "Unknown command. Available commands are: add, rm, ls, edit, history, receipt, account, transfer, currency, balances, settle, lend, borrow, repay, debts, goal, bill, buy, sell, price, portfolio, invite, help, config."}
 	}
+
+	return CommandResult{Command: Help, UserInfo: ctx.Language.help(topic)}
 }
 
-func config(args []string, userId uint) CommandResult {
+func config(args []string, ctx MessageContext) CommandResult {
+	userId := ctx.UserID
+
 	if len(args) < 2 {
 		return CommandResult{
 			Command:   Configuration,
@@ -249,7 +701,7 @@ func config(args []string, userId uint) CommandResult {
 		}
 
 		// Update user's preferred currency
-		user, err := r.UserRepo().GetByID(userId)
+		user, err := ctx.Repos.UserRepo().GetByID(userId)
 		if err != nil {
 			return CommandResult{
 				Command:   Configuration,
@@ -259,7 +711,7 @@ func config(args []string, userId uint) CommandResult {
 		}
 
 		user.PreferredCurrency = currencyCode
-		if err := r.UserRepo().Update(user); err != nil {
+		if err := ctx.Repos.UserRepo().Update(user, "preferred_currency"); err != nil {
 			return CommandResult{
 				Command:   Configuration,
 				Error:     err,
@@ -269,9 +721,121 @@ func config(args []string, userId uint) CommandResult {
 
 		return CommandResult{
 			Command:  Configuration,
-			UserInfo: fmt.Sprintf("✅ Default currency set to %s (%s)", currencyCode, supportedCurrencies[currencyCode]),
+			UserInfo: ctx.Language.Tf("✅ Default currency set to %s (%s)", currencyCode, currencyName(currencyCode)),
 		}
 
+	case "set-timezone", "stz":
+		loc, err := time.LoadLocation(args[1])
+		if err != nil || args[1] == "" || strings.EqualFold(args[1], "local") {
+			return CommandResult{
+				Command:   Configuration,
+				Error:     fmt.Errorf("invalid timezone: %s", args[1]),
+				UserError: "Invalid timezone. Use a name like Pacific/Auckland or America/Buenos_Aires.",
+			}
+		}
+
+		user, err := ctx.Repos.UserRepo().GetByID(userId)
+		if err != nil {
+			return CommandResult{
+				Command:   Configuration,
+				Error:     err,
+				UserError: userErrors[Unknown],
+			}
+		}
+
+		user.Timezone = loc.String()
+		if err := ctx.Repos.UserRepo().Update(user, "timezone"); err != nil {
+			return CommandResult{
+				Command:   Configuration,
+				Error:     err,
+				UserError: userErrors[Unknown],
+			}
+		}
+
+		return CommandResult{
+			Command:  Configuration,
+			UserInfo: ctx.Language.Tf("✅ Timezone set to %s (now %s)", loc.String(), time.Now().In(loc).Format("02-Jan-2006 15:04")),
+		}
+
+	case "set-language", "sl":
+		code := strings.ToLower(args[1])
+		if _, ok := languages[Language(code)]; !ok && code != "auto" {
+			return CommandResult{
+				Command:   Configuration,
+				Error:     fmt.Errorf("unsupported language: %s", args[1]),
+				UserError: "Unsupported language. Use en (English), es (Español), pt (Português) or auto.",
+			}
+		}
+
+		user, err := ctx.Repos.UserRepo().GetByID(userId)
+		if err != nil {
+			return CommandResult{
+				Command:   Configuration,
+				Error:     err,
+				UserError: userErrors[Unknown],
+			}
+		}
+
+		// Auto follows the language of the user's Telegram app.
+		user.Language = code
+		if code == "auto" {
+			user.Language = ""
+		}
+		if err := ctx.Repos.UserRepo().Update(user, "language"); err != nil {
+			return CommandResult{
+				Command:   Configuration,
+				Error:     err,
+				UserError: userErrors[Unknown],
+			}
+		}
+
+		if code == "auto" {
+			return CommandResult{Command: Configuration, UserInfo: ctx.Language.T("✅ Language set to follow your Telegram app")}
+		}
+		lang := Language(code)
+		return CommandResult{Command: Configuration, UserInfo: lang.Tf("✅ Language set to %s", languages[lang])}
+
+	case "set-number-format", "snf":
+		name := strings.ToLower(args[1])
+		numbers, ok := numberFormats[name]
+		if !ok && name != "auto" {
+			return CommandResult{
+				Command:   Configuration,
+				Error:     fmt.Errorf("unsupported number format: %s", args[1]),
+				UserError: "Unsupported number format. Use en (1,234.56), eu (1.234,56), br (R$ 1.234,56), fr (1 234,56), ch (1'234.56) or auto.",
+			}
+		}
+
+		user, err := ctx.Repos.UserRepo().GetByID(userId)
+		if err != nil {
+			return CommandResult{
+				Command:   Configuration,
+				Error:     err,
+				UserError: userErrors[Unknown],
+			}
+		}
+
+		// Auto follows the user's language.
+		user.NumberFormat = numbers.Name
+		if err := ctx.Repos.UserRepo().Update(user, "number_format"); err != nil {
+			return CommandResult{
+				Command:   Configuration,
+				Error:     err,
+				UserError: userErrors[Unknown],
+			}
+		}
+
+		if !ok {
+			return CommandResult{Command: Configuration, UserInfo: ctx.Language.T("✅ Number format set to follow your language")}
+		}
+		return CommandResult{
+			Command:  Configuration,
+			UserInfo: ctx.Language.Tf("✅ Number format set to %s", numbers.formatAmount(123456, user.PreferredCurrency)),
+		}
+
+	case "reminders", "rem":
+		return configureReminders(args[1:], ctx)
+
 	default:
 		return CommandResult{
 			Command:   Configuration,
@@ -1,51 +1,346 @@
 package app
 
 import (
+	"errors"
+	"flag"
 	"fmt"
-	"log"
+	"io/fs"
+	"log/slog"
 	"os"
+	"remind0/logging"
+	"strconv"
 	"strings"
+	"time"
 
 	dotEnv "github.com/joho/godotenv"
+	"gopkg.in/yaml.v3"
 )
 
+/**
+ * Configuration, layered from lowest to highest precedence:
+ *
+ *	1. A YAML file, given by --config or CONFIG_FILE (optional)
+ *	2. Environment variables, also read from .env outside production
+ *	3. Command line flags
+ *
+ * Any variable can instead be read from the file named by <NAME>_FILE,
+ * e.g. TURSO_AUTH_TOKEN_FILE=/run/secrets/turso (Docker secrets).
+ */
+
 type Config struct {
-	TursoDSN       string
-	TursoAuthToken string
-	TelegramToken  string
+	Telegram   TelegramConfig   `yaml:"telegram"`
+	Database   DatabaseConfig   `yaml:"database"`
+	Access     AccessConfig     `yaml:"access"`
+	Log        logging.Options  `yaml:"log"`
+	Monitoring MonitoringConfig `yaml:"monitoring"`
+	Prices     PricesConfig     `yaml:"prices"`
+}
+
+type TelegramConfig struct {
+	Token       string        `yaml:"token"`
+	Debug       bool          `yaml:"debug"`        // Log the raw API traffic, masked when redacting.
+	PollTimeout time.Duration `yaml:"poll_timeout"` // How long a poll for updates waits for one.
+}
+
+type DatabaseConfig struct {
+	URL       string `yaml:"url"`
+	AuthToken string `yaml:"auth_token"`
+}
+
+type AccessConfig struct {
+	Mode       AccessMode `yaml:"mode"`    // Who may use the bot, open to everyone by default.
+	AdminIDs   []int64    `yaml:"admins"`  // Telegram IDs of the admins, who always have access.
+	AllowedIDs []int64    `yaml:"allowed"` // Telegram IDs let in whatever the access mode.
+}
+
+type MonitoringConfig struct {
+	Addr string `yaml:"addr"` // Address of the health and metrics server (e.g. :9090), none if empty.
+}
+
+type PricesConfig struct {
+	File string `yaml:"file"` // CSV of instrument prices to value holdings with, none if empty.
+}
+
+// DSN of the database, credentials included.
+func (config *Config) DatabaseDSN() string {
+	return config.Database.URL + "?authToken=" + config.Database.AuthToken
+}
+
+func defaultConfig() *Config {
+	return &Config{
+		Telegram: TelegramConfig{PollTimeout: 60 * time.Second},
+		Access:   AccessConfig{Mode: AccessOpen},
+		Log:      logging.Options{Level: slog.LevelInfo, Format: "text", Redact: true},
+	}
+}
+
+/**
+ * A setting that can come from the environment or a flag, besides the file.
+ */
+type setting struct {
+	env   string
+	flag  string
+	usage string
+	set   setter
+}
+
+type setter interface {
+	Set(value string) error
+}
+
+type setterFunc func(value string) error
+
+func (f setterFunc) Set(value string) error { return f(value) }
+
+// Setter of a boolean, whose flag can be given without a value.
+type boolSetter struct{ setterFunc }
+
+func (boolSetter) IsBoolFlag() bool { return true }
+
+func (config *Config) settings() []setting {
+	return []setting{
+		{"TELEGRAM_BOT_TOKEN", "telegram-token", "Telegram bot token", setString(&config.Telegram.Token)},
+		{"TELEGRAM_DEBUG", "telegram-debug", "log the Telegram API traffic", setBool(&config.Telegram.Debug)},
+		{"TELEGRAM_POLL_TIMEOUT", "poll-timeout", "how long a poll for updates waits, e.g. 60s", setDuration(&config.Telegram.PollTimeout)},
+		{"TURSO_DATABASE_URL", "db-url", "database URL", setString(&config.Database.URL)},
+		{"TURSO_AUTH_TOKEN", "db-auth-token", "database auth token", setString(&config.Database.AuthToken)},
+		{"ACCESS_MODE", "access-mode", "open, allowlist or invite", setAccessMode(&config.Access.Mode)},
+		{"ADMIN_USER_IDS", "admins", "comma separated Telegram IDs of the admins", setUserIDs(&config.Access.AdminIDs)},
+		{"ALLOWED_USER_IDS", "allowed", "comma separated Telegram IDs always let in", setUserIDs(&config.Access.AllowedIDs)},
+		{"LOG_LEVEL", "log-level", "debug, info, warn or error", setLevel(&config.Log.Level)},
+		{"LOG_FORMAT", "log-format", "text or json", setString(&config.Log.Format)},
+		{"LOG_REDACT", "log-redact", "mask financial data in the logs", setBool(&config.Log.Redact)},
+		{"MONITORING_ADDR", "monitoring-addr", "address of the health and metrics server, e.g. :9090", setString(&config.Monitoring.Addr)},
+		{"PRICES_FILE", "prices-file", "CSV of instrument prices: instrument,price,currency,date?", setString(&config.Prices.File)},
+	}
 }
 
-func LoadConfig() (*Config, error) {
+/**
+ * Load the configuration from the file, environment and command line args.
+ * Returns the args left after the flags (e.g. a subcommand). Every problem
+ * found is reported at once.
+ */
+func LoadConfig(args []string) (*Config, []string, error) {
+	config := defaultConfig()
+	settings := config.settings()
+
+	// Flags are parsed first, to find the file, but applied last.
+	flags := flag.NewFlagSet("remind0", flag.ContinueOnError)
+	configFile := flags.String("config", "", "YAML configuration file")
+	raw := map[string]*rawFlag{}
+	for _, s := range settings {
+		_, isBool := s.set.(boolSetter)
+		raw[s.flag] = &rawFlag{isBool: isBool}
+		flags.Var(raw[s.flag], s.flag, fmt.Sprintf("%s (env %s)", s.usage, s.env))
+	}
+	if err := flags.Parse(args); err != nil {
+		return nil, nil, err
+	}
+
+	var errs []error
 
 	if os.Getenv("ENV") != "production" {
-		err := dotEnv.Load()
+		if err := dotEnv.Load(); err != nil && !errors.Is(err, fs.ErrNotExist) {
+			errs = append(errs, fmt.Errorf(".env: %w", err))
+		}
+	}
+
+	// 1. File
+	if *configFile == "" {
+		*configFile = os.Getenv("CONFIG_FILE")
+	}
+	if *configFile != "" {
+		if err := config.loadFile(*configFile); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", *configFile, err))
+		}
+	}
+
+	// 2. Environment
+	for _, s := range settings {
+		value, ok, err := lookupEnv(s.env)
 		if err != nil {
-			log.Fatal("⚠️ Failed to load environment variables")
+			errs = append(errs, err)
+			continue
 		}
+		if !ok {
+			continue
+		}
+		if err := s.set.Set(value); err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", s.env, err))
+		}
+	}
+
+	// 3. Flags
+	for _, s := range settings {
+		if value := raw[s.flag]; value.isSet {
+			if err := s.set.Set(value.value); err != nil {
+				errs = append(errs, fmt.Errorf("--%s: %w", s.flag, err))
+			}
+		}
+	}
+
+	errs = append(errs, config.validate()...)
+	if len(errs) > 0 {
+		return nil, nil, fmt.Errorf("⚠️ Invalid configuration:\n%w", errors.Join(errs...))
+	}
+
+	return config, flags.Args(), nil
+}
+
+func (config *Config) loadFile(path string) error {
+	file, err := os.Open(path)
+	if err != nil {
+		return err
 	}
+	defer file.Close()
+
+	decoder := yaml.NewDecoder(file)
+	decoder.KnownFields(true) // Catch typos.
+	return decoder.Decode(config)
+}
+
+/**
+ * Value of an environment variable, or of the file named by <NAME>_FILE.
+ */
+func lookupEnv(name string) (string, bool, error) {
+	value, ok := os.LookupEnv(name)
+	path, fromFile := os.LookupEnv(name + "_FILE")
 
-	config := &Config{
-		TursoDSN:       os.Getenv("TURSO_DATABASE_URL"),
-		TursoAuthToken: os.Getenv("TURSO_AUTH_TOKEN"),
-		TelegramToken:  os.Getenv("TELEGRAM_BOT_TOKEN"),
+	switch {
+	case ok && fromFile:
+		return "", false, fmt.Errorf("%s and %s_FILE are both set, only one can be", name, name)
+	case fromFile:
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return "", false, fmt.Errorf("%s_FILE: %w", name, err)
+		}
+		return strings.TrimSpace(string(content)), true, nil
+	default:
+		return value, ok, nil
 	}
+}
+
+func (config *Config) validate() []error {
+	var errs []error
 
 	missing := make([]string, 0)
-	if config.TursoDSN == "" {
+	if config.Database.URL == "" {
 		missing = append(missing, "TURSO_DATABASE_URL")
 	}
-	if config.TursoAuthToken == "" {
+	if config.Database.AuthToken == "" {
 		missing = append(missing, "TURSO_AUTH_TOKEN")
 	}
-	if config.TelegramToken == "" {
+	if config.Telegram.Token == "" {
 		missing = append(missing, "TELEGRAM_BOT_TOKEN")
 	}
-
 	if len(missing) > 0 {
-		return nil, fmt.Errorf("⚠️ Missing required environment variables: %s", strings.Join(missing, ", "))
+		errs = append(errs, fmt.Errorf("missing required settings: %s", strings.Join(missing, ", ")))
 	}
 
-	log.Println("✅ Configuration loaded successfully")
+	if !config.Access.Mode.valid() {
+		errs = append(errs, fmt.Errorf("access mode %q, expected open, allowlist or invite", config.Access.Mode))
+	}
+	if config.Log.Format != "text" && config.Log.Format != "json" {
+		errs = append(errs, fmt.Errorf("log format %q, expected text or json", config.Log.Format))
+	}
+	if config.Prices.File != "" {
+		if _, err := os.Stat(config.Prices.File); err != nil {
+			errs = append(errs, fmt.Errorf("prices file: %w", err))
+		}
+	}
+	if config.Telegram.PollTimeout < time.Second {
+		errs = append(errs, fmt.Errorf("poll timeout %s, expected at least 1s", config.Telegram.PollTimeout))
+	}
+
+	return errs
+}
+
+/**
+ * Flag value kept as given, to be applied after the file and environment.
+ */
+type rawFlag struct {
+	value  string
+	isSet  bool
+	isBool bool
+}
+
+func (f *rawFlag) String() string   { return f.value }
+func (f *rawFlag) IsBoolFlag() bool { return f.isBool }
+
+func (f *rawFlag) Set(value string) error {
+	f.value, f.isSet = value, true
+	return nil
+}
+
+func setString(target *string) setter {
+	return setterFunc(func(value string) error {
+		*target = value
+		return nil
+	})
+}
+
+func setBool(target *bool) setter {
+	return boolSetter{func(value string) error {
+		parsed, err := strconv.ParseBool(value)
+		if err != nil {
+			return fmt.Errorf("%q isn't true or false", value)
+		}
+		*target = parsed
+		return nil
+	}}
+}
+
+func setDuration(target *time.Duration) setter {
+	return setterFunc(func(value string) error {
+		parsed, err := time.ParseDuration(value)
+		if err != nil {
+			return fmt.Errorf("%q isn't a duration, e.g. 60s", value)
+		}
+		*target = parsed
+		return nil
+	})
+}
 
-	return config, nil
+func setLevel(target *slog.Level) setter {
+	return setterFunc(func(value string) error {
+		if err := target.UnmarshalText([]byte(value)); err != nil {
+			return fmt.Errorf("%q isn't debug, info, warn or error", value)
+		}
+		return nil
+	})
+}
+
+func setAccessMode(target *AccessMode) setter {
+	return setterFunc(func(value string) error {
+		*target = AccessMode(strings.ToLower(value))
+		return nil
+	})
+}
+
+func setUserIDs(target *[]int64) setter {
+	return setterFunc(func(value string) error {
+		ids, err := parseUserIDs(value)
+		if err != nil {
+			return err
+		}
+		*target = ids
+		return nil
+	})
+}
+
+// Parse a comma separated list of Telegram user IDs.
+func parseUserIDs(list string) ([]int64, error) {
+	ids := make([]int64, 0)
+	for _, field := range strings.Split(list, ",") {
+		field = strings.TrimSpace(field)
+		if field == "" {
+			continue
+		}
+		id, err := strconv.ParseInt(field, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%q is not a Telegram user ID", field)
+		}
+		ids = append(ids, id)
+	}
+	return ids, nil
 }
@@ -1,76 +1,152 @@
 package app
 
 import (
+	"errors"
 	"fmt"
-	"log"
+	"log/slog"
 	"strings"
 	"time"
 
 	. "remind0/db"
+	"remind0/monitoring"
 	r "remind0/repository"
 
 	telegramClient "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"gorm.io/gorm"
 )
 
 /**
  * Get updates using long-polling.
  * This will return a channel for updates.
- * Updates will be polled every 60 seconds.
+ * Each poll waits up to pollTimeout for updates to come in.
  */
-func ConnectBot(bot *telegramClient.BotAPI, offset *Offset) telegramClient.UpdatesChannel {
+func ConnectBot(bot *telegramClient.BotAPI, offset *Offset, pollTimeout time.Duration) telegramClient.UpdatesChannel {
 	u := telegramClient.NewUpdate(offset.Offset)
-	u.Timeout = 60
-	log.Println("✅ Channel opened")
+	u.Timeout = int(pollTimeout.Seconds())
+	slog.Info("Update channel opened", "offset", offset.Offset)
 	return bot.GetUpdatesChan(u)
 }
 
-func HandleTelegramMessage(bot *telegramClient.BotAPI, update telegramClient.Update) {
+/**
+ * Handle a message and queue the reply to it. Errors are only returned when
+ * the message couldn't be handled and is worth trying again (e.g. the
+ * database failed), anything the user got wrong is replied to instead.
+ */
+func HandleTelegramMessage(outbox *Outbox, update telegramClient.Update, repos *r.Repositories) error {
+
+	// Messages without a sender (e.g. channel posts) can't be attributed to anyone.
+	if update.Message.From == nil {
+		return nil
+	}
 
-	tgUserID := update.Message.Chat.ID                    // Get Telegram user ID
+	chatID := update.Message.Chat.ID                      // Get chat to reply to
+	tgUserID := update.Message.From.ID                    // Get Telegram user ID
 	body := update.Message.Text                           // Extract message text
 	timestamp := time.Unix(int64(update.Message.Date), 0) // Extract timestamp
+	inGroup := update.Message.Chat.IsGroup() || update.Message.Chat.IsSuperGroup()
+
+	// Photos and documents carry their text as a caption.
+	if body == "" {
+		body = update.Message.Caption
+	}
 
-	log.Printf("✅ Received message: %+v", struct {
-		User      string
-		Body      string
-		Timestamp time.Time
-	}{
-		Body:      body,
-		Timestamp: timestamp,
-		User:      update.Message.From.FirstName + " " + update.Message.From.LastName,
-	})
+	logger := slog.With("update_id", update.UpdateID, "chat_id", chatID, "tg_user_id", tgUserID)
+	logger.Debug("Received message",
+		"name", update.Message.From.FirstName+" "+update.Message.From.LastName,
+		"body", body,
+		"sent_at", timestamp,
+	)
 
 	/**
-	 * Validate the message: non-empty and within length limits (160 chars).
+	 * Group chats are shared with other conversations, so only commands are handled there.
 	 */
+	if inGroup && !strings.HasPrefix(body, "!") {
+		return nil
+	}
+
+	/**
+	 * Validate the message: non-empty and within length limits (50 lines of 160 chars).
+	 */
+	// Until the user is known, reply in the language of their Telegram app.
+	lang := userLanguage(nil, update.Message.From)
+
 	if !validateMessage(body) {
-		bot.Send(telegramClient.NewMessage(tgUserID, "⚠️ Message cannot be empty or exceed 160 characters."))
-		return
+		outbox.Send(telegramClient.NewMessage(chatID, lang.Tf("⚠️ Message cannot be empty, exceed %d lines or %d characters per line.", maxLines, maxLineLength)))
+		return nil
+	}
+
+	/**
+	 * Turn away whoever isn't allowed in, before they become a user.
+	 */
+	invite, rejection, err := admit(repos, update.Message.From, body, lang)
+	if err != nil {
+		return fmt.Errorf("failed to check access: %w", err)
+	}
+	if rejection != "" {
+		logger.Warn("Rejected message", "access_mode", access.mode)
+		outbox.Send(telegramClient.NewMessage(chatID, rejection))
+		return nil
 	}
 
 	/**
 	 * Validate or create user.
 	 */
-	user, err := r.UserRepo().GetOrCreate(tgUserID, update.Message.From)
+	user, err := repos.UserRepo().GetOrCreate(tgUserID, update.Message.From)
 	if err != nil {
-		log.Printf("⚠️ Error getting user: %s", err)
-		bot.Send(telegramClient.NewMessage(tgUserID, "⚠️ Failed to fetch or create user profile. Please try again later."))
-		return
+		return fmt.Errorf("failed to fetch or create user: %w", err)
+	}
+	lang = userLanguage(user, update.Message.From)
+	numbers := userNumberFormat(user, update.Message.From)
+
+	// The join request was all there was to the message.
+	if invite != nil {
+		if err := repos.InviteRepo().MarkUsed(invite, user.ID, time.Now()); err != nil {
+			return fmt.Errorf("failed to use invite: %w", err)
+		}
+		logger.Info("User joined with an invite", "invite_id", invite.ID)
+		outbox.Send(telegramClient.NewMessage(chatID, lang.T("🎉 Welcome! Send !help to get started.")))
+		return nil
+	}
+
+	loc := userLocation(user)
+	ctx := MessageContext{UserID: user.ID, Timestamp: timestamp.In(loc), Attachments: messageAttachments(update.Message), Repos: repos, Admin: isAdmin(tgUserID), Language: lang, Numbers: numbers, Log: logger, Outbox: outbox}
+
+	/**
+	 * Validate or create the group ledger, and keep track of its members.
+	 */
+	if inGroup {
+		group, err := repos.GroupRepo().GetOrCreate(chatID, update.Message.Chat.Title)
+		if err == nil {
+			err = repos.GroupRepo().AddMember(group.ID, user.ID)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to fetch or create group ledger: %w", err)
+		}
+		ctx.Group = group
 	}
 
 	/**
 	 * If it has a command, dispatch it accordingly.
 	 */
 	if cmd, ok := strings.CutPrefix(body, "!"); ok {
-		result := dispatch(cmd, timestamp, user.ID)
+		result := localiseTimes(dispatch(cmd, ctx), loc)
+		if err := commandFailure(result); err != nil {
+			return err
+		}
+		outbox.Then(func() { commandHandled(result) })
 		if result.Error != nil {
-			log.Printf("⚠️ Error processing command: %s", result.Error)
-			bot.Send(telegramClient.NewMessage(tgUserID, fmt.Sprintf("⚠️ Failed to process command: %s", result.UserError)))
-			return
+			logger.Warn("Command failed", "command", result.Command, "error", result.Error)
+			outbox.Send(telegramClient.NewMessage(chatID, lang.Tf("⚠️ Failed to process command: %s", lang.T(result.UserError))))
+			return nil
+		}
+		logger.Info("Processed command", "command", result.Command, "transactions", len(result.Transactions))
+		sendText(outbox, chatID, generateSuccessMessage(result, lang, numbers))
+		outbox.Then(func() { sendAttachments(outbox.bot, chatID, result.Attachments) })
+		if result.Broadcast != nil {
+			broadcast := *result.Broadcast
+			outbox.Then(func() { startBroadcast(outbox.bot, chatID, broadcast) })
 		}
-		log.Printf("✅ Processed command: %+v", result)
-		bot.Send(telegramClient.NewMessage(tgUserID, generateSuccessMessage(result)))
-		return
+		return nil
 	}
 
 	/**
@@ -78,12 +154,155 @@ func HandleTelegramMessage(bot *telegramClient.BotAPI, update telegramClient.Upd
 	 * This is because I like the simplicity of being able to do: $ 45
 	 * Design-wise, is it crap or is it not? I don't care. Might make it a command-only later.
 	 */
-	result := add(body, timestamp, user.ID)
+	result := localiseTimes(add(body, ctx), loc)
+	if err := commandFailure(result); err != nil {
+		return err
+	}
+	outbox.Then(func() { commandHandled(result) })
+	if result.Error != nil {
+		logger.Warn("Command failed", "command", result.Command, "error", result.Error)
+		outbox.Send(telegramClient.NewMessage(chatID, lang.Tf("⚠️ Failed to process command: \n%s", lang.T(result.UserError))))
+		return nil
+	}
+	logger.Info("Processed command", "command", result.Command, "transactions", len(result.Transactions))
+	sendText(outbox, chatID, generateSuccessMessage(result, lang, numbers))
+	return nil
+}
+
+/**
+ * A command that failed through no fault of the user (e.g. the database was
+ * unreachable) is rolled back and tried again, rather than replied to. Any
+ * other failure would happen again, so it's replied to straight away.
+ */
+func commandFailure(result CommandResult) error {
+	if !errors.Is(result.Error, ErrTransient) {
+		return nil
+	}
+	return &commandError{command: result.Command, err: result.Error}
+}
+
+type commandError struct {
+	command Command
+	err     error
+}
+
+func (e *commandError) Error() string {
+	return fmt.Sprintf("command %s failed: %v", e.command, e.err)
+}
+
+func (e *commandError) Unwrap() error {
+	return e.err
+}
+
+// Commands are counted once handled for good, those given up on in processUpdate.
+func commandHandled(result CommandResult) {
+	outcome := "success"
 	if result.Error != nil {
-		log.Printf("⚠️ Error processing add command: %s", result.Error)
-		bot.Send(telegramClient.NewMessage(tgUserID, fmt.Sprintf("⚠️ Failed to process command: \n%s", result.UserError)))
-		return
+		outcome = "user_error"
+	}
+	monitoring.CommandHandled(string(result.Command), outcome)
+}
+
+/**
+ * Handle a tap on a button sent by the bot, which so far only bill
+ * reminders have. Telegram is always answered, so the button stops
+ * spinning, with a short notice for the user.
+ */
+func HandleCallbackQuery(outbox *Outbox, update telegramClient.Update, repos *r.Repositories) error {
+	query := update.CallbackQuery
+	logger := slog.With("update_id", update.UpdateID, "tg_user_id", query.From.ID)
+
+	// Buttons are only sent to users, who may have been blocked since.
+	user, err := repos.UserRepo().GetByTelegramID(query.From.ID)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		return fmt.Errorf("failed to fetch user: %w", err)
+	}
+	if err != nil || user.Blocked {
+		logger.Warn("Rejected button")
+		outbox.Send(telegramClient.NewCallback(query.ID, ""))
+		return nil
+	}
+
+	notice := ""
+	switch action, data, _ := strings.Cut(query.Data, ":"); action {
+	case billPaidAction:
+		notice, err = payBillFromButton(outbox, query, data, user, repos)
+	default:
+		logger.Warn("Unknown button", "button", query.Data)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to handle button: %w", err)
+	}
+
+	logger.Info("Handled button", "button", query.Data)
+	outbox.Send(telegramClient.NewCallback(query.ID, notice))
+	return nil
+}
+
+// Longest text Telegram accepts in a single message.
+const maxReplyLength = 4096
+
+/**
+ * Send a reply, split over several messages on line breaks when it's too
+ * long for a single one (e.g. a batch of transactions).
+ */
+func sendText(outbox *Outbox, chatID int64, text string) {
+	chunk := ""
+	for _, line := range strings.SplitAfter(text, "\n") {
+		if len(chunk)+len(line) > maxReplyLength && chunk != "" {
+			outbox.Send(telegramClient.NewMessage(chatID, chunk))
+			chunk = ""
+		}
+		chunk += line
+	}
+	if strings.TrimSpace(chunk) != "" {
+		outbox.Send(telegramClient.NewMessage(chatID, chunk))
+	}
+}
+
+/**
+ * Collect the photo or document sent along with a message.
+ * Telegram sends each photo in several sizes, keep the largest one.
+ */
+func messageAttachments(message *telegramClient.Message) []Attachment {
+	attachments := []Attachment{}
+
+	if n := len(message.Photo); n > 0 {
+		photo := message.Photo[n-1]
+		attachments = append(attachments, Attachment{
+			Kind:         AttachmentPhoto,
+			FileID:       photo.FileID,
+			FileUniqueID: photo.FileUniqueID,
+		})
+	}
+
+	if document := message.Document; document != nil {
+		attachments = append(attachments, Attachment{
+			Kind:         AttachmentDocument,
+			FileID:       document.FileID,
+			FileUniqueID: document.FileUniqueID,
+			FileName:     document.FileName,
+			MimeType:     document.MimeType,
+		})
+	}
+
+	return attachments
+}
+
+/**
+ * Send stored files back to the chat, using their Telegram file IDs.
+ */
+func sendAttachments(bot *telegramClient.BotAPI, chatID int64, attachments []*Attachment) {
+	for _, attachment := range attachments {
+		var file telegramClient.Chattable
+		if attachment.Kind == AttachmentPhoto {
+			file = telegramClient.NewPhoto(chatID, telegramClient.FileID(attachment.FileID))
+		} else {
+			file = telegramClient.NewDocument(chatID, telegramClient.FileID(attachment.FileID))
+		}
+
+		if _, err := bot.Send(file); err != nil {
+			slog.Error("Error sending attachment", "attachment_id", attachment.ID, "chat_id", chatID, "error", err)
+		}
 	}
-	log.Printf("✅ Processed command: %+v", result)
-	bot.Send(telegramClient.NewMessage(tgUserID, generateSuccessMessage(result)))
 }
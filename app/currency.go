@@ -1,39 +1,248 @@
 package app
 
 import (
+	_ "embed"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"log/slog"
+	"math/big"
+	"regexp"
+	. "remind0/db"
+	r "remind0/repository"
+	"strconv"
 	"strings"
+	"sync"
 )
 
-// Supported major currencies (ISO 4217 codes)
-var supportedCurrencies = map[string]string{
-	"ARS": "Argentine Peso",
-	"NZD": "New Zealand Dollar",
-	"USD": "US Dollar",
-	"EUR": "Euro",
-	"AUD": "Australian Dollar",
-	"JPY": "Japanese Yen",
-	"BRL": "Brazilian Real",
-	"GBP": "British Pound",
-	"CAD": "Canadian Dollar",
-	"CHF": "Swiss Franc",
-	"CNY": "Chinese Yuan",
-	"INR": "Indian Rupee",
-	"MXN": "Mexican Peso",
-	"ZAR": "South African Rand",
-	"SEK": "Swedish Krona",
-	"NOK": "Norwegian Krone",
-	"DKK": "Danish Krone",
-	"SGD": "Singapore Dollar",
-	"HKD": "Hong Kong Dollar",
-	"KRW": "South Korean Won",
-	"RUB": "Russian Ruble",
-	"TRY": "Turkish Lira",
-	"PLN": "Polish Zloty",
-	"THB": "Thai Baht",
-	"MYR": "Malaysian Ringgit",
+/**
+ * Currencies amounts can be recorded in: every ISO 4217 currency, from the
+ * table embedded in the binary, plus the custom ones users define (e.g.
+ * BTC or airline miles), which every user can then use.
+ */
+
+//go:embed iso4217.csv
+var iso4217Table string
+
+type CurrencyInfo struct {
+	Code     string
+	Name     string
+	Symbol   string // Shown next to amounts, the code if there's no symbol
+	Decimals int    // Digits of the minor unit (e.g. 2 for cents)
+	Custom   bool   // Whether a user defined it
+}
+
+var isoCurrencies = parseISOCurrencies(iso4217Table)
+
+/**
+ * Parse the ISO 4217 table: code, minor units, symbol and name. It's part
+ * of the binary, so a malformed table is a bug.
+ */
+func parseISOCurrencies(table string) map[string]CurrencyInfo {
+	records, err := csv.NewReader(strings.NewReader(table)).ReadAll()
+	if err != nil {
+		panic(fmt.Sprintf("invalid ISO 4217 table: %v", err))
+	}
+
+	currencies := make(map[string]CurrencyInfo, len(records))
+	for _, record := range records[1:] { // Skip the header
+		decimals, err := strconv.Atoi(record[1])
+		if err != nil {
+			panic(fmt.Sprintf("invalid minor units for %s: %v", record[0], err))
+		}
+		currencies[record[0]] = CurrencyInfo{Code: record[0], Decimals: decimals, Symbol: record[2], Name: record[3]}
+	}
+	return currencies
+}
+
+/**
+ * Custom currencies, kept in memory as amounts are read and shown all over.
+ * Loaded at start-up and added to as users define them.
+ */
+var customCurrencies = struct {
+	sync.RWMutex
+	byCode map[string]CurrencyInfo
+}{byCode: map[string]CurrencyInfo{}}
+
+func LoadCustomCurrencies(repo r.ICurrencyRepository) error {
+	currencies, err := repo.GetAll()
+	if err != nil {
+		return err
+	}
+	for _, currency := range currencies {
+		registerCustomCurrency(currency)
+	}
+	slog.Info("Custom currencies loaded", "count", len(currencies))
+	return nil
+}
+
+func registerCustomCurrency(currency *CustomCurrency) {
+	customCurrencies.Lock()
+	defer customCurrencies.Unlock()
+	customCurrencies.byCode[currency.Code] = CurrencyInfo{Code: currency.Code, Name: currency.Name, Decimals: currency.Decimals, Custom: true}
+}
+
+func unregisterCustomCurrency(code string) {
+	customCurrencies.Lock()
+	defer customCurrencies.Unlock()
+	delete(customCurrencies.byCode, code)
+}
+
+func lookupCurrency(code string) (CurrencyInfo, bool) {
+	code = strings.ToUpper(code)
+	if currency, exists := isoCurrencies[code]; exists {
+		return currency, true
+	}
+
+	customCurrencies.RLock()
+	defer customCurrencies.RUnlock()
+	currency, exists := customCurrencies.byCode[code]
+	return currency, exists
 }
 
 func isValidCurrency(code string) bool {
-	_, exists := supportedCurrencies[strings.ToUpper(code)]
+	_, exists := lookupCurrency(code)
 	return exists
 }
+
+func currencyName(code string) string {
+	currency, _ := lookupCurrency(code)
+	return currency.Name
+}
+
+func currencySymbol(code string) string {
+	if currency, exists := lookupCurrency(code); exists && currency.Symbol != "" {
+		return currency.Symbol
+	}
+	return strings.ToUpper(code)
+}
+
+/**
+ * Amounts are stored as whole numbers of the currency's minor unit (e.g.
+ * cents), so they add up exactly.
+ */
+
+// Number of decimals of a currency's minor unit, 2 if it's unknown.
+func currencyExponent(code string) int {
+	if currency, exists := lookupCurrency(code); exists {
+		return currency.Decimals
+	}
+	return 2
+}
+
+// Number of minor units in one unit of a currency (e.g. 100 cents in a dollar).
+func minorUnitsPerUnit(code string) *big.Int {
+	return new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(currencyExponent(code))), nil)
+}
+
+// Convert an exact value to minor units, rounding half away from zero.
+// Fails when there are too many to store.
+func toMinorUnits(value *big.Rat, currency string) (int64, error) {
+	scaled := new(big.Rat).Mul(value, new(big.Rat).SetInt(minorUnitsPerUnit(currency)))
+	rounded, _ := new(big.Rat).SetString(scaled.FloatString(0))
+	if !rounded.Num().IsInt64() {
+		return 0, fmt.Errorf("amount %s %s is too large", value.FloatString(currencyExponent(currency)), currency)
+	}
+	return rounded.Num().Int64(), nil
+}
+
+var moneyPattern = regexp.MustCompile(`^[+-]?[\d.,']*\d[\d.,']*$`)
+
+// Parse a plain number (e.g. 12.50, 12,50 or 1.234,50) into minor units.
+func parseMoney(amount string, currency string, numbers NumberFormat) (int64, error) {
+	if !moneyPattern.MatchString(amount) {
+		return 0, fmt.Errorf("invalid amount %q", amount)
+	}
+	value, err := numbers.parseNumber(strings.TrimLeft(amount, "+-"))
+	if err != nil {
+		return 0, fmt.Errorf("invalid amount %q: %w", amount, err)
+	}
+	if strings.HasPrefix(amount, "-") {
+		value.Neg(value)
+	}
+	return toMinorUnits(value, currency)
+}
+
+// Format minor units with the currency's number of decimals (e.g. 1250 USD is 12.50, 1250 JPY is 1250).
+func formatMoney(amount int64, currency string) string {
+	value := new(big.Rat).SetFrac(big.NewInt(amount), minorUnitsPerUnit(currency))
+	return value.FloatString(currencyExponent(currency))
+}
+
+// Custom currency codes are referenced as $CODE, so keep them short and simple (e.g. BTC, MILES).
+var customCodePattern = regexp.MustCompile(`^[A-Z][A-Z0-9]{1,7}$`)
+
+// Most decimals a custom currency can have, amounts must still fit in minor units.
+const maxCustomDecimals = 8
+
+/**
+ * Manage custom currencies.
+ * Format: add <CODE> <decimals> <name> | rm <CODE> | list
+ */
+func currencies(args []string, ctx MessageContext) CommandResult {
+	if len(args) == 0 || args[0] == "list" || args[0] == "ls" {
+		return CommandResult{Command: Currencies, UserInfo: getCurrenciesListMessage(ctx.Language)}
+	}
+
+	switch args[0] {
+	case "add":
+		return addCurrency(args[1:], ctx)
+	case "rm", "remove":
+		return removeCurrency(args[1:], ctx)
+	default:
+		return CommandResult{Command: Currencies, Error: fmt.Errorf("unknown currency action: %s", args[0]), UserError: userErrors[Currencies]}
+	}
+}
+
+func addCurrency(args []string, ctx MessageContext) CommandResult {
+	if len(args) < 3 {
+		return CommandResult{Command: Currencies, Error: fmt.Errorf("missing arguments"), UserError: userErrors[Currencies]}
+	}
+
+	code := strings.ToUpper(args[0])
+	decimals, err := strconv.Atoi(args[1])
+	name := strings.Join(args[2:], " ")
+	if !customCodePattern.MatchString(code) || err != nil || decimals < 0 || decimals > maxCustomDecimals || len(name) > 40 {
+		return CommandResult{Command: Currencies, Error: fmt.Errorf("invalid currency: %v", args), UserError: userErrors[Currencies]}
+	}
+
+	// Checked against the database, the registry only catches up once committed.
+	if _, isISO := isoCurrencies[code]; isISO {
+		return CommandResult{Command: Currencies, Error: fmt.Errorf("%s is an ISO currency", code), UserError: "That currency already exists."}
+	}
+	if _, err := ctx.Repos.CurrencyRepo().GetByCode(code); err == nil {
+		return CommandResult{Command: Currencies, Error: fmt.Errorf("%s already defined", code), UserError: "That currency already exists."}
+	}
+
+	currency := &CustomCurrency{Code: code, Name: name, Decimals: decimals, CreatedByID: ctx.UserID}
+	if err := ctx.Repos.CurrencyRepo().Create(currency); err != nil {
+		return CommandResult{Command: Currencies, Error: err, UserError: userErrors[Unknown]}
+	}
+	ctx.Outbox.Then(func() { registerCustomCurrency(currency) })
+
+	return CommandResult{Command: Currencies, UserInfo: ctx.Language.Tf("✅ Added %s (%s) with %d decimals, use it with $%s", code, name, decimals, code)}
+}
+
+func removeCurrency(args []string, ctx MessageContext) CommandResult {
+	if len(args) != 1 {
+		return CommandResult{Command: Currencies, Error: fmt.Errorf("expected a single currency"), UserError: userErrors[Currencies]}
+	}
+
+	currency, err := ctx.Repos.CurrencyRepo().GetByCode(strings.ToUpper(args[0]))
+	if err != nil {
+		return CommandResult{Command: Currencies, Error: err, UserError: userErrors[Currencies]}
+	}
+	if currency.CreatedByID != ctx.UserID && !ctx.Admin {
+		return CommandResult{Command: Currencies, Error: fmt.Errorf("not the creator of %s", currency.Code), UserError: "Only whoever added a currency, or an admin, can remove it."}
+	}
+
+	if err := ctx.Repos.CurrencyRepo().Delete(currency); err != nil {
+		if errors.Is(err, r.ErrCurrencyInUse) {
+			return CommandResult{Command: Currencies, Error: err, UserError: "Currencies in use can't be removed."}
+		}
+		return CommandResult{Command: Currencies, Error: err, UserError: userErrors[Unknown]}
+	}
+	ctx.Outbox.Then(func() { unregisterCustomCurrency(currency.Code) })
+
+	return CommandResult{Command: Currencies, UserInfo: ctx.Language.Tf("✅ Removed %s", currency.Code)}
+}
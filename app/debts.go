@@ -0,0 +1,246 @@
+package app
+
+import (
+	"fmt"
+	. "remind0/db"
+	"sort"
+	"strings"
+)
+
+/**
+ *      _      _     _
+ *     | |    | |   | |
+ *   __| | ___| |__ | |_ ___
+ *  / _` |/ _ \ '_ \| __/ __|
+ * | (_| |  __/ |_) | |_\__ \
+ *  \__,_|\___|_.__/ \__|___/
+ *
+ * Personal IOUs with people who don't need to use the bot.
+ */
+
+type DebtBalance struct {
+	Counterparty string
+	Currency     string
+	Outstanding  int64 // In minor units, positive when the counterparty owes the user.
+	Open         int   // Number of debts not fully repaid yet.
+}
+
+// Amount of a debt that hasn't been repaid yet, in minor units.
+func outstandingAmount(debt *Debt) int64 {
+	amount := debt.Amount
+	for _, repayment := range debt.Repayments {
+		amount -= repayment.Amount
+	}
+	return amount
+}
+
+// Signed outstanding amount, positive when the counterparty owes the user.
+func signedOutstandingAmount(debt *Debt) int64 {
+	if debt.Kind == DebtBorrowed {
+		return -outstandingAmount(debt)
+	}
+	return outstandingAmount(debt)
+}
+
+func normaliseCounterparty(name string) string {
+	return strings.ToLower(strings.TrimPrefix(name, "@"))
+}
+
+/**
+ * Record money lent to or borrowed from someone.
+ * Format: <person> <amount> <notes?> $<currency?>
+ */
+func recordDebt(kind DebtKind, args []string, ctx MessageContext) CommandResult {
+	command := Lend
+	if kind == DebtBorrowed {
+		command = Borrow
+	}
+
+	if len(args) < 2 {
+		return CommandResult{Command: command, Error: fmt.Errorf("missing arguments"), UserError: userErrors[command]}
+	}
+
+	user, err := ctx.Repos.UserRepo().GetByID(ctx.UserID)
+	if err != nil {
+		return CommandResult{Command: command, Error: err, UserError: userErrors[Unknown]}
+	}
+
+	notesParts, currency := extractCurrency(args[2:], user.PreferredCurrency)
+
+	counterparty := normaliseCounterparty(args[0])
+	amount, err := parseMoney(args[1], currency, ctx.Numbers)
+	if counterparty == "" || err != nil || amount <= 0 {
+		return CommandResult{Command: command, Error: fmt.Errorf("invalid debt %v: %v", args, err), UserError: userErrors[command]}
+	}
+
+	debt := &Debt{
+		UserID:       ctx.UserID,
+		Counterparty: counterparty,
+		Kind:         kind,
+		Amount:       amount,
+		Currency:     currency,
+		Notes:        strings.Join(notesParts, " "),
+		Timestamp:    ctx.Timestamp,
+	}
+	if err := ctx.Repos.DebtRepo().Create(debt); err != nil {
+		return CommandResult{Command: command, Error: err, UserError: userErrors[Unknown]}
+	}
+
+	return debtSummary([]string{counterparty}, ctx, command)
+}
+
+/**
+ * Record a repayment towards the open debts with someone, oldest first.
+ * Repayments follow the direction of the debts: if they owe the user, it's
+ * them paying back, otherwise it's the user paying them back. When a category
+ * is given, the money movement is also recorded as a transaction.
+ * Format: <person> <amount> <category?> $<currency?>
+ */
+func repay(args []string, ctx MessageContext) CommandResult {
+	if len(args) < 2 || len(args) > 4 {
+		return CommandResult{Command: Repay, Error: fmt.Errorf("invalid arguments: %v", args), UserError: userErrors[Repay]}
+	}
+
+	user, err := ctx.Repos.UserRepo().GetByID(ctx.UserID)
+	if err != nil {
+		return CommandResult{Command: Repay, Error: err, UserError: userErrors[Unknown]}
+	}
+
+	rest, currency := extractCurrency(args[2:], user.PreferredCurrency)
+
+	counterparty := normaliseCounterparty(args[0])
+	amount, err := parseMoney(args[1], currency, ctx.Numbers)
+	if err != nil || amount <= 0 {
+		return CommandResult{Command: Repay, Error: fmt.Errorf("invalid amount %q", args[1]), UserError: userErrors[Repay]}
+	}
+
+	category := ""
+	if len(rest) == 1 {
+		name, found := findCategory(rest[0])
+		if !found {
+			return CommandResult{Command: Repay, Error: fmt.Errorf("invalid category %q", rest[0]), UserError: userErrors[Repay]}
+		}
+		category = name
+	} else if len(rest) > 1 {
+		return CommandResult{Command: Repay, Error: fmt.Errorf("invalid arguments: %v", rest), UserError: userErrors[Repay]}
+	}
+
+	debts, err := ctx.Repos.DebtRepo().GetManyByCounterparty(ctx.UserID, counterparty, currency)
+	if err != nil {
+		return CommandResult{Command: Repay, Error: err, UserError: userErrors[Unknown]}
+	}
+
+	var net int64
+	for _, debt := range debts {
+		net += signedOutstandingAmount(debt)
+	}
+	if net == 0 {
+		return CommandResult{Command: Repay, Error: fmt.Errorf("nothing owed with %s in %s", counterparty, currency), UserError: userErrors[Repay]}
+	}
+
+	kind := DebtLent
+	if net < 0 {
+		kind = DebtBorrowed
+	}
+
+	/**
+	 * Spread the repayment over the open debts, oldest first.
+	 */
+	remaining := amount
+	repayments := []*DebtRepayment{}
+	record := "repay"
+	for _, debt := range debts {
+		outstanding := outstandingAmount(debt)
+		if debt.Kind != kind || outstanding <= 0 || remaining == 0 {
+			continue
+		}
+		repaid := min(outstanding, remaining)
+		repayments = append(repayments, &DebtRepayment{DebtID: debt.ID, Amount: repaid, Timestamp: ctx.Timestamp})
+		record += fmt.Sprintf(" %d:%d", debt.ID, outstanding)
+		remaining -= repaid
+	}
+	if remaining > 0 {
+		return CommandResult{Command: Repay, Error: fmt.Errorf("repayment exceeds outstanding debts by %s", formatMoney(remaining, currency)), UserError: userErrors[Repay]}
+	}
+
+	var tx *Transaction
+	if category != "" {
+		notes := "Repayment to " + counterparty
+		if kind == DebtLent {
+			notes = "Repayment from " + counterparty
+		}
+		tx = &Transaction{
+			Hash:      generateRecordHash(record, category, amount, notes, ctx.Timestamp, ctx.UserID, currency),
+			Notes:     notes,
+			UserID:    ctx.UserID,
+			Amount:    amount,
+			Currency:  currency,
+			Category:  category,
+			Timestamp: ctx.Timestamp,
+		}
+	}
+
+	if err := ctx.Repos.DebtRepo().Repay(repayments, tx, SourceChat); err != nil {
+		return CommandResult{Command: Repay, Error: err, UserError: userErrors[Unknown]}
+	}
+
+	result := debtSummary([]string{counterparty}, ctx, Repay)
+	if tx != nil {
+		result.Transactions = []*Transaction{tx}
+	}
+	return result
+}
+
+/**
+ * Summarise outstanding balances per person and currency,
+ * optionally for a single person.
+ */
+func debtSummary(args []string, ctx MessageContext, command Command) CommandResult {
+	if len(args) > 1 {
+		return CommandResult{Command: command, Error: fmt.Errorf("too many arguments"), UserError: userErrors[Debts]}
+	}
+
+	debts, err := ctx.Repos.DebtRepo().GetAll(ctx.UserID)
+	if err != nil {
+		return CommandResult{Command: command, Error: err, UserError: userErrors[Unknown]}
+	}
+
+	filter := ""
+	if len(args) == 1 {
+		filter = normaliseCounterparty(args[0])
+	}
+
+	type key struct{ counterparty, currency string }
+	totals := map[key]*DebtBalance{}
+
+	for _, debt := range debts {
+		if filter != "" && debt.Counterparty != filter {
+			continue
+		}
+		k := key{debt.Counterparty, debt.Currency}
+		if totals[k] == nil {
+			totals[k] = &DebtBalance{Counterparty: debt.Counterparty, Currency: debt.Currency}
+		}
+		totals[k].Outstanding += signedOutstandingAmount(debt)
+		if outstandingAmount(debt) > 0 {
+			totals[k].Open++
+		}
+	}
+
+	balances := []DebtBalance{}
+	for _, balance := range totals {
+		if balance.Outstanding == 0 && balance.Open == 0 {
+			continue
+		}
+		balances = append(balances, *balance)
+	}
+
+	sort.Slice(balances, func(i, j int) bool {
+		if balances[i].Counterparty != balances[j].Counterparty {
+			return balances[i].Counterparty < balances[j].Counterparty
+		}
+		return balances[i].Currency < balances[j].Currency
+	})
+
+	return CommandResult{Command: command, Debts: balances}
+}
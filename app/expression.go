@@ -0,0 +1,172 @@
+package app
+
+import (
+	"fmt"
+	"math/big"
+)
+
+/**
+ *                                    _
+ *                                   (_)
+ *   _____  ___ __  _ __ ___  ___ ___ _  ___  _ __  ___
+ *  / _ \ \/ / '_ \| '__/ _ \/ __/ __| |/ _ \| '_ \/ __|
+ * |  __/>  <| |_) | | |  __/\__ \__ \ | (_) | | | \__ \
+ *  \___/_/\_\ .__/|_|  \___||___/___/_|\___/|_| |_|___/
+ *           | |
+ *           |_|
+ *
+ * Arithmetic in amounts, e.g. 12.5+8*2, 100/3 or 45-5%.
+ *
+ * Grammar:
+ *   expression := term (('+' | '-') term)*
+ *   term       := unary (('*' | '/') unary)*
+ *   unary      := ('+' | '-') unary | primary '%'?
+ *   primary    := number | '(' expression ')'
+ *
+ * A percentage added to or subtracted from something is a percentage of
+ * it (45-5% is 42.75), anywhere else it's a plain fraction (200*5% is 10).
+ * Everything is worked out with exact fractions, so no rounding happens
+ * until the result is turned into an amount.
+ */
+
+// Longest expression accepted, to keep messages reasonable.
+const maxExpressionLength = 64
+
+type expressionParser struct {
+	input   string
+	pos     int
+	numbers NumberFormat // Separators numbers are written with.
+}
+
+func evaluateExpression(input string, numbers NumberFormat) (*big.Rat, error) {
+	if input == "" || len(input) > maxExpressionLength {
+		return nil, fmt.Errorf("expression must have between 1 and %d characters", maxExpressionLength)
+	}
+
+	p := &expressionParser{input: input, numbers: numbers}
+	value, _, err := p.expression()
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.input) {
+		return nil, fmt.Errorf("unexpected %q at position %d", p.input[p.pos], p.pos+1)
+	}
+	return value, nil
+}
+
+func (p *expressionParser) peek() byte {
+	if p.pos < len(p.input) {
+		return p.input[p.pos]
+	}
+	return 0
+}
+
+func (p *expressionParser) expression() (*big.Rat, bool, error) {
+	left, isPercent, err := p.term()
+	if err != nil {
+		return nil, false, err
+	}
+
+	for op := p.peek(); op == '+' || op == '-'; op = p.peek() {
+		p.pos++
+		right, rightIsPercent, err := p.term()
+		if err != nil {
+			return nil, false, err
+		}
+
+		// A percentage on the right-hand side is relative to the left-hand side.
+		if rightIsPercent {
+			right = new(big.Rat).Mul(left, right)
+		}
+
+		if op == '+' {
+			left = new(big.Rat).Add(left, right)
+		} else {
+			left = new(big.Rat).Sub(left, right)
+		}
+		isPercent = false
+	}
+
+	return left, isPercent, nil
+}
+
+func (p *expressionParser) term() (*big.Rat, bool, error) {
+	left, isPercent, err := p.unary()
+	if err != nil {
+		return nil, false, err
+	}
+
+	for op := p.peek(); op == '*' || op == '/'; op = p.peek() {
+		p.pos++
+		right, _, err := p.unary()
+		if err != nil {
+			return nil, false, err
+		}
+
+		if op == '*' {
+			left = new(big.Rat).Mul(left, right)
+		} else {
+			if right.Sign() == 0 {
+				return nil, false, fmt.Errorf("division by zero")
+			}
+			left = new(big.Rat).Quo(left, right)
+		}
+		isPercent = false
+	}
+
+	return left, isPercent, nil
+}
+
+func (p *expressionParser) unary() (*big.Rat, bool, error) {
+	switch p.peek() {
+	case '-':
+		p.pos++
+		value, isPercent, err := p.unary()
+		if err != nil {
+			return nil, false, err
+		}
+		return new(big.Rat).Neg(value), isPercent, nil
+	case '+':
+		p.pos++
+		return p.unary()
+	}
+
+	value, err := p.primary()
+	if err != nil {
+		return nil, false, err
+	}
+
+	if p.peek() == '%' {
+		p.pos++
+		return new(big.Rat).Quo(value, big.NewRat(100, 1)), true, nil
+	}
+	return value, false, nil
+}
+
+func (p *expressionParser) primary() (*big.Rat, error) {
+	if p.peek() == '(' {
+		p.pos++
+		value, _, err := p.expression()
+		if err != nil {
+			return nil, err
+		}
+		if p.peek() != ')' {
+			return nil, fmt.Errorf("missing closing parenthesis")
+		}
+		p.pos++
+		return value, nil
+	}
+
+	start := p.pos
+	for c := p.peek(); (c >= '0' && c <= '9') || c == '.' || c == ',' || c == '\''; c = p.peek() {
+		p.pos++
+	}
+	if start == p.pos {
+		if p.pos >= len(p.input) {
+			return nil, fmt.Errorf("unexpected end of expression")
+		}
+		return nil, fmt.Errorf("unexpected %q at position %d", p.input[p.pos], p.pos+1)
+	}
+
+	return p.numbers.parseNumber(p.input[start:p.pos])
+}
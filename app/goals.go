@@ -0,0 +1,254 @@
+package app
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	. "remind0/db"
+	"strings"
+	"time"
+)
+
+/**
+ *                    _
+ *   __ _  ___   __ _| |___
+ *  / _` |/ _ \ / _` | / __|
+ * | (_| | (_) | (_| | \__ \
+ *  \__, |\___/ \__,_|_|___/
+ *  |___/
+ *
+ * What savings are for: a target to reach, maybe by a deadline, and the
+ * savings transactions marked with the goal (e.g. !add S 200 #house)
+ * counting towards it.
+ */
+
+// Goal names are referenced as #name, so keep them to a single simple word.
+var goalNamePattern = regexp.MustCompile(`^[a-z][a-z0-9_-]{0,19}$`)
+
+const (
+	// Shortest saving history a projection is made from, so a first deposit doesn't promise the moon.
+	minProjectionWindow = 30 * 24 * time.Hour
+	// Longest wait projected, past it the goal is as good as out of reach.
+	maxProjection = 100 * 365 * 24 * time.Hour
+)
+
+type GoalProgress struct {
+	Name      string
+	Currency  string
+	Target    int64 // In minor units of the currency.
+	Saved     int64 // In minor units of the currency.
+	Deadline  *time.Time
+	Monthly   int64      // Needed every month to reach the target by the deadline.
+	Projected *time.Time // When the target is reached at the pace so far, nil when unknown.
+}
+
+func goalID(goal *Goal) *uint {
+	if goal == nil {
+		return nil
+	}
+	return &goal.ID
+}
+
+// Name of the category savings are recorded in.
+func savingsCategory() string {
+	name, _ := findCategory("S")
+	return name
+}
+
+/**
+ * Look up the goal marked in a transaction, if any. Goals have a single
+ * currency, so unmarked amounts follow the goal's currency and others
+ * are rejected, as are accounts holding another currency.
+ */
+func resolveGoal(ctx MessageContext, parsed ParsedTx, currency string) (*Goal, string, error) {
+	if parsed.Goal == "" {
+		return nil, currency, nil
+	}
+
+	goal, err := ctx.Repos.GoalRepo().GetByName(ctx.UserID, parsed.Goal)
+	if err != nil {
+		return nil, "", fmt.Errorf("goal %q not found: %w", parsed.Goal, err)
+	}
+
+	if (parsed.ExplicitCurrency || parsed.Account != "") && currency != goal.Currency {
+		return nil, "", fmt.Errorf("goal %q is in %s, not %s", goal.Name, goal.Currency, currency)
+	}
+
+	return goal, goal.Currency, nil
+}
+
+/**
+ * Manage savings goals: add, remove or list them along with their progress.
+ */
+func goals(args []string, ctx MessageContext) CommandResult {
+	if len(args) == 0 {
+		return goalsProgress(ctx)
+	}
+
+	switch action := args[0]; action {
+	case "add", "a", "new":
+		return addGoal(args[1:], ctx)
+	case "remove", "rm", "delete", "del":
+		return removeGoal(args[1:], ctx)
+	case "list", "ls", "l":
+		return goalsProgress(ctx)
+	default:
+		return CommandResult{Command: Goals, Error: fmt.Errorf("unknown goal action: %s", action), UserError: userErrors[Goals]}
+	}
+}
+
+/**
+ * Format: <name> <target> <deadline?> $<currency?>
+ */
+func addGoal(args []string, ctx MessageContext) CommandResult {
+	if len(args) < 2 || len(args) > 4 {
+		return CommandResult{Command: Goals, Error: fmt.Errorf("invalid arguments: %v", args), UserError: userErrors[Goals]}
+	}
+
+	user, err := ctx.Repos.UserRepo().GetByID(ctx.UserID)
+	if err != nil {
+		return CommandResult{Command: Goals, Error: err, UserError: userErrors[Unknown]}
+	}
+
+	name := strings.ToLower(strings.TrimPrefix(args[0], "#"))
+	if !goalNamePattern.MatchString(name) {
+		return CommandResult{Command: Goals, Error: fmt.Errorf("invalid goal name %q", name), UserError: userErrors[Goals]}
+	}
+
+	rest, currency := extractCurrency(args[1:], user.PreferredCurrency)
+	if len(rest) == 0 {
+		return CommandResult{Command: Goals, Error: fmt.Errorf("missing target"), UserError: userErrors[Goals]}
+	}
+
+	target, err := parseMoney(rest[0], currency, ctx.Numbers)
+	if err != nil || target <= 0 {
+		return CommandResult{Command: Goals, Error: fmt.Errorf("invalid target %q", rest[0]), UserError: userErrors[Goals]}
+	}
+
+	var deadline *time.Time
+	if len(rest) == 2 {
+		date, ok := parseDeadline(rest[1], ctx.Timestamp)
+		if !ok {
+			return CommandResult{Command: Goals, Error: fmt.Errorf("invalid deadline %q", rest[1]), UserError: userErrors[Goals]}
+		}
+		deadline = &date
+	} else if len(rest) > 2 {
+		return CommandResult{Command: Goals, Error: fmt.Errorf("invalid arguments: %v", rest), UserError: userErrors[Goals]}
+	}
+
+	goal := &Goal{UserID: ctx.UserID, Name: name, Target: target, Currency: currency, Deadline: deadline}
+	err = ctx.Repos.GoalRepo().Create(goal)
+	if IsDuplicate(err) {
+		return CommandResult{Command: Goals, Error: err, UserError: "A goal with that name already exists."}
+	}
+	if err != nil {
+		return CommandResult{Command: Goals, Error: err, UserError: userErrors[Unknown]}
+	}
+
+	return goalsProgress(ctx)
+}
+
+func removeGoal(args []string, ctx MessageContext) CommandResult {
+	if len(args) != 1 {
+		return CommandResult{Command: Goals, Error: fmt.Errorf("expected a single goal"), UserError: userErrors[Goals]}
+	}
+
+	goal, err := ctx.Repos.GoalRepo().GetByName(ctx.UserID, strings.ToLower(strings.TrimPrefix(args[0], "#")))
+	if err != nil {
+		return CommandResult{Command: Goals, Error: err, UserError: userErrors[Goals]}
+	}
+
+	if err := ctx.Repos.GoalRepo().Delete(goal, SourceChat); err != nil {
+		return CommandResult{Command: Goals, Error: err, UserError: userErrors[Unknown]}
+	}
+
+	return goalsProgress(ctx)
+}
+
+/**
+ * Read a deadline, which must be in the future: a day (e.g. 31/12/2027 or
+ * 2027-12-31) or a month (e.g. 12/2027), meaning its last day.
+ */
+func parseDeadline(expr string, now time.Time) (time.Time, bool) {
+	for _, layout := range []string{"2/1/2006", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, expr, now.Location()); err == nil {
+			return t, t.After(now)
+		}
+	}
+
+	if t, err := time.ParseInLocation("1/2006", expr, now.Location()); err == nil {
+		t = t.AddDate(0, 1, -1)
+		return t, t.After(now)
+	}
+
+	return time.Time{}, false
+}
+
+/**
+ * Progress of every goal: how much was saved, what's needed every month to
+ * make the deadline and when the target is reached at the pace so far.
+ */
+func goalsProgress(ctx MessageContext) CommandResult {
+	goals, err := ctx.Repos.GoalRepo().GetAll(ctx.UserID)
+	if err != nil {
+		return CommandResult{Command: Goals, Error: err, UserError: userErrors[Unknown]}
+	}
+
+	contributions, err := ctx.Repos.GoalRepo().GetContributions(ctx.UserID)
+	if err != nil {
+		return CommandResult{Command: Goals, Error: err, UserError: userErrors[Unknown]}
+	}
+
+	saved := map[uint]int64{}
+	first := map[uint]time.Time{}
+	for _, tx := range contributions {
+		saved[*tx.GoalID] += tx.Amount
+		if _, found := first[*tx.GoalID]; !found {
+			first[*tx.GoalID] = tx.Timestamp
+		}
+	}
+
+	now := ctx.Timestamp
+	progress := make([]GoalProgress, 0, len(goals))
+	for _, goal := range goals {
+		p := GoalProgress{Name: goal.Name, Currency: goal.Currency, Target: goal.Target, Saved: saved[goal.ID]}
+		remaining := goal.Target - p.Saved
+
+		if goal.Deadline != nil {
+			deadline := goal.Deadline.In(now.Location())
+			p.Deadline = &deadline
+			if remaining > 0 && deadline.After(now) {
+				months := int64(monthsUntil(now, deadline))
+				p.Monthly = (remaining + months - 1) / months
+			}
+		}
+
+		// Saving history starts with the goal, or with savings back-dated before it.
+		since := goal.CreatedAt
+		if at, found := first[goal.ID]; found && at.Before(since) {
+			since = at
+		}
+		if remaining > 0 && p.Saved > 0 {
+			window := max(now.Sub(since), minProjectionWindow)
+			pace := new(big.Rat).SetFrac64(int64(window/time.Second), p.Saved) // Seconds per minor unit
+			wait := new(big.Rat).Mul(pace, big.NewRat(remaining, 1))
+			if wait.Cmp(big.NewRat(int64(maxProjection/time.Second), 1)) <= 0 {
+				projected := now.Add(time.Duration(roundRat(wait)) * time.Second)
+				p.Projected = &projected
+			}
+		}
+
+		progress = append(progress, p)
+	}
+
+	return CommandResult{Command: Goals, Goals: progress}
+}
+
+// Calendar months left until a date, counting the current one, at least one.
+func monthsUntil(now time.Time, date time.Time) int {
+	months := (date.Year()-now.Year())*12 + int(date.Month()) - int(now.Month())
+	if date.Day() > now.Day() {
+		months++
+	}
+	return max(months, 1)
+}
@@ -0,0 +1,444 @@
+package app
+
+import (
+	"fmt"
+	. "remind0/db"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+/**
+ *   __ _ _ __ ___  _   _ _ __  ___
+ *  / _` | '__/ _ \| | | | '_ \/ __|
+ * | (_| | | | (_) | |_| | |_) \__ \
+ *  \__, |_|  \___/ \__,_| .__/|___/
+ *   __/ |               | |
+ *  |___/                |_|
+ *
+ * Shared ledgers for group chats: who paid, how it is split and who owes whom.
+ */
+
+const splitKeyword = "split"
+
+// Alias members can use to refer to themselves in a split.
+const selfMention = "me"
+
+type SplitMode int
+
+const (
+	SplitEqual  SplitMode = iota // @alice @bob
+	SplitShares                  // @alice:2 @bob:1
+	SplitExact                   // @alice=30 @bob=60
+)
+
+type SplitPart struct {
+	Username string
+	Weight   int64 // Number of shares, only used when splitting by shares.
+	Amount   int64 // Amount in minor units, only used when splitting by exact amounts.
+}
+
+/**
+ * Separate the split section of an add request from the rest of the message.
+ * Mentions following the split keyword belong to the split, anything else
+ * (e.g. a trailing currency) is handed back to the add request.
+ */
+func extractSplit(body string) (string, []string, bool) {
+	parts := strings.Fields(body)
+
+	for i, part := range parts {
+		if strings.ToLower(part) != splitKeyword {
+			continue
+		}
+
+		rest := append([]string{}, parts[:i]...)
+		mentions := []string{}
+		for _, arg := range parts[i+1:] {
+			if strings.HasPrefix(arg, "@") {
+				mentions = append(mentions, arg)
+			} else {
+				rest = append(rest, arg)
+			}
+		}
+		return strings.Join(rest, " "), mentions, true
+	}
+
+	return body, nil, false
+}
+
+/**
+ * Parse the mentions of a split, exact amounts being in the given currency.
+ * All of them must use the same mode.
+ */
+func parseSplit(mentions []string, currency string, numbers NumberFormat) (SplitMode, []SplitPart, error) {
+	if len(mentions) == 0 {
+		return SplitEqual, nil, fmt.Errorf("no members to split with")
+	}
+
+	mode := SplitEqual
+	parts := make([]SplitPart, 0, len(mentions))
+
+	for i, mention := range mentions {
+		mention = strings.TrimPrefix(mention, "@")
+
+		partMode := SplitEqual
+		part := SplitPart{Username: mention, Weight: 1}
+
+		if name, weight, ok := strings.Cut(mention, ":"); ok {
+			n, err := strconv.ParseInt(weight, 10, 64)
+			if err != nil || n <= 0 {
+				return mode, nil, fmt.Errorf("invalid shares for %s: %q", name, weight)
+			}
+			partMode, part = SplitShares, SplitPart{Username: name, Weight: n}
+		} else if name, amount, ok := strings.Cut(mention, "="); ok {
+			n, err := parseMoney(amount, currency, numbers)
+			if err != nil || n < 0 {
+				return mode, nil, fmt.Errorf("invalid amount for %s: %q", name, amount)
+			}
+			partMode, part = SplitExact, SplitPart{Username: name, Amount: n}
+		}
+
+		if part.Username == "" {
+			return mode, nil, fmt.Errorf("empty mention in split")
+		}
+		if i > 0 && partMode != mode {
+			return mode, nil, fmt.Errorf("cannot mix split modes")
+		}
+
+		mode = partMode
+		parts = append(parts, part)
+	}
+
+	return mode, parts, nil
+}
+
+/**
+ * Share an amount between the payer and the mentioned members.
+ * The payer takes part implicitly (one share, or whatever is left when
+ * splitting by exact amounts) unless they mention themselves explicitly.
+ * Amounts are in minor units, with leftover units going to the first
+ * participants so the shares always add up to the total.
+ */
+func computeSplits(total int64, currency string, mode SplitMode, parts []SplitPart, payer *User, members []*User) ([]Split, error) {
+	if total <= 0 {
+		return nil, fmt.Errorf("only positive amounts can be split")
+	}
+
+	type share struct {
+		userId uint
+		part   SplitPart
+	}
+
+	shares := []share{}
+	seen := map[uint]bool{}
+	for _, part := range parts {
+		member := findMember(part.Username, payer, members)
+		if member == nil {
+			return nil, fmt.Errorf("unknown group member @%s", part.Username)
+		}
+		if seen[member.ID] {
+			return nil, fmt.Errorf("@%s is mentioned more than once", part.Username)
+		}
+		seen[member.ID] = true
+		shares = append(shares, share{userId: member.ID, part: part})
+	}
+
+	payerListed := seen[payer.ID]
+	amounts := make([]int64, len(shares))
+
+	switch mode {
+	case SplitExact:
+		var sum int64
+		for i, s := range shares {
+			amounts[i] = s.part.Amount
+			sum += s.part.Amount
+		}
+		if sum > total || (payerListed && sum != total) {
+			return nil, fmt.Errorf("split amounts add up to %s, expected %s", formatMoney(sum, currency), formatMoney(total, currency))
+		}
+		if !payerListed && sum < total {
+			shares = append(shares, share{userId: payer.ID})
+			amounts = append(amounts, total-sum)
+		}
+
+	default:
+		if !payerListed {
+			shares = append(shares, share{userId: payer.ID, part: SplitPart{Weight: 1}})
+			amounts = append(amounts, 0)
+		}
+
+		var weights int64
+		for _, s := range shares {
+			weights += s.part.Weight
+		}
+
+		remaining := total
+		for i, s := range shares {
+			amounts[i] = total * s.part.Weight / weights
+			remaining -= amounts[i]
+		}
+		for i := 0; remaining > 0; i = (i + 1) % len(amounts) {
+			amounts[i]++
+			remaining--
+		}
+	}
+
+	splits := make([]Split, 0, len(shares))
+	for i, s := range shares {
+		if amounts[i] == 0 {
+			continue
+		}
+		splits = append(splits, Split{UserID: s.userId, Amount: amounts[i]})
+	}
+
+	return splits, nil
+}
+
+func findMember(username string, self *User, members []*User) *User {
+	if strings.EqualFold(username, selfMention) {
+		return self
+	}
+	for _, member := range members {
+		if member.Username != "" && strings.EqualFold(member.Username, username) {
+			return member
+		}
+	}
+	return nil
+}
+
+func findMemberByID(id uint, self *User, members []*User) *User {
+	if id == self.ID {
+		return self
+	}
+	for _, member := range members {
+		if member.ID == id {
+			return member
+		}
+	}
+	return &User{}
+}
+
+// Name used to refer to a member in group messages.
+func memberName(user *User) string {
+	if user == nil {
+		return "someone"
+	}
+	if user.Username != "" {
+		return "@" + user.Username
+	}
+	return user.FirstName
+}
+
+type Balance struct {
+	Member   string
+	Currency string
+	Net      int64 // In minor units, positive when the member is owed money.
+}
+
+type Payment struct {
+	From     string
+	To       string
+	Amount   int64 // In minor units of the currency.
+	Currency string
+}
+
+/**
+ * Net position of every member per currency, in minor units. Payers are credited
+ * with the full expense and debited their own share, so every currency
+ * adds up to zero.
+ */
+func netPositions(txs []*Transaction, settlements []*Settlement) map[string]map[uint]int64 {
+	net := map[string]map[uint]int64{}
+
+	entry := func(currency string) map[uint]int64 {
+		if net[currency] == nil {
+			net[currency] = map[uint]int64{}
+		}
+		return net[currency]
+	}
+
+	for _, tx := range txs {
+		positions := entry(tx.Currency)
+		for _, split := range tx.Splits {
+			positions[tx.UserID] += split.Amount
+			positions[split.UserID] -= split.Amount
+		}
+	}
+
+	for _, s := range settlements {
+		positions := entry(s.Currency)
+		positions[s.FromUserID] += s.Amount
+		positions[s.ToUserID] -= s.Amount
+	}
+
+	return net
+}
+
+/**
+ * Work out the payments that clear every debt, repeatedly matching the
+ * largest debtor with the largest creditor. This settles n members in at
+ * most n-1 payments per currency.
+ */
+func settlePlan(net map[string]map[uint]int64, names map[uint]string) []Payment {
+	type position struct {
+		userId uint
+		amount int64
+	}
+
+	payments := []Payment{}
+
+	for _, currency := range sortedKeys(net) {
+		var debtors, creditors []position
+		for userId, amount := range net[currency] {
+			if amount < 0 {
+				debtors = append(debtors, position{userId, -amount})
+			} else if amount > 0 {
+				creditors = append(creditors, position{userId, amount})
+			}
+		}
+
+		for len(debtors) > 0 && len(creditors) > 0 {
+			sort.Slice(debtors, func(i, j int) bool { return debtors[i].amount > debtors[j].amount })
+			sort.Slice(creditors, func(i, j int) bool { return creditors[i].amount > creditors[j].amount })
+
+			amount := min(debtors[0].amount, creditors[0].amount)
+			payments = append(payments, Payment{
+				From:     names[debtors[0].userId],
+				To:       names[creditors[0].userId],
+				Amount:   amount,
+				Currency: currency,
+			})
+
+			debtors[0].amount -= amount
+			creditors[0].amount -= amount
+			if debtors[0].amount == 0 {
+				debtors = debtors[1:]
+			}
+			if creditors[0].amount == 0 {
+				creditors = creditors[1:]
+			}
+		}
+	}
+
+	return payments
+}
+
+func sortedKeys[V any](m map[string]V) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+/**
+ * Load the group's ledger along with a display name for each member.
+ */
+func groupLedger(ctx MessageContext) (map[string]map[uint]int64, map[uint]string, []*User, error) {
+	groupId := ctx.Group.ID
+
+	members, err := ctx.Repos.GroupRepo().GetMembers(groupId)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	txs, err := ctx.Repos.GroupRepo().GetSplitTransactions(groupId)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	settlements, err := ctx.Repos.GroupRepo().GetSettlements(groupId)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+
+	names := map[uint]string{}
+	for _, member := range members {
+		names[member.ID] = memberName(member)
+	}
+
+	return netPositions(txs, settlements), names, members, nil
+}
+
+func groupBalances(ctx MessageContext) CommandResult {
+	if ctx.Group == nil {
+		return CommandResult{Command: Balances, Error: fmt.Errorf("balances requested outside a group"), UserError: groupOnlyError}
+	}
+
+	net, names, _, err := groupLedger(ctx)
+	if err != nil {
+		return CommandResult{Command: Balances, Error: err, UserError: userErrors[Unknown]}
+	}
+
+	balances := []Balance{}
+	for _, currency := range sortedKeys(net) {
+		for userId, amount := range net[currency] {
+			if amount != 0 {
+				balances = append(balances, Balance{Member: names[userId], Currency: currency, Net: amount})
+			}
+		}
+	}
+
+	sort.SliceStable(balances, func(i, j int) bool {
+		if balances[i].Currency != balances[j].Currency {
+			return balances[i].Currency < balances[j].Currency
+		}
+		return balances[i].Net > balances[j].Net
+	})
+
+	return CommandResult{Command: Balances, Balances: balances}
+}
+
+/**
+ * Without arguments, suggest the payments that clear the group's debts.
+ * With a mention and an amount, record that the sender paid that member.
+ */
+func settle(args []string, ctx MessageContext) CommandResult {
+	if ctx.Group == nil {
+		return CommandResult{Command: Settle, Error: fmt.Errorf("settle requested outside a group"), UserError: groupOnlyError}
+	}
+
+	net, names, members, err := groupLedger(ctx)
+	if err != nil {
+		return CommandResult{Command: Settle, Error: err, UserError: userErrors[Unknown]}
+	}
+
+	if len(args) == 0 {
+		return CommandResult{Command: Settle, Payments: settlePlan(net, names)}
+	}
+
+	if len(args) < 2 || len(args) > 3 || !strings.HasPrefix(args[0], "@") {
+		return CommandResult{Command: Settle, Error: fmt.Errorf("invalid settle arguments: %v", args), UserError: userErrors[Settle]}
+	}
+
+	payer, err := ctx.Repos.UserRepo().GetByID(ctx.UserID)
+	if err != nil {
+		return CommandResult{Command: Settle, Error: err, UserError: userErrors[Unknown]}
+	}
+
+	payee := findMember(strings.TrimPrefix(args[0], "@"), payer, members)
+	if payee == nil || payee.ID == payer.ID {
+		return CommandResult{Command: Settle, Error: fmt.Errorf("invalid payee %s", args[0]), UserError: userErrors[Settle]}
+	}
+
+	currency := payer.PreferredCurrency
+	if len(args) == 3 {
+		currency = strings.ToUpper(strings.TrimPrefix(args[2], "$"))
+		if !isValidCurrency(currency) {
+			return CommandResult{Command: Settle, Error: fmt.Errorf("invalid currency %q", args[2]), UserError: userErrors[Settle]}
+		}
+	}
+
+	amount, err := parseMoney(args[1], currency, ctx.Numbers)
+	if err != nil || amount <= 0 {
+		return CommandResult{Command: Settle, Error: fmt.Errorf("invalid amount %q", args[1]), UserError: userErrors[Settle]}
+	}
+
+	settlement := &Settlement{GroupID: ctx.Group.ID, FromUserID: payer.ID, ToUserID: payee.ID, Amount: amount, Currency: currency}
+	if err := ctx.Repos.GroupRepo().CreateSettlement(settlement); err != nil {
+		return CommandResult{Command: Settle, Error: err, UserError: userErrors[Unknown]}
+	}
+
+	return CommandResult{Command: Settle, UserInfo: ctx.Language.Tf(
+		"✅ Recorded %s → %s: %s %s", memberName(payer), memberName(payee), formatMoney(amount, currency), currency,
+	)}
+}
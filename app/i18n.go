@@ -0,0 +1,118 @@
+package app
+
+import (
+	"fmt"
+	"strings"
+
+	. "remind0/db"
+
+	telegramClient "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+/**
+ * Replies are written in English and translated when they're sent, looking
+ * the English text up in the catalog of the user's language. Anything a
+ * catalog is missing stays in English, so a translation can be partial.
+ *
+ * The language is the one the user picked with !config set-language, else
+ * the one their Telegram app reports (pt-BR is read as pt), else English.
+ */
+
+type Language string
+
+const English Language = "en"
+
+// Languages replies can be in, by their name in that language.
+var languages = map[Language]string{
+	English: "English",
+	"es":    "Español",
+	"pt":    "Português",
+}
+
+/**
+ * Translations of a language, keyed by the English text.
+ */
+type catalog struct {
+	phrases    map[string]string    // Messages, labels and formats (e.g. "📥 Category: %s\n")
+	headers    map[Command]string   // Headers of the replies to each command
+	help       map[HelpTopic]string // Help topics
+	categories map[string]string    // Category names, aliases aren't translated
+}
+
+var catalogs = map[Language]*catalog{
+	"es": &spanish,
+	"pt": &portuguese,
+}
+
+/**
+ * Language of a user, following the fallback chain.
+ */
+func userLanguage(user *User, sender *telegramClient.User) Language {
+	var codes []string
+	if user != nil {
+		codes = append(codes, user.Language)
+	}
+	if sender != nil {
+		codes = append(codes, sender.LanguageCode)
+	}
+	return resolveLanguage(codes...)
+}
+
+/**
+ * First supported language among the codes, trying the base language of
+ * regional codes (e.g. pt-BR, es_AR). English when none is supported.
+ */
+func resolveLanguage(codes ...string) Language {
+	for _, code := range codes {
+		code = strings.ToLower(strings.TrimSpace(code))
+		base, _, _ := strings.Cut(strings.ReplaceAll(code, "_", "-"), "-")
+		for _, candidate := range []Language{Language(code), Language(base)} {
+			if _, ok := languages[candidate]; ok {
+				return candidate
+			}
+		}
+	}
+	return English
+}
+
+func (lang Language) catalog() *catalog {
+	if c, ok := catalogs[lang]; ok {
+		return c
+	}
+	return &catalog{}
+}
+
+// Translate a message, kept in English if there's no translation.
+func (lang Language) T(text string) string {
+	if translated, ok := lang.catalog().phrases[text]; ok {
+		return translated
+	}
+	return text
+}
+
+// Translate a format, then fill it in.
+func (lang Language) Tf(format string, args ...any) string {
+	return fmt.Sprintf(lang.T(format), args...)
+}
+
+func (lang Language) header(command Command) string {
+	if translated, ok := lang.catalog().headers[command]; ok {
+		return translated
+	}
+	return operationHeaders[command]
+}
+
+func (lang Language) help(topic HelpTopic) string {
+	if translated, ok := lang.catalog().help[topic]; ok {
+		return translated
+	}
+	return userHelp[topic]
+}
+
+// Name of a category, which transactions store in English.
+func (lang Language) category(name string) string {
+	if translated, ok := lang.catalog().categories[name]; ok {
+		return translated
+	}
+	return name
+}
@@ -0,0 +1,421 @@
+package app
+
+import . "remind0/db"
+
+/**
+ * Spanish translations.
+ */
+var spanish = catalog{
+	phrases: map[string]string{
+		// Replies
+		"✅ Command executed successfully.":                                                  "✅ Comando ejecutado correctamente.",
+		"🎉 Welcome! Send !help to get started.":                                             "🎉 ¡Bienvenido! Envía !help para empezar.",
+		"⚠️ Failed to process command: %s":                                                  "⚠️ No se pudo procesar el comando: %s",
+		"⚠️ Failed to process command: \n%s":                                                "⚠️ No se pudo procesar el comando: \n%s",
+		"⚠️ Message cannot be empty, exceed %d lines or %d characters per line.":            "⚠️ El mensaje no puede estar vacío ni superar las %d líneas o los %d caracteres por línea.",
+		"⚠️ Sorry, your message couldn't be processed. Please try again later.":             "⚠️ Lo sentimos, no se pudo procesar tu mensaje. Inténtalo de nuevo más tarde.",
+		"⛔ You have been blocked from using this bot.":                                      "⛔ Se te ha bloqueado el uso de este bot.",
+		"⛔ This bot is private. Ask its admin to let your Telegram ID (%d) in.":             "⛔ Este bot es privado. Pide a su administrador que dé acceso a tu ID de Telegram (%d).",
+		"⛔ This bot is invite-only. Send !join <code> with the invite code you were given.": "⛔ Este bot requiere invitación. Envía !join <código> con el código que te dieron.",
+		"⛔ This invite code is unknown, already used or expired.":                           "⛔ Este código de invitación no existe, ya se usó o ha caducado.",
+
+		// Transactions
+		"🪪 ID: %d\n":                        "🪪 ID: %d\n",
+		"📥 Category: %s\n":                  "📥 Categoría: %s\n",
+		"💰 Amount: %s%s\n":                  "💰 Importe: %s%s\n",
+		"📌 Notes: %s\n":                     "📌 Notas: %s\n",
+		"🕒 At: %s\n":                        "🕒 Fecha: %s\n",
+		"🏦 Account: @%s\n":                  "🏦 Cuenta: @%s\n",
+		"📎 Attachments: %d (!receipt %d)\n": "📎 Adjuntos: %d (!receipt %d)\n",
+		"👥 Split: %s\n":                     "👥 Reparto: %s\n",
+		"💰 Total: %s\n":                     "💰 Total: %s\n",
+		"📊 Count: %d\n":                     "📊 Cantidad: %d\n",
+		"• Line %d: %s":                     "• Línea %d: %s",
+		"Nothing was recorded, please fix these lines:\n": "No se registró nada, corrige estas líneas:\n",
+		"⚠️ Not an account, kept in the notes: %s\n":      "⚠️ No es una cuenta, se dejó en las notas: %s\n",
+
+		// History
+		"%s · %s · via %s\n":       "%s · %s · vía %s\n",
+		eventLabels[ActionCreate]:  "➕ Creada",
+		eventLabels[ActionEdit]:    "✏️ Editada",
+		eventLabels[ActionDelete]:  "🗑️ Eliminada",
+		"   Category: %s → %s":     "   Categoría: %s → %s",
+		"   Amount: %s %s → %s %s": "   Importe: %s %s → %s %s",
+		"   Notes: %s → %s":        "   Notas: %s → %s",
+		"   At: %s → %s":           "   Fecha: %s → %s",
+		"   Goal: removed":         "   Objetivo: quitado",
+		"   Goal: changed":         "   Objetivo: cambiado",
+		"   No visible changes\n":  "   Sin cambios visibles\n",
+
+		// Groups, debts and accounts
+		"🎉 All settled up!\n":                                              "🎉 ¡Todo saldado!\n",
+		"• %s owes %s\n":                                                   "• %s debe %s\n",
+		"• %s is owed %s\n":                                                "• A %s le deben %s\n",
+		"Record a payment with: !settle @member <amount>\n":                "Registra un pago con: !settle @miembro <importe>\n",
+		"✅ Recorded %s → %s: %s %s":                                        "✅ Registrado %s → %s: %s %s",
+		"🪪 Linked transaction: %d (%s)\n":                                  "🪪 Transacción vinculada: %d (%s)\n",
+		"🎉 No outstanding debts!\n":                                        "🎉 ¡No hay deudas pendientes!\n",
+		"• %s owes you %s %s\n":                                            "• %s te debe %s %s\n",
+		"• You owe %s %s %s\n":                                             "• Le debes a %s %s %s\n",
+		"• %s and you are even in %s\n":                                    "• Estás a mano con %s en %s\n",
+		"No accounts yet. Use !account add <name> <type> to create one.\n": "Aún no hay cuentas. Usa !account add <nombre> <tipo> para crear una.\n",
+		"Currently supported categories:\n\n":                              "Categorías disponibles:\n\n",
+
+		// Configuration
+		"✅ Default currency set to %s (%s)":           "✅ Moneda predeterminada: %s (%s)",
+		"✅ Timezone set to %s (now %s)":               "✅ Zona horaria: %s (ahora %s)",
+		"✅ Language set to %s":                        "✅ Idioma: %s",
+		"✅ Language set to follow your Telegram app":  "✅ El idioma seguirá el de tu aplicación de Telegram",
+		"✅ Number format set to %s":                   "✅ Formato de número: %s",
+		"✅ Number format set to follow your language": "✅ El formato de número seguirá el de tu idioma",
+
+		// Reminders
+		"👋 Nothing logged today yet.":           "👋 Hoy aún no has registrado nada.",
+		"👋 Nothing logged in the last %d days.": "👋 No has registrado nada en los últimos %d días.",
+		"Record an expense with e.g. !add G 12.50 Lunch, or turn these reminders off with !c reminders off.": "Registra un gasto con, por ejemplo, !add G 12.50 Almuerzo, o desactiva estos recordatorios con !c reminders off.",
+		"🔕 Reminders off": "🔕 Recordatorios desactivados",
+		"🔔 Reminders on: at %s when nothing was logged that day":       "🔔 Recordatorios activados: a las %s si ese día no se registró nada",
+		"🔔 Reminders on: at %s after %d days without logging anything": "🔔 Recordatorios activados: a las %s tras %d días sin registrar nada",
+		"🌙 Quiet hours: %s": "🌙 Horas de silencio: %s",
+		"Use the format: !c reminders on|off|time <HH:MM>|days <N>|quiet <HH:MM-HH:MM|off>. Use !help config for guidance.": "Usa el formato: !c reminders on|off|time <HH:MM>|days <N>|quiet <HH:MM-HH:MM|off>. Usa !help config para más ayuda.",
+		"Reminders can't be sent during quiet hours, pick a time outside them.":                                             "Los recordatorios no se envían en horas de silencio, elige una hora fuera de ellas.",
+
+		// Errors
+		userErrors[Add]:           "Comprueba que la categoría, el importe y la fecha de la transacción sean válidos. Usa !help add para más ayuda.",
+		userErrors[Remove]:        "Indica IDs de transacción válidos. Usa !help remove para más ayuda.",
+		userErrors[List]:          "Revisa las opciones e inténtalo de nuevo. Usa !help list para más ayuda.",
+		userErrors[Help]:          "Inténtalo de nuevo más tarde o contacta con soporte.",
+		userErrors[Edit]:          "Usa el formato: !edit <ID> <categoría> <importe> <notas?> $<moneda?>. Usa !help edit para más ayuda.",
+		userErrors[Configuration]: "Usa el formato: !c set-default-currency <CÓDIGO>. Usa !help config para más ayuda.",
+		userErrors[History]:       "Indica un único ID de transacción con historial. Usa !help history para más ayuda.",
+		userErrors[Settle]:        "Usa el formato: !settle o !settle @miembro <importe> $<moneda?>. Usa !help groups para más ayuda.",
+		userErrors[Lend]:          "Usa el formato: !lend <persona> <importe> <notas?> $<moneda?>. Usa !help debts para más ayuda.",
+		userErrors[Borrow]:        "Usa el formato: !borrow <persona> <importe> <notas?> $<moneda?>. Usa !help debts para más ayuda.",
+		userErrors[Repay]:         "Comprueba que el pago no supere lo que se debe. Usa !help debts para más ayuda.",
+		userErrors[Debts]:         "Usa el formato: !debts <persona?>. Usa !help debts para más ayuda.",
+		userErrors[Accounts]:      "Revisa el nombre y los datos de la cuenta. Usa !help accounts para más ayuda.",
+		userErrors[Receipt]:       "Indica un único ID de transacción con archivos adjuntos. Usa !help receipt para más ayuda.",
+		userErrors[TransferFunds]: "Usa el formato: !transfer <importe> @<origen> @<destino> <notas?>, entre cuentas de la misma moneda.",
+		userErrors[Invites]:       "Usa el formato: !invite <días?>, válido hasta 90 días. Usa !help invite para más ayuda.",
+		userErrors[Unknown]:       "Algo salió mal, inténtalo de nuevo más tarde.",
+		groupOnlyError:            "Esto solo está disponible en chats de grupo. Usa !help groups para más ayuda.",
+		splitError:                "Comprueba que repartes con miembros conocidos e importes válidos. Usa !help groups para más ayuda.",
+		parenthesisedBatchError:   "Los lotes ahora se escriben entre corchetes, separados por punto y coma, p. ej. [12.5;8]. Usa !help add para más ayuda.",
+		duplicateTransactionError: "Esa transacción ya estaba registrada.",
+
+		"An account with that name already exists.":                                                                            "Ya existe una cuenta con ese nombre.",
+		"Accounts with transactions or transfers can't be removed.":                                                            "No se pueden eliminar cuentas con transacciones o transferencias.",
+		"Invalid currency code. Use !help currencies for supported currencies.":                                                "Código de moneda no válido. Usa !help currencies para ver las monedas disponibles.",
+		"Invalid timezone. Use a name like Pacific/Auckland or America/Buenos_Aires.":                                          "Zona horaria no válida. Usa un nombre como Pacific/Auckland o America/Buenos_Aires.",
+		"Unsupported language. Use en (English), es (Español), pt (Português) or auto.":                                        "Idioma no disponible. Usa en (English), es (Español), pt (Português) o auto.",
+		"Unsupported number format. Use en (1,234.56), eu (1.234,56), br (R$ 1.234,56), fr (1 234,56), ch (1'234.56) or auto.": "Formato de número no disponible. Usa en (1,234.56), eu (1.234,56), br (R$ 1.234,56), fr (1 234,56), ch (1'234.56) o auto.",
+		"Currencies supported (ISO 4217):\n":                                                                                   "Monedas disponibles (ISO 4217):\n",
+		"Custom currencies:\n":                                                                                                 "Monedas personalizadas:\n",
+		"• %s - %s (%d decimals)\n":                                                                                            "• %s - %s (%d decimales)\n",
+		"Add one with: !currency add <CODE> <decimals> <name>\nRemove it with: !currency rm <CODE>\n":                          "Añade una con: !currency add <CÓDIGO> <decimales> <nombre>\nElimínala con: !currency rm <CÓDIGO>\n",
+		"✅ Added %s (%s) with %d decimals, use it with $%s":                                                                    "✅ Añadida %s (%s) con %d decimales, úsala con $%s",
+		"✅ Removed %s":                                               "✅ Eliminada %s",
+		"That currency already exists.":                              "Esa moneda ya existe.",
+		"Currencies in use can't be removed.":                        "No se pueden eliminar monedas en uso.",
+		"Only whoever added a currency, or an admin, can remove it.": "Solo quien añadió una moneda, o un administrador, puede eliminarla.",
+		userErrors[Currencies]:                                       "Usa el formato: !currency add <CÓDIGO> <decimales> <nombre> o !currency rm <CÓDIGO>, con hasta 8 decimales. Usa !help currencies para más ayuda.",
+		"Unknown config option. Use !help config for guidance.":      "Opción de configuración desconocida. Usa !help config para más ayuda.",
+		"Unknown command. Available commands are: add, rm, ls, edit, history, receipt, account, transfer, currency, balances, settle, lend, borrow, repay, debts, goal, bill, buy, sell, price, portfolio, invite, help, config.": "Comando desconocido. Los comandos disponibles son: add, rm, ls, edit, history, receipt, account, transfer, currency, balances, settle, lend, borrow, repay, debts, goal, bill, buy, sell, price, portfolio, invite, help, config.",
+
+		// Investments
+		"No holdings yet. Use !buy <instrument> <units> <price> to record one.\n": "Aún no hay inversiones. Usa !buy <instrumento> <unidades> <precio> para registrar una.\n",
+		"📕 %s: all sold\n":                                                              "📕 %s: todo vendido\n",
+		"📈 %s: %s units\n":                                                              "📈 %s: %s unidades\n",
+		"   Cost: %s (%s each)\n":                                                       "   Coste: %s (%s cada una)\n",
+		"   No price yet, enter one with !price %s <price>\n":                           "   Sin precio aún, indícalo con !price %s <precio>\n",
+		"   Value: %s (%s each, %s)\n":                                                  "   Valor: %s (%s cada una, %s)\n",
+		"   Unrealised: %s (%s)\n":                                                      "   No realizado: %s (%s)\n",
+		"   Realised: %s\n":                                                             "   Realizado: %s\n",
+		"💱 %s: worth %s, unrealised %s, realised %s\n":                                  "💱 %s: vale %s, no realizado %s, realizado %s\n",
+		"   Not counting %d holding(s) without a price\n":                               "   Sin contar %d inversión(es) sin precio\n",
+		"You can't sell more units than you hold.":                                      "No puedes vender más unidades de las que tienes.",
+		"That price makes the holding worth more than can be counted.":                  "Ese precio hace que la posición valga más de lo que se puede contar.",
+		"Each instrument is traded in a single currency, use the one it was bought in.": "Cada instrumento se opera en una sola moneda, usa aquella en la que se compró.",
+		userErrors[Buy]:                                                                 "Usa el formato: !buy <instrumento> <unidades> <precio> $<moneda?>. Usa !help portfolio para más ayuda.",
+		userErrors[Sell]:                                                                "Usa el formato: !sell <instrumento> <unidades> <precio> $<moneda?>. Usa !help portfolio para más ayuda.",
+		userErrors[Prices]:                                                              "Usa el formato: !price <instrumento> <precio> $<moneda?>. Usa !help portfolio para más ayuda.",
+		userErrors[Portfolio]:                                                           "Usa el formato: !portfolio <instrumento?>. Usa !help portfolio para más ayuda.",
+		"🧾 Trade: %d\n":                                                                 "🧾 Operación: %d\n",
+		"No trade with that ID.":                                                        "No hay ninguna operación con ese ID.",
+		"Later sales took units from that purchase, remove them first.":                 "Ventas posteriores tomaron unidades de esa compra, elimínalas primero.",
+		"Trade %d sells more %s than was held at the time, remove it with !trade rm %d.":                           "La operación %d vende más %s de lo que se tenía en ese momento, elimínala con !trade rm %d.",
+		"Transaction %d was recorded by trade %d, remove it with !trade rm %d instead.":                            "La transacción %d la registró la operación %d, elimínala con !trade rm %d.",
+		"Transaction %d was recorded by trade %d, remove it with !trade rm %d and record the trade again instead.": "La transacción %d la registró la operación %d, elimínala con !trade rm %d y registra la operación de nuevo.",
+		userErrors[Trades]: "Usa el formato: !trade rm <id>. Usa !help portfolio para más ayuda.",
+
+		// Goals
+		"🎯 Goal: #%s\n": "🎯 Objetivo: #%s\n",
+		"No goals yet. Use !goal add <name> <target> <deadline?> to create one.\n": "Aún no hay objetivos. Usa !goal add <nombre> <meta> <plazo?> para crear uno.\n",
+		"   Saved: %s of %s\n":                           "   Ahorrado: %s de %s\n",
+		"   🎉 Target reached!\n":                         "   🎉 ¡Meta alcanzada!\n",
+		"   Deadline: %s, missed\n":                      "   Plazo: %s, vencido\n",
+		"   Deadline: %s, save %s a month\n":             "   Plazo: %s, ahorra %s al mes\n",
+		"   At this pace: reached by %s\n":               "   A este ritmo: alcanzada el %s\n",
+		"   Save towards it with: !add S <amount> #%s\n": "   Ahorra para él con: !add S <importe> #%s\n",
+		"A goal with that name already exists.":          "Ya existe un objetivo con ese nombre.",
+		userErrors[Goals]:                                "Revisa el nombre, la meta y el plazo del objetivo (p. ej. 12/2027). Usa !help goals para más ayuda.",
+
+		// Bills
+		"No bills yet. Use !bill add <name> <amount> due <day> to add one.\n": "Aún no hay facturas. Usa !bill add <nombre> <importe> due <día> para añadir una.\n",
+		"   Next due: %s, reminder %d day(s) before\n":                        "   Próximo vencimiento: %s, aviso %d día(s) antes\n",
+		"🧾 %s (%s) is due in %d day(s), on %s.":                               "🧾 %s (%s) vence en %d día(s), el %s.",
+		"🧾 %s (%s) is due today.":                                             "🧾 %s (%s) vence hoy.",
+		"🧾 %s (%s) was due on %s.":                                            "🧾 %s (%s) venció el %s.",
+		"Tap Paid once it's paid to record it as %s, or use !bill paid %s.":   "Toca Pagada cuando la pagues para registrarla como %s, o usa !bill paid %s.",
+		"✅ Paid": "✅ Pagada",
+		"✅ Paid, recorded as transaction %d. Next due on %s.": "✅ Pagada, registrada como la transacción %d. Próximo vencimiento el %s.",
+		"That bill no longer exists.":                         "Esa factura ya no existe.",
+		"That bill was already paid or skipped.":              "Esa factura ya se pagó o se omitió.",
+		"A bill with that name already exists.":               "Ya existe una factura con ese nombre.",
+		userErrors[Bills]:                                     "Usa el formato: !bill add <nombre> <importe> due <día> <U|R|SUB?> <remind N?> $<moneda?>. Usa !help bills para más ayuda.",
+
+		// Receipts, invites and admin
+		"📎 Attached %d file(s) to transaction %d.":                                        "📎 %d archivo(s) adjuntado(s) a la transacción %d.",
+		"📎 %d file(s) for transaction %d.":                                                "📎 %d archivo(s) de la transacción %d.",
+		"You already have access, no invite code needed.":                                 "Ya tienes acceso, no necesitas código de invitación.",
+		"Only admins can create invite codes.":                                            "Solo los administradores pueden crear códigos de invitación.",
+		"Invite code: %s\nValid once, until %s.\n\nThe new user sends the bot:\n!join %s": "Código de invitación: %s\nVálido una vez, hasta el %s.\n\nEl nuevo usuario envía al bot:\n!join %s",
+		"Only admins can use this command.":                                               "Solo los administradores pueden usar este comando.",
+		"Unknown user. Use their @username or Telegram ID, see !admin users.":             "Usuario desconocido. Usa su @usuario o su ID de Telegram, ver !admin users.",
+		"Admins can't be blocked.":                                                        "Los administradores no se pueden bloquear.",
+		"Blocked %s (%d).":                                                                "Bloqueado %s (%d).",
+		"Unblocked %s (%d).":                                                              "Desbloqueado %s (%d).",
+		"Sending the announcement to %d user(s), you'll be told once it's done.":          "Enviando el anuncio a %d usuario(s), se te avisará cuando termine.",
+		"📣 Announcement delivered to %d of %d user(s).":                                   "📣 Anuncio entregado a %d de %d usuario(s).",
+		"📣 Announcement interrupted by a restart, delivered to %d of %d user(s).":         "📣 Anuncio interrumpido por un reinicio, entregado a %d de %d usuario(s).",
+	},
+
+	headers: map[Command]string{
+		Add:           "✅ Gasto registrado",
+		Remove:        "✂️ Gasto eliminado",
+		List:          "📋 Transacciones",
+		Help:          "💡 Ayuda",
+		Edit:          "📝 Gasto actualizado",
+		Configuration: "⚙️ Configuración",
+		History:       "🕓 Historial de la transacción",
+		Balances:      "⚖️ Saldos",
+		Settle:        "🤝 Saldar cuentas",
+		Lend:          "📤 Préstamo registrado",
+		Borrow:        "📥 Deuda registrada",
+		Repay:         "💸 Pago registrado",
+		Debts:         "🧾 Deudas",
+		Accounts:      "🏦 Cuentas",
+		TransferFunds: "🔁 Transferencia registrada",
+		Receipt:       "🧾 Recibos",
+		Invites:       "🎟️ Invitaciones",
+		Currencies:    "💱 Monedas",
+		Buy:           "🛒 Compra registrada",
+		Sell:          "💰 Venta registrada",
+		Prices:        "🏷️ Precio actualizado",
+		Portfolio:     "📊 Cartera",
+		Trades:        "🗑️ Operación eliminada",
+		Goals:         "🎯 Objetivos de ahorro",
+		Bills:         "🧾 Facturas",
+	},
+
+	categories: map[string]string{
+		"Income":           "Ingresos",
+		"Savings":          "Ahorros",
+		"Utilities":        "Servicios",
+		"Subscriptions":    "Suscripciones",
+		"Rent":             "Alquiler",
+		"Health & Fitness": "Salud y deporte",
+		"Transport":        "Transporte",
+		"Groceries":        "Supermercado",
+		"Going Out":        "Salidas",
+		"Investment":       "Inversiones",
+		"Shopping":         "Compras",
+		"Education":        "Educación",
+		"Travel":           "Viajes",
+		"Miscellaneous":    "Varios",
+	},
+
+	help: map[HelpTopic]string{
+		{Command: Help}: `
+Comando: help (alias: h)
+
+Uso:
+	!help: Muestra este menú de ayuda
+	!help <comando>: Muestra la ayuda detallada de un comando
+	!help categories: Lista las categorías disponibles
+	!help currencies: Lista las monedas disponibles
+	!help groups: Comparte gastos en chats de grupo
+
+Comandos:
+	• !add <categoría> <importe> <notas?> $<moneda?> - Registra un gasto o ingreso
+	• !ls [opciones] - Consulta tus transacciones
+	• !rm <ID1> <ID2> ... - Elimina transacciones
+	• !edit <ID> <categoría> <importe> <notas?> - Edita una transacción
+	• !lend / !borrow <persona> <importe> - Lleva la cuenta de lo que se debe
+	• !debts - Muestra las deudas pendientes
+	• !receipt <ID> - Recupera los recibos de una transacción
+	• !account add <nombre> <tipo> - Lleva el saldo de una cuenta
+	• !currency add <CÓDIGO> <decimales> <nombre> - Añade una moneda (p. ej. BTC)
+	• !balances - Muestra los saldos de las cuentas
+	• !buy / !sell <instrumento> <unidades> <precio> - Lleva tus inversiones
+	• !portfolio - Muestra tus inversiones, su valor y ganancias
+	• !goal add <nombre> <meta> <plazo?> - Ahorra para un objetivo
+	• !bill add <nombre> <importe> due <día> - Recibe avisos de una factura
+	• !history <ID> - Muestra los cambios de una transacción
+	• !c set-default-currency <CÓDIGO> - Define tu moneda preferida
+	• !c set-language <en|es|pt> - Define el idioma de las respuestas
+	• !help - Muestra este menú de ayuda
+
+Ejemplos rápidos:
+	• !add G 45 Almuerzo $USD
+	• !ls $USD 20
+	• !c set-default-currency NZD
+
+Más ayuda:
+	• Escribe !help <comando> para ver su uso detallado
+	• Escribe !help categories para ver las categorías
+	• Escribe !help currencies para ver las monedas
+	`,
+		{Command: Add}: `
+Comando: add (alias: a)
+
+Uso:
+	!add <categoría> <importe o [n;n]> <notas?> @<cuenta?> @<fecha?> $<moneda?>
+
+Ejemplos:
+	!add G 45 Mercadona (45 en tu moneda predeterminada)
+	!add G 45 Mercadona $USD (45 USD)
+	!add G [2.5;8] Mercadillo $EUR (2.5 y 8 EUR)
+	!add GO 12.5+8*2 Copas (28.50)
+	!add SH 45-5% Zapatos (42.75, con un 5% de descuento)
+	!add SH -20 Devolución (También con importes negativos)
+	!add G 45 Mercadona
+	GO 12 Café @visa (Una transacción por línea)
+
+Notas:
+	• Categorías: usa !help categories para ver la lista
+	• Monedas: usa !help currencies para ver la lista
+	• Define tu moneda predeterminada: !c set-default-currency USD
+	• Cuenta de origen o destino: añade @<cuenta> (ver !help accounts), cualquier
+	  otra @ se queda en las notas (p. ej. cena con @bob), con un aviso
+	  por si era una errata
+	• Objetivo de ahorro: añade #<objetivo> a los ahorros (ver !help goals)
+	• Recibos: envía una foto con el mensaje como pie de foto
+	• Importes: + - * / % y paréntesis, sin espacios
+	• Lotes: varios importes entre corchetes, separados por ;
+	• Varias a la vez: una por línea, no se registra nada si alguna línea es incorrecta
+	• Fechas pasadas: añade @<fecha> (ver abajo)
+	• Repartir en chats de grupo: usa !help groups
+
+Fechas (en tu zona horaria, ver !help config):
+	@today, @yesterday, @mon ... @sun (la última ocurrencia)
+	@12/03, @12/03/2025, @2025-03-12, opcionalmente seguidas de HH:MM
+	p. ej. !add GO 60 Cena @yesterday 19:30
+	`,
+		{Command: Remove}: `
+Comando: remove (alias: rm, r, delete, del, d)
+
+Uso:
+	!rm <ID1> <ID2> ...: Elimina una o varias transacciones por ID
+
+Ejemplos:
+	!rm 42 (Elimina la transacción #42)
+	!rm 42 43 44 (Elimina varias transacciones)
+
+Nota: los IDs se pueden consultar con el comando !ls
+	`,
+		{Command: Edit}: `
+Comando: edit (alias: e, update, u)
+
+Uso:
+	!edit <ID> <categoría> <importe> <notas?> $<moneda?>
+
+Ejemplos:
+	!edit 42 G 50 Mercadona (Cambia #42 por 50 de Supermercado)
+	!edit 42 GO 30 Copas $USD (Cambia #42 por 30 USD de Salidas)
+
+Notas:
+	• Por defecto se mantiene la moneda actual de la transacción
+	• Cada edición queda guardada en el !history de la transacción
+	`,
+		{Command: History}: `
+Comando: history (alias: hist)
+
+Uso:
+	!history <ID>: Muestra cada cambio hecho a una transacción
+
+Ejemplos:
+	!history 42 (Historial de la transacción #42)
+
+Nota:
+	Las transacciones eliminadas conservan su historial.
+	`,
+		{Command: List}: `
+Comando: list (alias: ls, l)
+
+Uso:
+	!ls [opciones]
+
+Opciones (en cualquier orden):
+	<categoría>: Filtra por el alias de la categoría
+	<DD/MM/AAAA>: Desde una fecha concreta
+	<1-100>: Limita el número de resultados (10 por defecto)
+	+: Agrupa por categoría
+	*: Muestra las transacciones de siempre
+	$<CÓDIGO>: Filtra por moneda (p. ej. $USD)
+
+Ejemplos:
+	!ls (Últimas 10 transacciones de este ciclo)
+	!ls G (Todas las transacciones de Supermercado)
+	!ls + 20 (Últimas 20 transacciones agrupadas por categoría)
+	!ls $USD (Todas las transacciones en USD)
+	!ls G $EUR 20 (Últimas 20 transacciones de supermercado en EUR)
+	`,
+		{Command: Configuration}: `
+Comando: config (alias: c, cfg)
+
+Uso:
+	!c set-default-currency <CÓDIGO>: Define tu moneda preferida
+	!c set-timezone <ZONA>: Define la zona horaria de las fechas
+	!c set-language <en|es|pt|auto>: Define el idioma de las respuestas
+	!c set-number-format <FORMATO>: Define cómo se escriben los importes
+	!c reminders <on|off>: Recibe un aviso cuando no hayas registrado nada
+	!c reminders time <HH:MM>: Define cuándo se envían los avisos (20:00 por defecto)
+	!c reminders days <N>: Avisa tras N días sin registrar nada (1 por defecto)
+	!c reminders quiet <HH:MM-HH:MM|off>: Horas en las que no se envían avisos
+
+Alias:
+	• set-default-currency, sdc
+	• set-timezone, stz
+	• set-language, sl
+	• set-number-format, snf
+	• reminders, rem
+
+Formatos de número:
+	• en: 1,234.56 ($1,234.56)
+	• eu: 1.234,56 (1.234,56 €)
+	• br: 1.234,56 (R$ 1.234,56)
+	• fr: 1 234,56 (1 234,56 €)
+	• ch: 1'234.56 (CHF 1'234.56)
+	• auto: el habitual en tu idioma
+
+Ejemplos:
+	!c set-default-currency USD
+	!c sdc NZD
+	!c stz Europe/Madrid
+	!c sl es
+	!c snf eu
+	!c reminders on
+	!c rem time 21:30
+	!c rem quiet 22:00-08:00
+
+Notas:
+	Esta moneda se usa en todas las transacciones en las que no
+	indiques otra. Usa !help currencies para ver los códigos.
+	La zona horaria por defecto es UTC.
+	El idioma sigue el de tu aplicación de Telegram (auto), o inglés si no está disponible.
+	Importes como 12,50 se leen como 12.50 sea cual sea el formato de número.
+	Los avisos siguen tu zona horaria, definir su hora o sus días los activa.
+	`,
+	},
+}
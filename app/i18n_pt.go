@@ -0,0 +1,421 @@
+package app
+
+import . "remind0/db"
+
+/**
+ * Portuguese translations.
+ */
+var portuguese = catalog{
+	phrases: map[string]string{
+		// Replies
+		"✅ Command executed successfully.":                                                  "✅ Comando executado com sucesso.",
+		"🎉 Welcome! Send !help to get started.":                                             "🎉 Bem-vindo! Envie !help para começar.",
+		"⚠️ Failed to process command: %s":                                                  "⚠️ Não foi possível processar o comando: %s",
+		"⚠️ Failed to process command: \n%s":                                                "⚠️ Não foi possível processar o comando: \n%s",
+		"⚠️ Message cannot be empty, exceed %d lines or %d characters per line.":            "⚠️ A mensagem não pode estar vazia nem passar de %d linhas ou %d caracteres por linha.",
+		"⚠️ Sorry, your message couldn't be processed. Please try again later.":             "⚠️ Desculpe, não foi possível processar sua mensagem. Tente novamente mais tarde.",
+		"⛔ You have been blocked from using this bot.":                                      "⛔ Você foi bloqueado e não pode usar este bot.",
+		"⛔ This bot is private. Ask its admin to let your Telegram ID (%d) in.":             "⛔ Este bot é privado. Peça ao administrador para liberar o seu ID do Telegram (%d).",
+		"⛔ This bot is invite-only. Send !join <code> with the invite code you were given.": "⛔ Este bot é só para convidados. Envie !join <código> com o código de convite que você recebeu.",
+		"⛔ This invite code is unknown, already used or expired.":                           "⛔ Este código de convite não existe, já foi usado ou expirou.",
+
+		// Transactions
+		"🪪 ID: %d\n":                        "🪪 ID: %d\n",
+		"📥 Category: %s\n":                  "📥 Categoria: %s\n",
+		"💰 Amount: %s%s\n":                  "💰 Valor: %s%s\n",
+		"📌 Notes: %s\n":                     "📌 Notas: %s\n",
+		"🕒 At: %s\n":                        "🕒 Data: %s\n",
+		"🏦 Account: @%s\n":                  "🏦 Conta: @%s\n",
+		"📎 Attachments: %d (!receipt %d)\n": "📎 Anexos: %d (!receipt %d)\n",
+		"👥 Split: %s\n":                     "👥 Divisão: %s\n",
+		"💰 Total: %s\n":                     "💰 Total: %s\n",
+		"📊 Count: %d\n":                     "📊 Quantidade: %d\n",
+		"• Line %d: %s":                     "• Linha %d: %s",
+		"Nothing was recorded, please fix these lines:\n": "Nada foi registrado, corrija estas linhas:\n",
+		"⚠️ Not an account, kept in the notes: %s\n":      "⚠️ Não é uma conta, ficou nas notas: %s\n",
+
+		// History
+		"%s · %s · via %s\n":       "%s · %s · via %s\n",
+		eventLabels[ActionCreate]:  "➕ Criada",
+		eventLabels[ActionEdit]:    "✏️ Editada",
+		eventLabels[ActionDelete]:  "🗑️ Excluída",
+		"   Category: %s → %s":     "   Categoria: %s → %s",
+		"   Amount: %s %s → %s %s": "   Valor: %s %s → %s %s",
+		"   Notes: %s → %s":        "   Notas: %s → %s",
+		"   At: %s → %s":           "   Data: %s → %s",
+		"   Goal: removed":         "   Meta: removida",
+		"   Goal: changed":         "   Meta: alterada",
+		"   No visible changes\n":  "   Nenhuma mudança visível\n",
+
+		// Groups, debts and accounts
+		"🎉 All settled up!\n":                                              "🎉 Tudo acertado!\n",
+		"• %s owes %s\n":                                                   "• %s deve %s\n",
+		"• %s is owed %s\n":                                                "• %s tem %s a receber\n",
+		"Record a payment with: !settle @member <amount>\n":                "Registre um pagamento com: !settle @membro <valor>\n",
+		"✅ Recorded %s → %s: %s %s":                                        "✅ Registrado %s → %s: %s %s",
+		"🪪 Linked transaction: %d (%s)\n":                                  "🪪 Transação vinculada: %d (%s)\n",
+		"🎉 No outstanding debts!\n":                                        "🎉 Nenhuma dívida pendente!\n",
+		"• %s owes you %s %s\n":                                            "• %s te deve %s %s\n",
+		"• You owe %s %s %s\n":                                             "• Você deve a %s %s %s\n",
+		"• %s and you are even in %s\n":                                    "• Você e %s estão quites em %s\n",
+		"No accounts yet. Use !account add <name> <type> to create one.\n": "Ainda não há contas. Use !account add <nome> <tipo> para criar uma.\n",
+		"Currently supported categories:\n\n":                              "Categorias disponíveis:\n\n",
+
+		// Configuration
+		"✅ Default currency set to %s (%s)":           "✅ Moeda padrão: %s (%s)",
+		"✅ Timezone set to %s (now %s)":               "✅ Fuso horário: %s (agora %s)",
+		"✅ Language set to %s":                        "✅ Idioma: %s",
+		"✅ Language set to follow your Telegram app":  "✅ O idioma vai seguir o do seu aplicativo do Telegram",
+		"✅ Number format set to %s":                   "✅ Formato de número: %s",
+		"✅ Number format set to follow your language": "✅ O formato de número vai seguir o do seu idioma",
+
+		// Reminders
+		"👋 Nothing logged today yet.":           "👋 Você ainda não registrou nada hoje.",
+		"👋 Nothing logged in the last %d days.": "👋 Você não registrou nada nos últimos %d dias.",
+		"Record an expense with e.g. !add G 12.50 Lunch, or turn these reminders off with !c reminders off.": "Registre um gasto com, por exemplo, !add G 12.50 Almoço, ou desative estes lembretes com !c reminders off.",
+		"🔕 Reminders off": "🔕 Lembretes desativados",
+		"🔔 Reminders on: at %s when nothing was logged that day":       "🔔 Lembretes ativados: às %s se nada foi registrado no dia",
+		"🔔 Reminders on: at %s after %d days without logging anything": "🔔 Lembretes ativados: às %s após %d dias sem registrar nada",
+		"🌙 Quiet hours: %s": "🌙 Horário de silêncio: %s",
+		"Use the format: !c reminders on|off|time <HH:MM>|days <N>|quiet <HH:MM-HH:MM|off>. Use !help config for guidance.": "Use o formato: !c reminders on|off|time <HH:MM>|days <N>|quiet <HH:MM-HH:MM|off>. Use !help config para mais ajuda.",
+		"Reminders can't be sent during quiet hours, pick a time outside them.":                                             "Os lembretes não são enviados no horário de silêncio, escolha um horário fora dele.",
+
+		// Errors
+		userErrors[Add]:           "Verifique se a categoria, o valor e a data da transação são válidos. Use !help add para mais ajuda.",
+		userErrors[Remove]:        "Informe IDs de transação válidos. Use !help remove para mais ajuda.",
+		userErrors[List]:          "Confira as opções e tente novamente. Use !help list para mais ajuda.",
+		userErrors[Help]:          "Tente novamente mais tarde ou fale com o suporte.",
+		userErrors[Edit]:          "Use o formato: !edit <ID> <categoria> <valor> <notas?> $<moeda?>. Use !help edit para mais ajuda.",
+		userErrors[Configuration]: "Use o formato: !c set-default-currency <CÓDIGO>. Use !help config para mais ajuda.",
+		userErrors[History]:       "Informe um único ID de transação com histórico. Use !help history para mais ajuda.",
+		userErrors[Settle]:        "Use o formato: !settle ou !settle @membro <valor> $<moeda?>. Use !help groups para mais ajuda.",
+		userErrors[Lend]:          "Use o formato: !lend <pessoa> <valor> <notas?> $<moeda?>. Use !help debts para mais ajuda.",
+		userErrors[Borrow]:        "Use o formato: !borrow <pessoa> <valor> <notas?> $<moeda?>. Use !help debts para mais ajuda.",
+		userErrors[Repay]:         "Verifique se o pagamento não passa do valor devido. Use !help debts para mais ajuda.",
+		userErrors[Debts]:         "Use o formato: !debts <pessoa?>. Use !help debts para mais ajuda.",
+		userErrors[Accounts]:      "Confira o nome e os dados da conta. Use !help accounts para mais ajuda.",
+		userErrors[Receipt]:       "Informe um único ID de transação com arquivos anexados. Use !help receipt para mais ajuda.",
+		userErrors[TransferFunds]: "Use o formato: !transfer <valor> @<origem> @<destino> <notas?>, entre contas da mesma moeda.",
+		userErrors[Invites]:       "Use o formato: !invite <dias?>, válido por até 90 dias. Use !help invite para mais ajuda.",
+		userErrors[Unknown]:       "Algo deu errado, tente novamente mais tarde.",
+		groupOnlyError:            "Isto só está disponível em chats de grupo. Use !help groups para mais ajuda.",
+		splitError:                "Verifique se a divisão é com membros conhecidos e valores válidos. Use !help groups para mais ajuda.",
+		parenthesisedBatchError:   "Os lotes agora são escritos entre colchetes, separados por ponto e vírgula, ex.: [12.5;8]. Use !help add para mais ajuda.",
+		duplicateTransactionError: "Essa transação já estava registrada.",
+
+		"An account with that name already exists.":                                                                            "Já existe uma conta com esse nome.",
+		"Accounts with transactions or transfers can't be removed.":                                                            "Contas com transações ou transferências não podem ser removidas.",
+		"Invalid currency code. Use !help currencies for supported currencies.":                                                "Código de moeda inválido. Use !help currencies para ver as moedas disponíveis.",
+		"Invalid timezone. Use a name like Pacific/Auckland or America/Buenos_Aires.":                                          "Fuso horário inválido. Use um nome como America/Sao_Paulo ou Europe/Lisbon.",
+		"Unsupported language. Use en (English), es (Español), pt (Português) or auto.":                                        "Idioma não disponível. Use en (English), es (Español), pt (Português) ou auto.",
+		"Unsupported number format. Use en (1,234.56), eu (1.234,56), br (R$ 1.234,56), fr (1 234,56), ch (1'234.56) or auto.": "Formato de número não disponível. Use en (1,234.56), eu (1.234,56), br (R$ 1.234,56), fr (1 234,56), ch (1'234.56) ou auto.",
+		"Currencies supported (ISO 4217):\n":                                                                                   "Moedas disponíveis (ISO 4217):\n",
+		"Custom currencies:\n":                                                                                                 "Moedas personalizadas:\n",
+		"• %s - %s (%d decimals)\n":                                                                                            "• %s - %s (%d casas decimais)\n",
+		"Add one with: !currency add <CODE> <decimals> <name>\nRemove it with: !currency rm <CODE>\n":                          "Adicione uma com: !currency add <CÓDIGO> <decimais> <nome>\nRemova com: !currency rm <CÓDIGO>\n",
+		"✅ Added %s (%s) with %d decimals, use it with $%s":                                                                    "✅ %s (%s) adicionada com %d casas decimais, use com $%s",
+		"✅ Removed %s":                                               "✅ %s removida",
+		"That currency already exists.":                              "Essa moeda já existe.",
+		"Currencies in use can't be removed.":                        "Moedas em uso não podem ser removidas.",
+		"Only whoever added a currency, or an admin, can remove it.": "Só quem adicionou a moeda, ou um administrador, pode removê-la.",
+		userErrors[Currencies]:                                       "Use o formato: !currency add <CÓDIGO> <decimais> <nome> ou !currency rm <CÓDIGO>, com até 8 casas decimais. Use !help currencies para mais ajuda.",
+		"Unknown config option. Use !help config for guidance.":      "Opção de configuração desconhecida. Use !help config para mais ajuda.",
+		"Unknown command. Available commands are: add, rm, ls, edit, history, receipt, account, transfer, currency, balances, settle, lend, borrow, repay, debts, goal, bill, buy, sell, price, portfolio, invite, help, config.": "Comando desconhecido. Os comandos disponíveis são: add, rm, ls, edit, history, receipt, account, transfer, currency, balances, settle, lend, borrow, repay, debts, goal, bill, buy, sell, price, portfolio, invite, help, config.",
+
+		// Investments
+		"No holdings yet. Use !buy <instrument> <units> <price> to record one.\n": "Ainda não há investimentos. Use !buy <instrumento> <unidades> <preço> para registrar um.\n",
+		"📕 %s: all sold\n":                                                              "📕 %s: tudo vendido\n",
+		"📈 %s: %s units\n":                                                              "📈 %s: %s unidades\n",
+		"   Cost: %s (%s each)\n":                                                       "   Custo: %s (%s cada)\n",
+		"   No price yet, enter one with !price %s <price>\n":                           "   Sem preço ainda, informe com !price %s <preço>\n",
+		"   Value: %s (%s each, %s)\n":                                                  "   Valor: %s (%s cada, %s)\n",
+		"   Unrealised: %s (%s)\n":                                                      "   Não realizado: %s (%s)\n",
+		"   Realised: %s\n":                                                             "   Realizado: %s\n",
+		"💱 %s: worth %s, unrealised %s, realised %s\n":                                  "💱 %s: vale %s, não realizado %s, realizado %s\n",
+		"   Not counting %d holding(s) without a price\n":                               "   Sem contar %d investimento(s) sem preço\n",
+		"You can't sell more units than you hold.":                                      "Você não pode vender mais unidades do que possui.",
+		"That price makes the holding worth more than can be counted.":                  "Esse preço faz a posição valer mais do que se pode contar.",
+		"Each instrument is traded in a single currency, use the one it was bought in.": "Cada instrumento é negociado em uma única moeda, use aquela em que foi comprado.",
+		userErrors[Buy]:                                                                 "Use o formato: !buy <instrumento> <unidades> <preço> $<moeda?>. Use !help portfolio para mais ajuda.",
+		userErrors[Sell]:                                                                "Use o formato: !sell <instrumento> <unidades> <preço> $<moeda?>. Use !help portfolio para mais ajuda.",
+		userErrors[Prices]:                                                              "Use o formato: !price <instrumento> <preço> $<moeda?>. Use !help portfolio para mais ajuda.",
+		userErrors[Portfolio]:                                                           "Use o formato: !portfolio <instrumento?>. Use !help portfolio para mais ajuda.",
+		"🧾 Trade: %d\n":                                                                 "🧾 Operação: %d\n",
+		"No trade with that ID.":                                                        "Não há nenhuma operação com esse ID.",
+		"Later sales took units from that purchase, remove them first.":                 "Vendas posteriores tiraram unidades dessa compra, remova-as primeiro.",
+		"Trade %d sells more %s than was held at the time, remove it with !trade rm %d.":                           "A operação %d vende mais %s do que havia no momento, remova-a com !trade rm %d.",
+		"Transaction %d was recorded by trade %d, remove it with !trade rm %d instead.":                            "A transação %d foi registrada pela operação %d, remova-a com !trade rm %d.",
+		"Transaction %d was recorded by trade %d, remove it with !trade rm %d and record the trade again instead.": "A transação %d foi registrada pela operação %d, remova-a com !trade rm %d e registre a operação novamente.",
+		userErrors[Trades]: "Use o formato: !trade rm <id>. Use !help portfolio para mais ajuda.",
+
+		// Goals
+		"🎯 Goal: #%s\n": "🎯 Meta: #%s\n",
+		"No goals yet. Use !goal add <name> <target> <deadline?> to create one.\n": "Ainda não há metas. Use !goal add <nome> <valor> <prazo?> para criar uma.\n",
+		"   Saved: %s of %s\n":                           "   Guardado: %s de %s\n",
+		"   🎉 Target reached!\n":                         "   🎉 Meta alcançada!\n",
+		"   Deadline: %s, missed\n":                      "   Prazo: %s, vencido\n",
+		"   Deadline: %s, save %s a month\n":             "   Prazo: %s, guarde %s por mês\n",
+		"   At this pace: reached by %s\n":               "   Neste ritmo: alcançada em %s\n",
+		"   Save towards it with: !add S <amount> #%s\n": "   Guarde para ela com: !add S <valor> #%s\n",
+		"A goal with that name already exists.":          "Já existe uma meta com esse nome.",
+		userErrors[Goals]:                                "Verifique o nome, o valor e o prazo da meta (ex. 12/2027). Use !help goals para mais ajuda.",
+
+		// Bills
+		"No bills yet. Use !bill add <name> <amount> due <day> to add one.\n": "Ainda não há contas. Use !bill add <nome> <valor> due <dia> para adicionar uma.\n",
+		"   Next due: %s, reminder %d day(s) before\n":                        "   Próximo vencimento: %s, aviso %d dia(s) antes\n",
+		"🧾 %s (%s) is due in %d day(s), on %s.":                               "🧾 %s (%s) vence em %d dia(s), em %s.",
+		"🧾 %s (%s) is due today.":                                             "🧾 %s (%s) vence hoje.",
+		"🧾 %s (%s) was due on %s.":                                            "🧾 %s (%s) venceu em %s.",
+		"Tap Paid once it's paid to record it as %s, or use !bill paid %s.":   "Toque em Paga quando pagar para registrá-la como %s, ou use !bill paid %s.",
+		"✅ Paid": "✅ Paga",
+		"✅ Paid, recorded as transaction %d. Next due on %s.": "✅ Paga, registrada como a transação %d. Próximo vencimento em %s.",
+		"That bill no longer exists.":                         "Essa conta não existe mais.",
+		"That bill was already paid or skipped.":              "Essa conta já foi paga ou pulada.",
+		"A bill with that name already exists.":               "Já existe uma conta com esse nome.",
+		userErrors[Bills]:                                     "Use o formato: !bill add <nome> <valor> due <dia> <U|R|SUB?> <remind N?> $<moeda?>. Use !help bills para mais ajuda.",
+
+		// Receipts, invites and admin
+		"📎 Attached %d file(s) to transaction %d.":                                        "📎 %d arquivo(s) anexado(s) à transação %d.",
+		"📎 %d file(s) for transaction %d.":                                                "📎 %d arquivo(s) da transação %d.",
+		"You already have access, no invite code needed.":                                 "Você já tem acesso, não precisa de código de convite.",
+		"Only admins can create invite codes.":                                            "Só administradores podem criar códigos de convite.",
+		"Invite code: %s\nValid once, until %s.\n\nThe new user sends the bot:\n!join %s": "Código de convite: %s\nVálido uma vez, até %s.\n\nO novo usuário envia ao bot:\n!join %s",
+		"Only admins can use this command.":                                               "Só administradores podem usar este comando.",
+		"Unknown user. Use their @username or Telegram ID, see !admin users.":             "Usuário desconhecido. Use o @usuário ou o ID do Telegram, veja !admin users.",
+		"Admins can't be blocked.":                                                        "Administradores não podem ser bloqueados.",
+		"Blocked %s (%d).":                                                                "Bloqueado %s (%d).",
+		"Unblocked %s (%d).":                                                              "Desbloqueado %s (%d).",
+		"Sending the announcement to %d user(s), you'll be told once it's done.":          "Enviando o anúncio para %d usuário(s), você será avisado quando terminar.",
+		"📣 Announcement delivered to %d of %d user(s).":                                   "📣 Anúncio entregue a %d de %d usuário(s).",
+		"📣 Announcement interrupted by a restart, delivered to %d of %d user(s).":         "📣 Anúncio interrompido por uma reinicialização, entregue a %d de %d usuário(s).",
+	},
+
+	headers: map[Command]string{
+		Add:           "✅ Despesa registrada",
+		Remove:        "✂️ Despesa excluída",
+		List:          "📋 Transações",
+		Help:          "💡 Ajuda",
+		Edit:          "📝 Despesa atualizada",
+		Configuration: "⚙️ Configuração",
+		History:       "🕓 Histórico da transação",
+		Balances:      "⚖️ Saldos",
+		Settle:        "🤝 Acertar contas",
+		Lend:          "📤 Empréstimo registrado",
+		Borrow:        "📥 Dívida registrada",
+		Repay:         "💸 Pagamento registrado",
+		Debts:         "🧾 Dívidas",
+		Accounts:      "🏦 Contas",
+		TransferFunds: "🔁 Transferência registrada",
+		Receipt:       "🧾 Recibos",
+		Invites:       "🎟️ Convites",
+		Currencies:    "💱 Moedas",
+		Buy:           "🛒 Compra registrada",
+		Sell:          "💰 Venda registrada",
+		Prices:        "🏷️ Preço atualizado",
+		Portfolio:     "📊 Carteira",
+		Trades:        "🗑️ Operação removida",
+		Goals:         "🎯 Metas de economia",
+		Bills:         "🧾 Contas a pagar",
+	},
+
+	categories: map[string]string{
+		"Income":           "Renda",
+		"Savings":          "Poupança",
+		"Utilities":        "Contas da casa",
+		"Subscriptions":    "Assinaturas",
+		"Rent":             "Aluguel",
+		"Health & Fitness": "Saúde e exercício",
+		"Transport":        "Transporte",
+		"Groceries":        "Mercado",
+		"Going Out":        "Saídas",
+		"Investment":       "Investimentos",
+		"Shopping":         "Compras",
+		"Education":        "Educação",
+		"Travel":           "Viagens",
+		"Miscellaneous":    "Diversos",
+	},
+
+	help: map[HelpTopic]string{
+		{Command: Help}: `
+Comando: help (atalhos: h)
+
+Uso:
+	!help: Mostra este menu de ajuda
+	!help <comando>: Mostra a ajuda detalhada de um comando
+	!help categories: Lista as categorias disponíveis
+	!help currencies: Lista as moedas disponíveis
+	!help groups: Divida despesas em chats de grupo
+
+Comandos:
+	• !add <categoria> <valor> <notas?> $<moeda?> - Registra uma despesa ou receita
+	• !ls [opções] - Mostra suas transações
+	• !rm <ID1> <ID2> ... - Exclui transações
+	• !edit <ID> <categoria> <valor> <notas?> - Edita uma transação
+	• !lend / !borrow <pessoa> <valor> - Acompanha quem deve a quem
+	• !debts - Mostra as dívidas pendentes
+	• !receipt <ID> - Recupera os recibos de uma transação
+	• !account add <nome> <tipo> - Acompanha o saldo de uma conta
+	• !currency add <CÓDIGO> <decimais> <nome> - Adiciona uma moeda (ex.: BTC)
+	• !balances - Mostra os saldos das contas
+	• !buy / !sell <instrumento> <unidades> <preço> - Acompanhe seus investimentos
+	• !portfolio - Mostra seus investimentos, seu valor e ganhos
+	• !goal add <nome> <valor> <prazo?> - Guarde para uma meta
+	• !bill add <nome> <valor> due <dia> - Receba avisos de uma conta
+	• !history <ID> - Mostra as mudanças de uma transação
+	• !c set-default-currency <CÓDIGO> - Define sua moeda preferida
+	• !c set-language <en|es|pt> - Define o idioma das respostas
+	• !help - Mostra este menu de ajuda
+
+Exemplos rápidos:
+	• !add G 45 Almoço $USD
+	• !ls $USD 20
+	• !c set-default-currency BRL
+
+Mais ajuda:
+	• Digite !help <comando> para ver o uso detalhado
+	• Digite !help categories para ver as categorias
+	• Digite !help currencies para ver as moedas
+	`,
+		{Command: Add}: `
+Comando: add (atalhos: a)
+
+Uso:
+	!add <categoria> <valor ou [n;n]> <notas?> @<conta?> @<data?> $<moeda?>
+
+Exemplos:
+	!add G 45 Supermercado (45 na sua moeda padrão)
+	!add G 45 Supermercado $USD (45 USD)
+	!add G [2.5;8] Feira $EUR (2.5 e 8 EUR)
+	!add GO 12.5+8*2 Bebidas (28.50)
+	!add SH 45-5% Sapatos (42.75, com 5% de desconto)
+	!add SH -20 Reembolso (Valores negativos também funcionam)
+	!add G 45 Supermercado
+	GO 12 Café @visa (Uma transação por linha)
+
+Notas:
+	• Categorias: use !help categories para ver a lista
+	• Moedas: use !help currencies para ver a lista
+	• Defina sua moeda padrão: !c set-default-currency USD
+	• Conta de origem ou destino: adicione @<conta> (veja !help accounts), qualquer
+	  outro @ fica nas notas (ex.: jantar com @bob), com um aviso
+	  caso tenha sido um erro de digitação
+	• Meta de economia: adicione #<meta> à poupança (veja !help goals)
+	• Recibos: envie uma foto com a mensagem na legenda
+	• Valores: + - * / % e parênteses, sem espaços
+	• Lotes: vários valores entre colchetes, separados por ;
+	• Várias de uma vez: uma por linha, nada é registrado se alguma linha estiver errada
+	• Datas passadas: adicione @<data> (veja abaixo)
+	• Dividir em chats de grupo: use !help groups
+
+Datas (no seu fuso horário, veja !help config):
+	@today, @yesterday, @mon ... @sun (a última ocorrência)
+	@12/03, @12/03/2025, @2025-03-12, opcionalmente seguidas de HH:MM
+	ex.: !add GO 60 Jantar @yesterday 19:30
+	`,
+		{Command: Remove}: `
+Comando: remove (atalhos: rm, r, delete, del, d)
+
+Uso:
+	!rm <ID1> <ID2> ...: Exclui uma ou mais transações pelo ID
+
+Exemplos:
+	!rm 42 (Exclui a transação #42)
+	!rm 42 43 44 (Exclui várias transações)
+
+Nota: os IDs aparecem no comando !ls
+	`,
+		{Command: Edit}: `
+Comando: edit (atalhos: e, update, u)
+
+Uso:
+	!edit <ID> <categoria> <valor> <notas?> $<moeda?>
+
+Exemplos:
+	!edit 42 G 50 Supermercado (Troca #42 por 50 de Mercado)
+	!edit 42 GO 30 Bebidas $USD (Troca #42 por 30 USD de Saídas)
+
+Notas:
+	• A moeda padrão é a atual da transação
+	• Cada edição fica guardada no !history da transação
+	`,
+		{Command: History}: `
+Comando: history (atalhos: hist)
+
+Uso:
+	!history <ID>: Mostra cada mudança feita em uma transação
+
+Exemplos:
+	!history 42 (Histórico da transação #42)
+
+Nota:
+	Transações excluídas mantêm o histórico.
+	`,
+		{Command: List}: `
+Comando: list (atalhos: ls, l)
+
+Uso:
+	!ls [opções]
+
+Opções (em qualquer ordem):
+	<categoria>: Filtra pelo atalho da categoria
+	<DD/MM/AAAA>: A partir de uma data
+	<1-100>: Limita o número de resultados (10 por padrão)
+	+: Agrupa por categoria
+	*: Mostra as transações de todos os tempos
+	$<CÓDIGO>: Filtra por moeda (ex.: $USD)
+
+Exemplos:
+	!ls (Últimas 10 transações deste ciclo)
+	!ls G (Todas as transações de Mercado)
+	!ls + 20 (Últimas 20 transações agrupadas por categoria)
+	!ls $USD (Todas as transações em USD)
+	!ls G $EUR 20 (Últimas 20 transações de mercado em EUR)
+	`,
+		{Command: Configuration}: `
+Comando: config (atalhos: c, cfg)
+
+Uso:
+	!c set-default-currency <CÓDIGO>: Define sua moeda preferida
+	!c set-timezone <FUSO>: Define o fuso horário das datas
+	!c set-language <en|es|pt|auto>: Define o idioma das respostas
+	!c set-number-format <FORMATO>: Define como os valores são escritos
+	!c reminders <on|off>: Receba um aviso quando não tiver registrado nada
+	!c reminders time <HH:MM>: Define quando os avisos são enviados (20:00 por padrão)
+	!c reminders days <N>: Avisa após N dias sem registrar nada (1 por padrão)
+	!c reminders quiet <HH:MM-HH:MM|off>: Horário em que nenhum aviso é enviado
+
+Atalhos:
+	• set-default-currency, sdc
+	• set-timezone, stz
+	• set-language, sl
+	• set-number-format, snf
+	• reminders, rem
+
+Formatos de número:
+	• en: 1,234.56 ($1,234.56)
+	• eu: 1.234,56 (1.234,56 €)
+	• br: 1.234,56 (R$ 1.234,56)
+	• fr: 1 234,56 (1 234,56 €)
+	• ch: 1'234.56 (CHF 1'234.56)
+	• auto: o usual no seu idioma
+
+Exemplos:
+	!c set-default-currency BRL
+	!c sdc EUR
+	!c stz America/Sao_Paulo
+	!c sl pt
+	!c snf br
+	!c reminders on
+	!c rem time 21:30
+	!c rem quiet 22:00-08:00
+
+Notas:
+	Esta moeda é usada em todas as transações em que você não
+	indicar outra. Use !help currencies para ver os códigos.
+	O fuso horário padrão é UTC.
+	O idioma segue o do seu aplicativo do Telegram (auto), ou inglês se não estiver disponível.
+	Valores como 12,50 são lidos como 12.50 em qualquer formato de número.
+	Os avisos seguem o seu fuso horário, definir o horário ou os dias os ativa.
+	`,
+	},
+}
@@ -0,0 +1,186 @@
+package app
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	. "remind0/db"
+	"remind0/monitoring"
+	r "remind0/repository"
+	"time"
+
+	telegramClient "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+/**
+ *  _       _
+ * (_)     | |
+ *  _ _ __ | |__   _____  __
+ * | | '_ \| '_ \ / _ \ \/ /
+ * | | | | | |_) | (_) >  <
+ * |_|_| |_|_.__/ \___/_/\_\
+ *
+ * Updates are stored as soon as they arrive and handled from there, so a
+ * crash halfway through handling one doesn't lose it. An update is marked
+ * as done in the same database transaction as its effects: it's either
+ * fully handled or it'll be handled again.
+ */
+
+const (
+	maxUpdateAttempts = 5               // Attempts before giving up on an update.
+	updateRetryDelay  = 1 * time.Minute // Grows with each failed attempt.
+	inboxBatchSize    = 100
+)
+
+/**
+ * Store an incoming update, moving the offset past it.
+ */
+func ReceiveUpdate(offset *Offset, update telegramClient.Update) error {
+	payload, err := json.Marshal(update)
+	if err != nil {
+		return err
+	}
+
+	var chatID int64
+	if chat := update.FromChat(); chat != nil {
+		chatID = chat.ID
+	}
+
+	err = r.InboxRepo().Receive(offset, &InboxUpdate{
+		UpdateID:      update.UpdateID,
+		Payload:       string(payload),
+		ChatID:        chatID,
+		Status:        InboxPending,
+		NextAttemptAt: time.Now(),
+	})
+	if err == nil {
+		monitoring.UpdateReceived()
+	}
+	return err
+}
+
+/**
+ * Hand the stored updates that are due over to the workers, oldest first.
+ */
+func ProcessInbox(pool *UpdatePool) {
+	items, err := r.InboxRepo().GetDue(time.Now(), inboxBatchSize)
+	if err != nil {
+		slog.Error("Error reading the inbox", "error", err)
+		return
+	}
+
+	for _, item := range items {
+		pool.Submit(item)
+	}
+}
+
+/**
+ * Handle a stored update, recording the failure if it couldn't be.
+ * Returns whether it was handled.
+ */
+func processUpdate(bot *telegramClient.BotAPI, item *InboxUpdate) bool {
+	var update telegramClient.Update
+	err := json.Unmarshal([]byte(item.Payload), &update)
+	if err == nil {
+		err = handleUpdate(bot, update, item)
+	}
+	if err == nil {
+		monitoring.UpdateHandled("done")
+		return true
+	}
+
+	logger := slog.With("update_id", item.UpdateID, "chat_id", item.ChatID)
+	if errors.Is(err, r.ErrNotPending) {
+		// Read as due just before an earlier attempt finished with it.
+		logger.Info("Skipped update already handled")
+		return true
+	}
+	logger.Error("Error handling update", "attempt", item.Attempts+1, "error", err)
+
+	retryAt := time.Now().Add(time.Duration(item.Attempts+1) * updateRetryDelay)
+	if err := r.InboxRepo().MarkFailed(item, err, maxUpdateAttempts, retryAt); errors.Is(err, r.ErrNotPending) {
+		logger.Info("Skipped recording failure of update already handled")
+		return true
+	} else if err != nil {
+		logger.Error("Error recording failure of update", "error", err)
+		return false
+	}
+
+	if item.Status != InboxDead {
+		monitoring.UpdateHandled("failed")
+		return false
+	}
+	monitoring.UpdateHandled("dead")
+	logger.Error("Gave up on update", "attempts", item.Attempts)
+	var failed *commandError
+	if errors.As(err, &failed) {
+		monitoring.CommandHandled(string(failed.command), "error")
+	}
+	if update.Message != nil && update.Message.Chat != nil {
+		lang := userLanguage(nil, update.Message.From)
+		bot.Send(telegramClient.NewMessage(update.Message.Chat.ID, lang.T("⚠️ Sorry, your message couldn't be processed. Please try again later.")))
+	}
+	return false
+}
+
+/**
+ * Handle an update and mark it as done, all in a single database transaction,
+ * then send the replies. Panics are turned into errors so the update is
+ * tried again.
+ */
+func handleUpdate(bot *telegramClient.BotAPI, update telegramClient.Update, item *InboxUpdate) (err error) {
+	defer func() {
+		if p := recover(); p != nil {
+			err = fmt.Errorf("panic: %v", p)
+		}
+	}()
+
+	outbox := &Outbox{bot: bot}
+	err = r.Atomically(func(repos *r.Repositories) error {
+		if update.Message != nil {
+			if err := HandleTelegramMessage(outbox, update, repos); err != nil {
+				return err
+			}
+		}
+		if update.CallbackQuery != nil {
+			if err := HandleCallbackQuery(outbox, update, repos); err != nil {
+				return err
+			}
+		}
+		return repos.InboxRepo().MarkDone(item)
+	})
+	if err != nil {
+		return err
+	}
+
+	outbox.flush()
+	return nil
+}
+
+/**
+ * Replies, and anything else to do once an update is handled, held back
+ * until its transaction commits: an update rolled back is handled again,
+ * and would otherwise be answered twice.
+ */
+type Outbox struct {
+	bot     *telegramClient.BotAPI
+	pending []func()
+}
+
+// Queue a message, or any other request to Telegram.
+func (outbox *Outbox) Send(c telegramClient.Chattable) {
+	outbox.Then(func() { outbox.bot.Request(c) })
+}
+
+// Queue something else to do once committed.
+func (outbox *Outbox) Then(action func()) {
+	outbox.pending = append(outbox.pending, action)
+}
+
+func (outbox *Outbox) flush() {
+	for _, action := range outbox.pending {
+		action()
+	}
+	outbox.pending = nil
+}
@@ -0,0 +1,626 @@
+package app
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+	"regexp"
+	. "remind0/db"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+/**
+ *  _                     _
+ * (_)_ ____   _____  ___| |_
+ * | | '_ \ \ / / _ \/ __| __|
+ * | | | | \ V /  __/\__ \ |_
+ * |_|_| |_|\_/ \___||___/\__|
+ *
+ * What the money going into investments bought: holdings of shares, funds
+ * or crypto, their cost basis (first in, first out) and what they're worth
+ * at the latest known price.
+ */
+
+// Units are kept in hundred-millionths, enough for fractional shares and satoshis.
+const (
+	unitDecimals = 8
+	unitScale    = 100_000_000
+)
+
+// Tickers or short names (e.g. AAPL, BRK.B, VWRL.L or BTC-USD).
+var instrumentPattern = regexp.MustCompile(`^[A-Z0-9][A-Z0-9.:_-]{0,19}$`)
+
+type Holding struct {
+	Instrument string
+	Currency   string
+	Units      int64    // Held, in hundred-millionths of a unit.
+	Cost       int64    // Cost basis of the units held, in minor units.
+	Realised   int64    // Gains made selling, in minor units.
+	Price      *big.Rat // Latest price per unit, nil when unknown.
+	PricedAt   time.Time
+}
+
+// Worth of the units held at the latest price, in minor units.
+func (h Holding) Value() (int64, error) {
+	if h.Price == nil {
+		return 0, nil
+	}
+	value := new(big.Rat).Mul(h.Price, big.NewRat(h.Units, unitScale))
+	return toMinorUnits(value, h.Currency)
+}
+
+// A sale of more units than were held at the time.
+type oversoldError struct {
+	trade *Trade
+	err   error
+}
+
+func (e *oversoldError) Error() string {
+	return fmt.Sprintf("trade %d of %s: %v", e.trade.ID, e.trade.Instrument, e.err)
+}
+
+func (e *oversoldError) Unwrap() error {
+	return e.err
+}
+
+// Part of a purchase not sold yet.
+type lot struct {
+	units int64
+	cost  int64
+}
+
+/**
+ * Apply the trades of a user in order: buys add lots, sells take units
+ * from the oldest lots first, along with their share of the cost.
+ */
+func computeHoldings(trades []*Trade) ([]*Holding, error) {
+	holdings := map[string]*Holding{}
+	lots := map[string][]lot{}
+
+	for _, trade := range trades {
+		holding := holdings[trade.Instrument]
+		if holding == nil {
+			holding = &Holding{Instrument: trade.Instrument, Currency: trade.Currency}
+			holdings[trade.Instrument] = holding
+		}
+
+		if trade.Kind == TradeBuy {
+			lots[trade.Instrument] = append(lots[trade.Instrument], lot{units: trade.Units, cost: trade.Amount})
+			holding.Units += trade.Units
+			holding.Cost += trade.Amount
+			continue
+		}
+
+		cost, remaining, err := takeLots(lots[trade.Instrument], trade.Units)
+		if err != nil {
+			return nil, &oversoldError{trade: trade, err: err}
+		}
+		lots[trade.Instrument] = remaining
+		holding.Units -= trade.Units
+		holding.Cost -= cost
+		holding.Realised += trade.Amount - cost
+	}
+
+	result := make([]*Holding, 0, len(holdings))
+	for _, holding := range holdings {
+		result = append(result, holding)
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Instrument < result[j].Instrument })
+	return result, nil
+}
+
+/**
+ * Take units from the oldest lots, returning their cost and the lots left.
+ * Part of a lot costs its share of the lot's cost, so that selling every
+ * unit of a lot in several goes adds up to exactly what it cost.
+ */
+func takeLots(lots []lot, units int64) (int64, []lot, error) {
+	var cost int64
+	for units > 0 {
+		if len(lots) == 0 {
+			return 0, nil, errors.New("selling more units than held")
+		}
+		oldest := &lots[0]
+		if units >= oldest.units {
+			cost += oldest.cost
+			units -= oldest.units
+			lots = lots[1:]
+			continue
+		}
+		share := new(big.Rat).Mul(big.NewRat(oldest.cost, 1), big.NewRat(units, oldest.units))
+		taken := roundRat(share)
+		cost += taken
+		oldest.cost -= taken
+		oldest.units -= units
+		units = 0
+	}
+	return cost, lots, nil
+}
+
+/**
+ * Holdings can't be worked out while a sale is of more units than were held
+ * at the time, which removing that sale fixes.
+ */
+func holdingsFailure(command Command, err error, ctx MessageContext) CommandResult {
+	var oversold *oversoldError
+	if !errors.As(err, &oversold) {
+		return CommandResult{Command: command, Error: err, UserError: userErrors[Unknown]}
+	}
+	trade := oversold.trade
+	return CommandResult{Command: command, Error: err, UserError: ctx.Language.Tf(
+		"Trade %d sells more %s than was held at the time, remove it with !trade rm %d.", trade.ID, trade.Instrument, trade.ID,
+	)}
+}
+
+func roundRat(value *big.Rat) int64 {
+	rounded, _ := new(big.Rat).SetString(value.FloatString(0))
+	return rounded.Num().Int64()
+}
+
+// Parse a positive number of units, with up to 8 decimals.
+func parseUnits(units string, numbers NumberFormat) (int64, error) {
+	value, err := numbers.parseNumber(units)
+	if err != nil {
+		return 0, err
+	}
+	scaled := new(big.Rat).Mul(value, big.NewRat(unitScale, 1))
+	if !scaled.IsInt() || scaled.Sign() <= 0 || !scaled.Num().IsInt64() {
+		return 0, fmt.Errorf("invalid units %q", units)
+	}
+	return scaled.Num().Int64(), nil
+}
+
+// Parse a positive price per unit.
+func parsePrice(price string, numbers NumberFormat) (*big.Rat, error) {
+	value, err := numbers.parseNumber(price)
+	if err != nil {
+		return nil, err
+	}
+	if value.Sign() <= 0 {
+		return nil, fmt.Errorf("invalid price %q", price)
+	}
+	return value, nil
+}
+
+/**
+ * A decimal with as many digits as it needs between the given bounds
+ * (e.g. 185.2 with 2 to 8 is 185.20, 0.123456 stays as is).
+ */
+func decimalString(value *big.Rat, minDecimals int, maxDecimals int) string {
+	plain := value.FloatString(maxDecimals)
+	if maxDecimals == 0 {
+		return plain
+	}
+	whole, fraction, _ := strings.Cut(plain, ".")
+	fraction = strings.TrimRight(fraction, "0")
+	for len(fraction) < minDecimals {
+		fraction += "0"
+	}
+	if fraction == "" {
+		return whole
+	}
+	return whole + "." + fraction
+}
+
+func formatUnits(units int64, numbers NumberFormat) string {
+	return numbers.formatDecimal(decimalString(big.NewRat(units, unitScale), 0, unitDecimals))
+}
+
+// Price per unit, with more decimals than the currency has when needed.
+func formatPrice(price *big.Rat, currency string, numbers NumberFormat) string {
+	return numbers.withSymbol(numbers.formatDecimal(decimalString(price, currencyExponent(currency), unitDecimals)), currency)
+}
+
+/**
+ * Prices
+ *
+ * Holdings are valued at the latest price known for them: entered by the
+ * user with !price, or read from the prices file, which every user shares.
+ * The file is a CSV of instrument, price, currency and optionally the date
+ * (YYYY-MM-DD) it's from, its modification time otherwise. It's read again
+ * on every valuation, so a scheduled job can keep it up to date.
+ */
+
+var pricesFile string
+
+/**
+ * Apply the prices settings of the configuration.
+ */
+func ConfigurePrices(config *Config) {
+	pricesFile = config.Prices.File
+}
+
+type quote struct {
+	price *big.Rat
+	at    time.Time
+}
+
+type quoteKey struct{ instrument, currency string }
+
+func readPricesFile(path string) (map[quoteKey]quote, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	reader.TrimLeadingSpace = true
+
+	quotes := map[quoteKey]quote{}
+	for line := 1; ; line++ {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if line == 1 && strings.EqualFold(record[0], "instrument") {
+			continue
+		}
+		if len(record) < 3 || len(record) > 4 {
+			return nil, fmt.Errorf("line %d: expected instrument, price, currency and date?", line)
+		}
+
+		price, ok := new(big.Rat).SetString(strings.TrimSpace(record[1]))
+		if !ok || price.Sign() <= 0 || !digitsPattern.MatchString(strings.TrimSpace(record[1])) {
+			return nil, fmt.Errorf("line %d: invalid price %q", line, record[1])
+		}
+
+		at := info.ModTime()
+		if len(record) == 4 && strings.TrimSpace(record[3]) != "" {
+			if at, err = time.Parse(time.DateOnly, strings.TrimSpace(record[3])); err != nil {
+				return nil, fmt.Errorf("line %d: invalid date %q", line, record[3])
+			}
+		}
+
+		key := quoteKey{strings.ToUpper(strings.TrimSpace(record[0])), strings.ToUpper(strings.TrimSpace(record[2]))}
+		quotes[key] = quote{price: price, at: at}
+	}
+	return quotes, nil
+}
+
+/**
+ * Latest prices known to a user, of both sources the newest winning.
+ */
+func latestQuotes(ctx MessageContext) (map[quoteKey]quote, error) {
+	quotes := map[quoteKey]quote{}
+
+	if pricesFile != "" {
+		fromFile, err := readPricesFile(pricesFile)
+		if err != nil {
+			// Valuing with the manual prices beats not answering at all.
+			ctx.Log.Warn("Prices file unreadable", "path", pricesFile, "error", err)
+		}
+		for key, q := range fromFile {
+			quotes[key] = q
+		}
+	}
+
+	prices, err := ctx.Repos.InvestmentRepo().GetPrices(ctx.UserID)
+	if err != nil {
+		return nil, err
+	}
+	for _, price := range prices {
+		value, ok := new(big.Rat).SetString(price.Price)
+		if !ok {
+			continue
+		}
+		key := quoteKey{price.Instrument, price.Currency}
+		if existing, found := quotes[key]; !found || price.UpdatedAt.After(existing.at) {
+			quotes[key] = quote{price: value, at: price.UpdatedAt}
+		}
+	}
+
+	return quotes, nil
+}
+
+/**
+ * Commands
+ */
+
+/**
+ * Record units of an instrument bought or sold, along with the money it
+ * took or brought as an investment transaction.
+ * Format: <instrument> <units> <price> $<currency?>
+ */
+func trade(kind TradeKind, args []string, ctx MessageContext) CommandResult {
+	command := Buy
+	if kind == TradeSell {
+		command = Sell
+	}
+
+	if len(args) < 3 || len(args) > 4 {
+		return CommandResult{Command: command, Error: fmt.Errorf("invalid arguments: %v", args), UserError: userErrors[command]}
+	}
+
+	instrument := strings.ToUpper(args[0])
+	if !instrumentPattern.MatchString(instrument) {
+		return CommandResult{Command: command, Error: fmt.Errorf("invalid instrument %q", args[0]), UserError: userErrors[command]}
+	}
+
+	user, err := ctx.Repos.UserRepo().GetByID(ctx.UserID)
+	if err != nil {
+		return CommandResult{Command: command, Error: err, UserError: userErrors[Unknown]}
+	}
+
+	trades, err := ctx.Repos.InvestmentRepo().GetTrades(ctx.UserID, instrument)
+	if err != nil {
+		return CommandResult{Command: command, Error: err, UserError: userErrors[Unknown]}
+	}
+	holdings, err := computeHoldings(trades)
+	if err != nil {
+		return holdingsFailure(command, err, ctx)
+	}
+
+	// An instrument is traded in a single currency, the one it was first bought in.
+	held := &Holding{Instrument: instrument}
+	rest, currency := extractCurrency(args[1:], user.PreferredCurrency)
+	if len(holdings) == 1 {
+		held = holdings[0]
+		rest, currency = extractCurrency(args[1:], held.Currency)
+	}
+	if held.Currency != "" && currency != held.Currency {
+		return CommandResult{Command: command, Error: fmt.Errorf("%s is traded in %s, not %s", instrument, held.Currency, currency), UserError: "Each instrument is traded in a single currency, use the one it was bought in."}
+	}
+	if len(rest) != 2 {
+		return CommandResult{Command: command, Error: fmt.Errorf("invalid arguments: %v", rest), UserError: userErrors[command]}
+	}
+
+	units, err := parseUnits(rest[0], ctx.Numbers)
+	if err != nil {
+		return CommandResult{Command: command, Error: err, UserError: userErrors[command]}
+	}
+	price, err := parsePrice(rest[1], ctx.Numbers)
+	if err != nil {
+		return CommandResult{Command: command, Error: err, UserError: userErrors[command]}
+	}
+
+	if kind == TradeSell && units > held.Units {
+		return CommandResult{Command: command, Error: fmt.Errorf("selling %d of %d units of %s", units, held.Units, instrument), UserError: "You can't sell more units than you hold."}
+	}
+
+	amount, err := toMinorUnits(new(big.Rat).Mul(price, big.NewRat(units, unitScale)), currency)
+	if err != nil {
+		return CommandResult{Command: command, Error: err, UserError: userErrors[command]}
+	}
+	if amount <= 0 {
+		return CommandResult{Command: command, Error: fmt.Errorf("trade of %s worth nothing", instrument), UserError: userErrors[command]}
+	}
+
+	/**
+	 * Money going into investments is spending, money coming out of them a refund.
+	 */
+	category, _ := findCategory("INV")
+	notes := fmt.Sprintf("Buy %s %s @ %s", decimalString(big.NewRat(units, unitScale), 0, unitDecimals), instrument, decimalString(price, currencyExponent(currency), unitDecimals))
+	signed := amount
+	if kind == TradeSell {
+		notes = "Sell" + strings.TrimPrefix(notes, "Buy")
+		signed = -amount
+	}
+
+	// Identical trades sent in the same second follow different ones.
+	var latest uint
+	for _, earlier := range trades {
+		latest = max(latest, earlier.ID)
+	}
+
+	tx := &Transaction{
+		Hash:      generateRecordHash(fmt.Sprintf("trade after %d", latest), category, signed, notes, ctx.Timestamp, ctx.UserID, currency),
+		Notes:     notes,
+		UserID:    ctx.UserID,
+		Amount:    signed,
+		Currency:  currency,
+		Category:  category,
+		Timestamp: ctx.Timestamp,
+	}
+	record := &Trade{
+		UserID:     ctx.UserID,
+		Instrument: instrument,
+		Kind:       kind,
+		Units:      units,
+		Amount:     amount,
+		Currency:   currency,
+		Timestamp:  ctx.Timestamp,
+	}
+	if err := ctx.Repos.InvestmentRepo().RecordTrade(record, tx, SourceChat); err != nil {
+		return CommandResult{Command: command, Error: err, UserError: userErrors[Unknown]}
+	}
+
+	result := portfolio([]string{instrument}, ctx, command)
+	if result.Error == nil {
+		result.Trade, result.Transactions = record, []*Transaction{tx}
+	}
+	return result
+}
+
+/**
+ * Manage the trades recorded.
+ * Format: rm <id>
+ */
+func trades(args []string, ctx MessageContext) CommandResult {
+	if len(args) != 2 || strings.ToLower(args[0]) != "rm" {
+		return CommandResult{Command: Trades, Error: fmt.Errorf("invalid arguments: %v", args), UserError: userErrors[Trades]}
+	}
+	return removeTrade(args[1], ctx)
+}
+
+/**
+ * Remove a trade along with the investment transaction recorded with it,
+ * as long as no later sale took units from it.
+ */
+func removeTrade(strId string, ctx MessageContext) CommandResult {
+	id, err := strconv.ParseUint(strId, 10, 64)
+	if err != nil {
+		return CommandResult{Command: Trades, Error: fmt.Errorf("invalid trade ID %q", strId), UserError: userErrors[Trades]}
+	}
+
+	trade, err := ctx.Repos.InvestmentRepo().GetTrade(uint(id), ctx.UserID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return CommandResult{Command: Trades, Error: err, UserError: "No trade with that ID."}
+	}
+	if err != nil {
+		return CommandResult{Command: Trades, Error: err, UserError: userErrors[Unknown]}
+	}
+
+	trades, err := ctx.Repos.InvestmentRepo().GetTrades(ctx.UserID, trade.Instrument)
+	if err != nil {
+		return CommandResult{Command: Trades, Error: err, UserError: userErrors[Unknown]}
+	}
+	remaining := make([]*Trade, 0, len(trades))
+	for _, t := range trades {
+		if t.ID != trade.ID {
+			remaining = append(remaining, t)
+		}
+	}
+	if _, err := computeHoldings(remaining); err != nil {
+		return CommandResult{Command: Trades, Error: err, UserError: "Later sales took units from that purchase, remove them first."}
+	}
+
+	// The transaction may already be gone, removed before trades went with it.
+	var linked []*Transaction
+	if trade.TransactionID != nil {
+		tx, err := ctx.Repos.TxRepo().GetById(int64(*trade.TransactionID), ctx.UserID)
+		if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			return CommandResult{Command: Trades, Error: err, UserError: userErrors[Unknown]}
+		}
+		if tx != nil {
+			if err := ctx.Repos.TxRepo().Delete([]*Transaction{tx}, SourceChat); err != nil {
+				return CommandResult{Command: Trades, Error: err, UserError: userErrors[Unknown]}
+			}
+			linked = append(linked, tx)
+		}
+	}
+	if err := ctx.Repos.InvestmentRepo().DeleteTrade(trade); err != nil {
+		return CommandResult{Command: Trades, Error: err, UserError: userErrors[Unknown]}
+	}
+
+	result := portfolio([]string{trade.Instrument}, ctx, Trades)
+	if result.Error == nil {
+		result.Trade, result.Transactions = trade, linked
+	}
+	return result
+}
+
+/**
+ * Enter the latest price of an instrument, to value it with.
+ * Format: <instrument> <price> $<currency?>
+ */
+func setPrice(args []string, ctx MessageContext) CommandResult {
+	if len(args) < 2 || len(args) > 3 {
+		return CommandResult{Command: Prices, Error: fmt.Errorf("invalid arguments: %v", args), UserError: userErrors[Prices]}
+	}
+
+	instrument := strings.ToUpper(args[0])
+	if !instrumentPattern.MatchString(instrument) {
+		return CommandResult{Command: Prices, Error: fmt.Errorf("invalid instrument %q", args[0]), UserError: userErrors[Prices]}
+	}
+
+	user, err := ctx.Repos.UserRepo().GetByID(ctx.UserID)
+	if err != nil {
+		return CommandResult{Command: Prices, Error: err, UserError: userErrors[Unknown]}
+	}
+
+	// Prices are in the currency the instrument is traded in, unless told otherwise.
+	fallback := user.PreferredCurrency
+	trades, err := ctx.Repos.InvestmentRepo().GetTrades(ctx.UserID, instrument)
+	if err != nil {
+		return CommandResult{Command: Prices, Error: err, UserError: userErrors[Unknown]}
+	}
+	if len(trades) > 0 {
+		fallback = trades[0].Currency
+	}
+
+	rest, currency := extractCurrency(args[1:], fallback)
+	if len(rest) != 1 {
+		return CommandResult{Command: Prices, Error: fmt.Errorf("invalid arguments: %v", rest), UserError: userErrors[Prices]}
+	}
+
+	price, err := parsePrice(rest[0], ctx.Numbers)
+	if err != nil {
+		return CommandResult{Command: Prices, Error: err, UserError: userErrors[Prices]}
+	}
+
+	// The units held must still be worth an amount that can be counted.
+	holdings, err := computeHoldings(trades)
+	if err != nil {
+		return holdingsFailure(Prices, err, ctx)
+	}
+	for _, holding := range holdings {
+		holding.Price = price
+		if _, err := holding.Value(); holding.Currency == currency && err != nil {
+			return CommandResult{Command: Prices, Error: err, UserError: "That price makes the holding worth more than can be counted."}
+		}
+	}
+
+	entered := &InstrumentPrice{
+		UserID:     ctx.UserID,
+		Instrument: instrument,
+		Price:      decimalString(price, 0, unitDecimals),
+		Currency:   currency,
+		UpdatedAt:  ctx.Timestamp,
+	}
+	if err := ctx.Repos.InvestmentRepo().SetPrice(entered); err != nil {
+		return CommandResult{Command: Prices, Error: err, UserError: userErrors[Unknown]}
+	}
+
+	return portfolio([]string{instrument}, ctx, Prices)
+}
+
+/**
+ * Holdings with their cost basis, realised gains and value at the latest
+ * price, optionally of a single instrument.
+ * Format: <instrument?>
+ */
+func portfolio(args []string, ctx MessageContext, command Command) CommandResult {
+	if len(args) > 1 {
+		return CommandResult{Command: command, Error: fmt.Errorf("too many arguments"), UserError: userErrors[Portfolio]}
+	}
+
+	instrument := ""
+	if len(args) == 1 {
+		instrument = strings.ToUpper(args[0])
+	}
+
+	trades, err := ctx.Repos.InvestmentRepo().GetTrades(ctx.UserID, instrument)
+	if err != nil {
+		return CommandResult{Command: command, Error: err, UserError: userErrors[Unknown]}
+	}
+	holdings, err := computeHoldings(trades)
+	if err != nil {
+		return holdingsFailure(command, err, ctx)
+	}
+
+	quotes, err := latestQuotes(ctx)
+	if err != nil {
+		return CommandResult{Command: command, Error: err, UserError: userErrors[Unknown]}
+	}
+
+	result := make([]Holding, 0, len(holdings))
+	for _, holding := range holdings {
+		if q, found := quotes[quoteKey{holding.Instrument, holding.Currency}]; found {
+			holding.Price, holding.PricedAt = q.price, q.at.In(ctx.Timestamp.Location())
+		}
+		if _, err := holding.Value(); err != nil {
+			return CommandResult{Command: command, Error: err, UserError: "That price makes the holding worth more than can be counted."}
+		}
+		result = append(result, *holding)
+	}
+
+	return CommandResult{Command: command, Portfolio: result}
+}
@@ -0,0 +1,156 @@
+package app
+
+import (
+	"errors"
+	. "remind0/db"
+	"testing"
+)
+
+func TestTakeLots(t *testing.T) {
+	lots := func() []lot {
+		return []lot{{units: 3 * unitScale, cost: 1000}, {units: 2 * unitScale, cost: 900}}
+	}
+
+	tests := []struct {
+		name      string
+		units     int64
+		wantCost  int64
+		wantLots  []lot
+		wantError bool
+	}{
+		{"part of the oldest lot", 1 * unitScale, 333, []lot{{units: 2 * unitScale, cost: 667}, {units: 2 * unitScale, cost: 900}}, false},
+		{"the oldest lot exactly", 3 * unitScale, 1000, []lot{{units: 2 * unitScale, cost: 900}}, false},
+		{"across lots", 4 * unitScale, 1450, []lot{{units: 1 * unitScale, cost: 450}}, false},
+		{"every lot", 5 * unitScale, 1900, []lot{}, false},
+		{"more than held", 6 * unitScale, 0, nil, true},
+	}
+
+	for _, test := range tests {
+		cost, remaining, err := takeLots(lots(), test.units)
+		if test.wantError {
+			if err == nil {
+				t.Errorf("%s: takeLots() = %d, want an error", test.name, cost)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: takeLots() failed: %v", test.name, err)
+			continue
+		}
+		if cost != test.wantCost {
+			t.Errorf("%s: cost = %d, want %d", test.name, cost, test.wantCost)
+		}
+		if len(remaining) != len(test.wantLots) {
+			t.Errorf("%s: lots left = %v, want %v", test.name, remaining, test.wantLots)
+			continue
+		}
+		for i := range remaining {
+			if remaining[i] != test.wantLots[i] {
+				t.Errorf("%s: lots left = %v, want %v", test.name, remaining, test.wantLots)
+				break
+			}
+		}
+	}
+}
+
+// Selling a lot in several goes adds up to exactly what it cost.
+func TestTakeLotsInParts(t *testing.T) {
+	lots := []lot{{units: 3 * unitScale, cost: 1000}}
+
+	var total int64
+	for range 3 {
+		cost, remaining, err := takeLots(lots, unitScale)
+		if err != nil {
+			t.Fatalf("takeLots() failed: %v", err)
+		}
+		total, lots = total+cost, remaining
+	}
+
+	if total != 1000 || len(lots) != 0 {
+		t.Errorf("sold for a cost of %d with %v left, want 1000 with nothing left", total, lots)
+	}
+}
+
+func TestComputeHoldings(t *testing.T) {
+	buy := func(id uint, instrument string, units int64, amount int64) *Trade {
+		return &Trade{ID: id, Instrument: instrument, Kind: TradeBuy, Units: units * unitScale, Amount: amount, Currency: "USD"}
+	}
+	sell := func(id uint, instrument string, units int64, amount int64) *Trade {
+		return &Trade{ID: id, Instrument: instrument, Kind: TradeSell, Units: units * unitScale, Amount: amount, Currency: "USD"}
+	}
+
+	tests := []struct {
+		name      string
+		trades    []*Trade
+		want      []Holding
+		wantError bool
+	}{
+		{
+			name:   "nothing traded",
+			trades: nil,
+			want:   []Holding{},
+		},
+		{
+			name:   "buys add up",
+			trades: []*Trade{buy(1, "AAPL", 10, 185000), buy(2, "AAPL", 5, 100000)},
+			want:   []Holding{{Instrument: "AAPL", Currency: "USD", Units: 15 * unitScale, Cost: 285000}},
+		},
+		{
+			name:   "a partial sale takes from the oldest lot",
+			trades: []*Trade{buy(1, "AAPL", 10, 100000), buy(2, "AAPL", 10, 200000), sell(3, "AAPL", 4, 60000)},
+			want:   []Holding{{Instrument: "AAPL", Currency: "USD", Units: 16 * unitScale, Cost: 260000, Realised: 20000}},
+		},
+		{
+			name:   "a sale across lots",
+			trades: []*Trade{buy(1, "AAPL", 10, 100000), buy(2, "AAPL", 10, 200000), sell(3, "AAPL", 15, 150000)},
+			want:   []Holding{{Instrument: "AAPL", Currency: "USD", Units: 5 * unitScale, Cost: 100000, Realised: -50000}},
+		},
+		{
+			name:   "selling everything",
+			trades: []*Trade{buy(1, "AAPL", 10, 100000), sell(2, "AAPL", 4, 50000), sell(3, "AAPL", 6, 30000)},
+			want:   []Holding{{Instrument: "AAPL", Currency: "USD", Units: 0, Cost: 0, Realised: -20000}},
+		},
+		{
+			name:   "instruments are kept apart and sorted",
+			trades: []*Trade{buy(1, "VWRL", 2, 20000), buy(2, "AAPL", 1, 18000), sell(3, "VWRL", 1, 11000)},
+			want: []Holding{
+				{Instrument: "AAPL", Currency: "USD", Units: 1 * unitScale, Cost: 18000},
+				{Instrument: "VWRL", Currency: "USD", Units: 1 * unitScale, Cost: 10000, Realised: 1000},
+			},
+		},
+		{
+			name:      "selling more than held",
+			trades:    []*Trade{buy(1, "AAPL", 2, 20000), sell(2, "AAPL", 3, 30000)},
+			wantError: true,
+		},
+		{
+			name:      "selling what was never bought",
+			trades:    []*Trade{sell(1, "AAPL", 1, 10000)},
+			wantError: true,
+		},
+	}
+
+	for _, test := range tests {
+		holdings, err := computeHoldings(test.trades)
+		if test.wantError {
+			var oversold *oversoldError
+			if !errors.As(err, &oversold) {
+				t.Errorf("%s: computeHoldings() = %v, want an oversold error", test.name, err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: computeHoldings() failed: %v", test.name, err)
+			continue
+		}
+		if len(holdings) != len(test.want) {
+			t.Errorf("%s: got %d holdings, want %d", test.name, len(holdings), len(test.want))
+			continue
+		}
+		for i, holding := range holdings {
+			if *holding != test.want[i] {
+				t.Errorf("%s: holding %d = %+v, want %+v", test.name, i, *holding, test.want[i])
+			}
+		}
+	}
+}
@@ -2,84 +2,486 @@ package app
 
 import (
 	"fmt"
+	"math/big"
 	. "remind0/db"
+	r "remind0/repository"
 	"sort"
+	"strings"
+	"time"
 )
 
 const SEPARATOR = "════════════"
 
 /**
- * Handle the formatting of success messages for various commands.
+ * Handle the formatting of success messages for various commands, in the
+ * user's language and number format.
  */
-func generateSuccessMessage(r CommandResult) string {
-	msg := "✅ Command executed successfully."
+func generateSuccessMessage(r CommandResult, lang Language, numbers NumberFormat) string {
+	msg := lang.T("✅ Command executed successfully.")
 
 	if txs := r.Transactions; txs != nil {
-		msg = txSuccessMessage(r.Command, txs)
+		msg = txSuccessMessage(lang, numbers, r.Command, txs)
 	}
 
 	if aggs := r.Aggregated; aggs != nil {
-		msg = aggSuccessMessage(r.Command, aggs)
+		msg = aggSuccessMessage(lang, numbers, r.Command, aggs)
+	}
+
+	if events := r.Events; events != nil {
+		msg = historySuccessMessage(lang, r.Command, events)
+	}
+
+	if balances := r.Balances; balances != nil {
+		msg = balancesSuccessMessage(lang, r.Command, balances)
+	}
+
+	if payments := r.Payments; payments != nil {
+		msg = paymentsSuccessMessage(lang, r.Command, payments)
+	}
+
+	if debts := r.Debts; debts != nil {
+		msg = debtsSuccessMessage(lang, r.Command, debts, r.Transactions)
+	}
+
+	if accounts := r.Accounts; accounts != nil {
+		msg = accountsSuccessMessage(lang, r.Command, accounts)
+	}
+
+	if holdings := r.Portfolio; holdings != nil {
+		msg = portfolioSuccessMessage(lang, numbers, r.Command, holdings, r.Trade, r.Transactions)
+	}
+
+	if goals := r.Goals; goals != nil {
+		msg = goalsSuccessMessage(lang, numbers, r.Command, goals)
+	}
+
+	if bills := r.Bills; bills != nil {
+		msg = billsSuccessMessage(lang, numbers, r.Command, bills, r.Transactions)
 	}
 
 	if r.UserInfo != "" {
-		msg = userHelpMessage(r.Command, r.UserInfo)
+		msg = userHelpMessage(lang, r.Command, r.UserInfo)
 	}
 
 	return msg
 }
 
+/**
+ * Show the times of a result in the user's timezone.
+ */
+func localiseTimes(r CommandResult, loc *time.Location) CommandResult {
+	for _, tx := range r.Transactions {
+		tx.Timestamp = tx.Timestamp.In(loc)
+	}
+	for _, event := range r.Events {
+		event.Timestamp = event.Timestamp.In(loc)
+	}
+	return r
+}
+
 /**
  * Format a return message to inform the user of a successful expense-related operation.
  */
-func txSuccessMessage(operation Command, txs []*Transaction) string {
-	msg := operationHeaders[operation] + "\n" + SEPARATOR + "\n"
+func txSuccessMessage(lang Language, numbers NumberFormat, operation Command, txs []*Transaction) string {
+	msg := lang.header(operation) + "\n" + SEPARATOR + "\n"
 
 	for _, tx := range txs {
-		msg += fmt.Sprintf(
-			"🪪 ID: %d\n"+
-				"📥 Category: %s\n"+
-				"💰 Amount: %.2f %s\n"+
-				"📌 Notes: %s\n"+
-				"🕒 At: %s\n"+
-				SEPARATOR+"\n",
-			tx.ID, tx.Category, tx.Amount, tx.Currency, tx.Notes, tx.Timestamp.Format("02-Jan-2006 15:04"),
-		)
+		msg += lang.Tf("🪪 ID: %d\n", tx.ID) +
+			lang.Tf("📥 Category: %s\n", lang.category(tx.Category)) +
+			lang.Tf("💰 Amount: %s%s\n", workingOut(tx.Expression), numbers.formatAmount(tx.Amount, tx.Currency)) +
+			lang.Tf("📌 Notes: %s\n", tx.Notes) +
+			lang.Tf("🕒 At: %s\n", tx.Timestamp.Format("02-Jan-2006 15:04"))
+
+		if tx.Account != nil {
+			msg += lang.Tf("🏦 Account: @%s\n", tx.Account.Name)
+		}
+
+		if len(tx.Mentions) > 0 {
+			msg += lang.Tf("⚠️ Not an account, kept in the notes: %s\n", strings.Join(tx.Mentions, ", "))
+		}
+
+		if tx.Goal != nil {
+			msg += lang.Tf("🎯 Goal: #%s\n", tx.Goal.Name)
+		}
+
+		if len(tx.Attachments) > 0 {
+			msg += lang.Tf("📎 Attachments: %d (!receipt %d)\n", len(tx.Attachments), tx.ID)
+		}
+
+		if len(tx.Splits) > 0 {
+			shares := make([]string, 0, len(tx.Splits))
+			for _, split := range tx.Splits {
+				shares = append(shares, fmt.Sprintf("%s %s", memberName(&split.User), numbers.formatNumber(split.Amount, tx.Currency)))
+			}
+			msg += lang.Tf("👥 Split: %s\n", strings.Join(shares, ", "))
+		}
+
+		msg += SEPARATOR + "\n"
 	}
 
 	return msg
 }
 
+// Show the expression an amount was worked out from, if any (e.g. "12.5+8*2 = ").
+func workingOut(expression string) string {
+	if expression == "" {
+		return ""
+	}
+	return expression + " = "
+}
+
 /**
  * Format a return message to inform the user of a successful aggregation-related operation.
  */
-func aggSuccessMessage(operation Command, aggs []AggregatedTransactions) string {
-	msg := operationHeaders[operation] + "\n" + SEPARATOR + "\n"
+func aggSuccessMessage(lang Language, numbers NumberFormat, operation Command, aggs []AggregatedTransactions) string {
+	msg := lang.header(operation) + "\n" + SEPARATOR + "\n"
 
 	for _, agg := range aggs {
-		msg += fmt.Sprintf(
-			"📥 Category: %s\n"+
-				"💰 Total: %.2f\n"+
-				"📊 Count: %d\n"+
-				SEPARATOR+"\n",
-			agg.Category, agg.Total, agg.Count,
-		)
+		msg += lang.Tf("📥 Category: %s\n", lang.category(agg.Category)) +
+			lang.Tf("💰 Total: %s\n", numbers.formatAmount(agg.Total, agg.Currency)) +
+			lang.Tf("📊 Count: %d\n", agg.Count) +
+			SEPARATOR + "\n"
 	}
 
 	return msg
 }
 
-func userHelpMessage(command Command, userInfo string) string {
-	return operationHeaders[command] + "\n" + SEPARATOR + "\n" + userInfo + "\n"
+/**
+ * Format the timeline of a transaction, one entry per recorded event.
+ */
+func historySuccessMessage(lang Language, operation Command, events []*TransactionEvent) string {
+	msg := lang.header(operation) + "\n" + SEPARATOR + "\n"
+	msg += lang.Tf("🪪 ID: %d\n", events[0].TransactionID) + SEPARATOR + "\n"
+
+	for _, event := range events {
+		msg += lang.Tf("%s · %s · via %s\n", lang.T(eventLabels[event.Action]), event.Timestamp.Format("02-Jan-2006 15:04"), event.Source)
+
+		before, _ := r.ParseSnapshot(event.OldValue)
+		after, _ := r.ParseSnapshot(event.NewValue)
+
+		switch {
+		case before != nil && after != nil:
+			msg += snapshotDiff(lang, before, after)
+		case after != nil:
+			msg += snapshotSummary(lang, after)
+		case before != nil:
+			msg += snapshotSummary(lang, before)
+		}
+		msg += SEPARATOR + "\n"
+	}
+
+	return msg
+}
+
+func snapshotSummary(lang Language, s *r.TxSnapshot) string {
+	return fmt.Sprintf("   %s · %s %s · %s\n", lang.category(s.Category), formatMoney(s.Amount, s.Currency), s.Currency, s.Notes)
+}
+
+// List only the fields that changed between two snapshots.
+func snapshotDiff(lang Language, before, after *r.TxSnapshot) string {
+	var changes []string
+
+	if before.Category != after.Category {
+		changes = append(changes, lang.Tf("   Category: %s → %s", lang.category(before.Category), lang.category(after.Category)))
+	}
+	if before.Amount != after.Amount || before.Currency != after.Currency {
+		changes = append(changes, lang.Tf("   Amount: %s %s → %s %s",
+			formatMoney(before.Amount, before.Currency), before.Currency, formatMoney(after.Amount, after.Currency), after.Currency,
+		))
+	}
+	if before.Notes != after.Notes {
+		changes = append(changes, lang.Tf("   Notes: %s → %s", before.Notes, after.Notes))
+	}
+	if !before.Timestamp.Equal(after.Timestamp) {
+		changes = append(changes, lang.Tf("   At: %s → %s", before.Timestamp.Format("02-Jan-2006 15:04"), after.Timestamp.Format("02-Jan-2006 15:04")))
+	}
+	switch {
+	case before.GoalID != nil && after.GoalID == nil:
+		changes = append(changes, lang.T("   Goal: removed"))
+	case after.GoalID != nil && (before.GoalID == nil || *before.GoalID != *after.GoalID):
+		changes = append(changes, lang.T("   Goal: changed"))
+	}
+
+	if len(changes) == 0 {
+		return lang.T("   No visible changes\n")
+	}
+	return strings.Join(changes, "\n") + "\n"
+}
+
+var eventLabels = map[EventAction]string{
+	ActionCreate: "➕ Created",
+	ActionEdit:   "✏️ Edited",
+	ActionDelete: "🗑️ Deleted",
+}
+
+/**
+ * Format the net position of each group member, grouped by currency.
+ */
+func balancesSuccessMessage(lang Language, operation Command, balances []Balance) string {
+	msg := lang.header(operation) + "\n" + SEPARATOR + "\n"
+
+	if len(balances) == 0 {
+		return msg + lang.T("🎉 All settled up!\n")
+	}
+
+	currency := ""
+	for _, balance := range balances {
+		if balance.Currency != currency {
+			if currency != "" {
+				msg += SEPARATOR + "\n"
+			}
+			currency = balance.Currency
+			msg += fmt.Sprintf("💱 %s\n", currency)
+		}
+
+		if balance.Net < 0 {
+			msg += lang.Tf("• %s owes %s\n", balance.Member, formatMoney(-balance.Net, balance.Currency))
+		} else {
+			msg += lang.Tf("• %s is owed %s\n", balance.Member, formatMoney(balance.Net, balance.Currency))
+		}
+	}
+
+	return msg + SEPARATOR + "\n"
+}
+
+/**
+ * Format the payments that would clear the debts of a group.
+ */
+func paymentsSuccessMessage(lang Language, operation Command, payments []Payment) string {
+	msg := lang.header(operation) + "\n" + SEPARATOR + "\n"
+
+	if len(payments) == 0 {
+		return msg + lang.T("🎉 All settled up!\n")
+	}
+
+	for _, payment := range payments {
+		msg += fmt.Sprintf("• %s → %s: %s %s\n", payment.From, payment.To, formatMoney(payment.Amount, payment.Currency), payment.Currency)
+	}
+
+	return msg + SEPARATOR + "\n" + lang.T("Record a payment with: !settle @member <amount>\n")
+}
+
+/**
+ * Format the outstanding debts per person and currency, along with any
+ * transaction recorded alongside a repayment.
+ */
+func debtsSuccessMessage(lang Language, operation Command, debts []DebtBalance, linked []*Transaction) string {
+	msg := lang.header(operation) + "\n" + SEPARATOR + "\n"
+
+	for _, tx := range linked {
+		msg += lang.Tf("🪪 Linked transaction: %d (%s)\n", tx.ID, lang.category(tx.Category)) + SEPARATOR + "\n"
+	}
+
+	if len(debts) == 0 {
+		return msg + lang.T("🎉 No outstanding debts!\n")
+	}
+
+	for _, debt := range debts {
+		switch {
+		case debt.Outstanding > 0:
+			msg += lang.Tf("• %s owes you %s %s\n", debt.Counterparty, formatMoney(debt.Outstanding, debt.Currency), debt.Currency)
+		case debt.Outstanding < 0:
+			msg += lang.Tf("• You owe %s %s %s\n", debt.Counterparty, formatMoney(-debt.Outstanding, debt.Currency), debt.Currency)
+		default:
+			msg += lang.Tf("• %s and you are even in %s\n", debt.Counterparty, debt.Currency)
+		}
+	}
+
+	return msg + SEPARATOR + "\n"
+}
+
+/**
+ * Format the current balance of each account.
+ */
+func accountsSuccessMessage(lang Language, operation Command, accounts []AccountBalance) string {
+	msg := lang.header(operation) + "\n" + SEPARATOR + "\n"
+
+	if len(accounts) == 0 {
+		return msg + lang.T("No accounts yet. Use !account add <name> <type> to create one.\n")
+	}
+
+	for _, account := range accounts {
+		msg += fmt.Sprintf("%s @%s (%s): %s %s\n", accountIcons[account.Type], account.Name, account.Type, formatMoney(account.Balance, account.Currency), account.Currency)
+	}
+
+	return msg + SEPARATOR + "\n"
+}
+
+/**
+ * Format each holding with its cost basis, value at the latest price and
+ * gains, then the totals per currency, along with the trade recorded or
+ * removed and its transaction.
+ */
+func portfolioSuccessMessage(lang Language, numbers NumberFormat, operation Command, holdings []Holding, trade *Trade, linked []*Transaction) string {
+	msg := lang.header(operation) + "\n" + SEPARATOR + "\n"
+
+	if trade != nil {
+		msg += lang.Tf("🧾 Trade: %d\n", trade.ID)
+	}
+	for _, tx := range linked {
+		msg += lang.Tf("🪪 Linked transaction: %d (%s)\n", tx.ID, lang.category(tx.Category))
+	}
+	if trade != nil || len(linked) > 0 {
+		msg += SEPARATOR + "\n"
+	}
+
+	if len(holdings) == 0 {
+		return msg + lang.T("No holdings yet. Use !buy <instrument> <units> <price> to record one.\n")
+	}
+
+	type total struct {
+		cost, value, realised int64
+		unpriced              int
+	}
+	totals := map[string]*total{}
+	currencies := []string{}
+
+	for _, holding := range holdings {
+		if totals[holding.Currency] == nil {
+			totals[holding.Currency] = &total{}
+			currencies = append(currencies, holding.Currency)
+		}
+		t := totals[holding.Currency]
+		t.realised += holding.Realised
+
+		if holding.Units == 0 {
+			msg += lang.Tf("📕 %s: all sold\n", holding.Instrument)
+			msg += lang.Tf("   Realised: %s\n", signedAmount(numbers, holding.Realised, holding.Currency))
+			continue
+		}
+
+		average := new(big.Rat).Quo(new(big.Rat).SetFrac(big.NewInt(holding.Cost), minorUnitsPerUnit(holding.Currency)), big.NewRat(holding.Units, unitScale))
+		msg += lang.Tf("📈 %s: %s units\n", holding.Instrument, formatUnits(holding.Units, numbers))
+		msg += lang.Tf("   Cost: %s (%s each)\n", numbers.formatAmount(holding.Cost, holding.Currency), formatPrice(average, holding.Currency, numbers))
+
+		if holding.Price == nil {
+			msg += lang.Tf("   No price yet, enter one with !price %s <price>\n", holding.Instrument)
+			t.unpriced++
+		} else {
+			value, _ := holding.Value() // Checked along with the price.
+			msg += lang.Tf("   Value: %s (%s each, %s)\n", numbers.formatAmount(value, holding.Currency), formatPrice(holding.Price, holding.Currency, numbers), holding.PricedAt.Format("02-Jan-2006"))
+			msg += lang.Tf("   Unrealised: %s (%s)\n", signedAmount(numbers, value-holding.Cost, holding.Currency), gainPercentage(numbers, value-holding.Cost, holding.Cost))
+			t.cost += holding.Cost
+			t.value += value
+		}
+		if holding.Realised != 0 {
+			msg += lang.Tf("   Realised: %s\n", signedAmount(numbers, holding.Realised, holding.Currency))
+		}
+	}
+
+	msg += SEPARATOR + "\n"
+	for _, currency := range currencies {
+		t := totals[currency]
+		msg += lang.Tf("💱 %s: worth %s, unrealised %s, realised %s\n", currency, numbers.formatAmount(t.value, currency), signedAmount(numbers, t.value-t.cost, currency), signedAmount(numbers, t.realised, currency))
+		if t.unpriced > 0 {
+			msg += lang.Tf("   Not counting %d holding(s) without a price\n", t.unpriced)
+		}
+	}
+
+	return msg + SEPARATOR + "\n"
+}
+
+/**
+ * Format the progress of each savings goal, with what's needed to make its
+ * deadline and when it's reached at the pace so far.
+ */
+func goalsSuccessMessage(lang Language, numbers NumberFormat, operation Command, goals []GoalProgress) string {
+	msg := lang.header(operation) + "\n" + SEPARATOR + "\n"
+
+	if len(goals) == 0 {
+		return msg + lang.T("No goals yet. Use !goal add <name> <target> <deadline?> to create one.\n")
+	}
+
+	for _, goal := range goals {
+		msg += fmt.Sprintf("🎯 #%s\n", goal.Name)
+		msg += fmt.Sprintf("%s %d%%\n", progressBar(goal.Saved, goal.Target), max(goal.Saved, 0)*100/goal.Target)
+		msg += lang.Tf("   Saved: %s of %s\n", numbers.formatAmount(goal.Saved, goal.Currency), numbers.formatAmount(goal.Target, goal.Currency))
+
+		switch {
+		case goal.Saved >= goal.Target:
+			msg += lang.T("   🎉 Target reached!\n")
+		case goal.Deadline != nil && goal.Monthly == 0:
+			msg += lang.Tf("   Deadline: %s, missed\n", goal.Deadline.Format("02-Jan-2006"))
+		case goal.Deadline != nil:
+			msg += lang.Tf("   Deadline: %s, save %s a month\n", goal.Deadline.Format("02-Jan-2006"), numbers.formatAmount(goal.Monthly, goal.Currency))
+		}
+
+		if goal.Saved < goal.Target {
+			if goal.Projected != nil {
+				msg += lang.Tf("   At this pace: reached by %s\n", goal.Projected.Format("02-Jan-2006"))
+			} else {
+				msg += lang.Tf("   Save towards it with: !add S <amount> #%s\n", goal.Name)
+			}
+		}
+		msg += SEPARATOR + "\n"
+	}
+
+	return msg
+}
+
+func billsSuccessMessage(lang Language, numbers NumberFormat, operation Command, bills []*Bill, paid []*Transaction) string {
+	msg := lang.header(operation) + "\n" + SEPARATOR + "\n"
+
+	for _, tx := range paid {
+		msg += lang.Tf("🪪 Linked transaction: %d (%s)\n", tx.ID, lang.category(tx.Category)) + SEPARATOR + "\n"
+	}
+
+	if len(bills) == 0 {
+		return msg + lang.T("No bills yet. Use !bill add <name> <amount> due <day> to add one.\n")
+	}
+
+	for _, bill := range bills {
+		msg += lang.Tf("🧾 %s: %s (%s)\n", bill.Name, numbers.formatAmount(bill.Amount, bill.Currency), lang.category(bill.Category))
+		msg += lang.Tf("   Next due: %s, reminder %d day(s) before\n", bill.NextDue.Format("02-Jan-2006"), bill.RemindDays)
+	}
+
+	return msg
+}
+
+// Share of the target saved so far, out of ten blocks (e.g. ██████░░░░).
+func progressBar(saved int64, target int64) string {
+	filled := int(min(max(saved, 0)*10/target, 10))
+	return strings.Repeat("█", filled) + strings.Repeat("░", 10-filled)
+}
+
+// Gain or loss with its sign (e.g. +$12.00 or -$3.50).
+func signedAmount(numbers NumberFormat, amount int64, currency string) string {
+	if amount > 0 {
+		return "+" + numbers.formatAmount(amount, currency)
+	}
+	return numbers.formatAmount(amount, currency)
+}
+
+// Gain or loss as a percentage of what was paid (e.g. +4.25%).
+func gainPercentage(numbers NumberFormat, gain int64, cost int64) string {
+	if cost == 0 {
+		return "-"
+	}
+	percentage := numbers.formatDecimal(new(big.Rat).SetFrac64(gain*100, cost).FloatString(2)) + "%"
+	if gain > 0 {
+		return "+" + percentage
+	}
+	return percentage
+}
+
+var accountIcons = map[AccountType]string{
+	AccountCash:    "💵",
+	AccountDebit:   "💳",
+	AccountCredit:  "🧾",
+	AccountSavings: "🐷",
+}
+
+func userHelpMessage(lang Language, command Command, userInfo string) string {
+	return lang.header(command) + "\n" + SEPARATOR + "\n" + userInfo + "\n"
 }
 
 /**
  * Format a return message to inform the user of the available categories.
  */
-func getCategoriesMessage() string {
-	categoryList := "Currently supported categories:\n\n"
+func getCategoriesMessage(lang Language) string {
+	categoryList := lang.T("Currently supported categories:\n\n")
 	for _, cat := range validCategories {
-		categoryList += fmt.Sprintf("• %s (%s)\n", cat.Alias, cat.Name)
+		categoryList += fmt.Sprintf("• %s (%s)\n", cat.Alias, lang.category(cat.Name))
 	}
 	return categoryList
 }
@@ -87,20 +489,35 @@ func getCategoriesMessage() string {
 /**
  * Format a return message to inform the user of the available currencies.
  */
-func getCurrenciesListMessage() string {
-	currencyList := "Currencies supported:\n"
+func getCurrenciesListMessage(lang Language) string {
+	currencyList := lang.T("Currencies supported (ISO 4217):\n")
+
+	var codes []string
+	for code := range isoCurrencies {
+		codes = append(codes, code)
+	}
+
+	sort.Strings(codes)
+	for _, code := range codes {
+		currencyList += fmt.Sprintf("• %s - %s\n", code, isoCurrencies[code].Name)
+	}
+
+	customCurrencies.RLock()
+	defer customCurrencies.RUnlock()
 
-	var currencies []string
-	for code := range supportedCurrencies {
-		currencies = append(currencies, code)
+	codes = codes[:0]
+	for code := range customCurrencies.byCode {
+		codes = append(codes, code)
 	}
 
-	sort.Strings(currencies)
-	for _, code := range currencies {
-		currencyList += fmt.Sprintf("• %s - %s\n", code, supportedCurrencies[code])
+	currencyList += "\n" + lang.T("Custom currencies:\n")
+	sort.Strings(codes)
+	for _, code := range codes {
+		currency := customCurrencies.byCode[code]
+		currencyList += lang.Tf("• %s - %s (%d decimals)\n", code, currency.Name, currency.Decimals)
 	}
 
-	return currencyList
+	return currencyList + "\n" + lang.T("Add one with: !currency add <CODE> <decimals> <name>\nRemove it with: !currency rm <CODE>\n")
 }
 
 /**
@@ -113,30 +530,79 @@ var operationHeaders = map[Command]string{
 	Help:          "💡 Help",
 	Edit:          "📝 Expense Updated",
 	Configuration: "⚙️ Configuration",
+	History:       "🕓 Transaction History",
+	Balances:      "⚖️ Balances",
+	Settle:        "🤝 Settle Up",
+	Lend:          "📤 Loan Recorded",
+	Borrow:        "📥 Debt Recorded",
+	Repay:         "💸 Repayment Recorded",
+	Debts:         "🧾 Debts",
+	Accounts:      "🏦 Accounts",
+	TransferFunds: "🔁 Transfer Recorded",
+	Receipt:       "🧾 Receipts",
+	Invites:       "🎟️ Invites",
+	Admin:         "🛠️ Admin",
+	Currencies:    "💱 Currencies",
+	Buy:           "🛒 Purchase Recorded",
+	Sell:          "💰 Sale Recorded",
+	Prices:        "🏷️ Price Updated",
+	Portfolio:     "📊 Portfolio",
+	Trades:        "🗑️ Trade Removed",
+	Goals:         "🎯 Savings Goals",
+	Bills:         "🧾 Bills",
 }
 
 /**
  * User-friendly error messages.
  */
 var userErrors = map[Command]string{
-	Add:           "Please ensure your transaction's category is valid. Use !help add for guidance.",
+	Add:           "Please ensure your transaction's category, amount and date are valid. Use !help add for guidance.",
 	Remove:        "Please ensure you provide valid transaction IDs. Use !help remove for guidance.",
 	List:          "Please check your options and try again. Use !help list for guidance.",
 	Help:          "Please try again later or contact support.",
-	Edit:          "Editing transactions is not implemented yet.",
+	Edit:          "Please use format: !edit <ID> <category> <amount> <notes?> $<currency?>. Use !help edit for guidance.",
 	Configuration: "Please use format: !c set-default-currency <CODE>. Use !help config for guidance.",
+	History:       "Please provide a single transaction ID with recorded history. Use !help history for guidance.",
+	Settle:        "Please use format: !settle or !settle @member <amount> $<currency?>. Use !help groups for guidance.",
+	Lend:          "Please use format: !lend <person> <amount> <notes?> $<currency?>. Use !help debts for guidance.",
+	Borrow:        "Please use format: !borrow <person> <amount> <notes?> $<currency?>. Use !help debts for guidance.",
+	Repay:         "Please ensure the repayment doesn't exceed what is owed. Use !help debts for guidance.",
+	Debts:         "Please use format: !debts <person?>. Use !help debts for guidance.",
+	Accounts:      "Please check the account name and details. Use !help accounts for guidance.",
+	Receipt:       "Please provide a single transaction ID with attached files. Use !help receipt for guidance.",
+	TransferFunds: "Please use format: !transfer <amount> @<from> @<to> <notes?>, between accounts of the same currency.",
+	Invites:       "Please use format: !invite <days?>, valid for up to 90 days. Use !help invite for guidance.",
+	Currencies:    "Please use format: !currency add <CODE> <decimals> <name> or !currency rm <CODE>, with up to 8 decimals. Use !help currencies for guidance.",
+	Buy:           "Please use format: !buy <instrument> <units> <price> $<currency?>. Use !help portfolio for guidance.",
+	Sell:          "Please use format: !sell <instrument> <units> <price> $<currency?>. Use !help portfolio for guidance.",
+	Prices:        "Please use format: !price <instrument> <price> $<currency?>. Use !help portfolio for guidance.",
+	Portfolio:     "Please use format: !portfolio <instrument?>. Use !help portfolio for guidance.",
+	Trades:        "Please use format: !trade rm <id>. Use !help portfolio for guidance.",
+	Goals:         "Please check the goal's name, target and deadline (e.g. 12/2027). Use !help goals for guidance.",
+	Bills:         "Please use format: !bill add <name> <amount> due <day> <U|R|SUB?> <remind N?> $<currency?>. Use !help bills for guidance.",
+	Admin:         "Please use format: !admin stats|users|block <user>|unblock <user>|broadcast <message>. Use !help admin for guidance.",
 	Unknown:       "Something went wrong, please try again later.",
 }
 
+// Batches used to be written as (12.5-8).
+const parenthesisedBatchError = "Batches are now written in brackets, separated by semicolons, e.g. [12.5;8]. Use !help add for guidance."
+
+// The same message, or a line of it, was sent twice.
+const duplicateTransactionError = "That transaction was already recorded."
+
+/**
+ * User-friendly error messages for group ledgers.
+ */
+const (
+	groupOnlyError = "This is only available in group chats. Use !help groups for guidance."
+	splitError     = "Please ensure you split with known members and valid amounts. Use !help groups for guidance."
+)
+
 type HelpTopic struct {
 	Command  Command
 	Subtopic string
 }
 
-// Prevent from running on every map access.
-var categoriesHelpMessage = getCategoriesMessage()
-var currenciesHelpMessage = getCurrenciesListMessage()
-
 /**
  * Detailed help messages for each command.
  */
@@ -145,17 +611,38 @@ var userHelp = map[HelpTopic]string{
 Command Name: add (aliases: a)
 
 Usage:
-	!add <category> <amount or (n-n)> <notes?> $<currency?>
+	!add <category> <amount or [n;n]> <notes?> @<account?> @<date?> $<currency?>
 
 Examples:
 	!add G 45 Woolworths (45 in your default currency)
 	!add G 45 Woolworths $USD (45 USD)
-	!add G (2.5-8) Farmers market $EUR (2.5 and 8 EUR)
+	!add G [2.5;8] Farmers market $EUR (2.5 and 8 EUR)
+	!add GO 12.5+8*2 Drinks (28.50)
+	!add SH 45-5% Shoes (42.75, 5% off)
+	!add SH -20 Refund (Negative amounts work too)
+	!add G 45 Woolworths
+	GO 12 Coffee @visa (One transaction per line)
 
 Note:
 	• Categories: Use !help categories for list
 	• Currencies: Use !help currencies for list
 	• Set your default: !c set-default-currency USD
+	• Account paid from/into: add @<account> (see !help accounts), any
+	  other @ stays in the notes (e.g. dinner with @bob), with a warning
+	  in case it was a typo
+	• Savings goal: add #<goal> to savings (see !help goals)
+	• Receipts: send a photo with the message as its caption, it goes
+	  with the first transaction
+	• Amounts: + - * / % and parentheses, no spaces
+	• Batches: several amounts in brackets, separated by ;
+	• Several at once: one per line, nothing is recorded if a line is wrong
+	• Back-dating: add @<date> (see below)
+	• Splitting in group chats: Use !help groups
+
+Dates (in your timezone, see !help config):
+	@today, @yesterday, @mon ... @sun (last occurrence)
+	@12/03, @12/03/2025, @2025-03-12, optionally followed by HH:MM
+	e.g. !add GO 60 Dinner @yesterday 19:30
 	`,
 	{Command: Remove}: `
 Command Name: remove (aliases: rm, r, delete, del, d)
@@ -168,6 +655,32 @@ Examples:
 	!rm 42 43 44 (Remove multiple transactions)
 
 Note: IDs can be found using the !ls command
+	`,
+	{Command: Edit}: `
+Command Name: edit (aliases: e, update, u)
+
+Usage:
+	!edit <ID> <category> <amount> <notes?> $<currency?>
+
+Examples:
+	!edit 42 G 50 Woolworths (Replace #42 with 50 of Groceries)
+	!edit 42 GO 30 Drinks $USD (Replace #42 with 30 USD of Going Out)
+
+Note:
+	• The currency defaults to the transaction's current one
+	• Every edit is kept in the transaction's !history
+	`,
+	{Command: History}: `
+Command Name: history (aliases: hist)
+
+Usage:
+	!history <ID>: Show every change made to a transaction
+
+Examples:
+	!history 42 (Timeline of transaction #42)
+
+Note:
+	Deleted transactions keep their history.
 	`,
 	{Command: List}: `
 Command Name: list (aliases: ls, l)
@@ -198,12 +711,26 @@ Usage:
 	!help <command>: Show detailed help for a specific command
 	!help categories: List all supported categories
 	!help currencies: List all supported currencies
+	!help groups: Share expenses in group chats
 
 Input Commands:
 	• !add <category> <amount> <notes?> $<currency?> - Record an expense/income
 	• !ls [options] - View your transactions
 	• !rm <ID1> <ID2> ... - Remove transactions
+	• !edit <ID> <category> <amount> <notes?> - Edit a transaction
+	• !lend / !borrow <person> <amount> - Track money owed
+	• !debts - Show outstanding debts
+	• !receipt <ID> - Get the receipts of a transaction back
+	• !account add <name> <type> - Track an account's balance
+	• !currency add <CODE> <decimals> <name> - Add a currency (e.g. BTC)
+	• !balances - Show account balances
+	• !buy / !sell <instrument> <units> <price> - Track investments
+	• !portfolio - Show holdings, their value and gains
+	• !goal add <name> <target> <deadline?> - Save towards a goal
+	• !bill add <name> <amount> due <day> - Get reminded of a bill
+	• !history <ID> - Show the changes made to a transaction
 	• !c set-default-currency <CODE> - Set your preferred currency
+	• !c set-language <en|es|pt> - Set the language replies are in
 	• !help - Show this help menu
 
 Quick Examples:
@@ -221,18 +748,241 @@ Command Name: config (aliases: c, cfg)
 
 Usage:
 	!c set-default-currency <CODE>: Set your preferred currency
+	!c set-timezone <ZONE>: Set the timezone dates are read in
+	!c set-language <en|es|pt|auto>: Set the language replies are in
+	!c set-number-format <FORMAT>: Set how amounts are written
+	!c reminders <on|off>: Get a nudge when you haven't logged anything
+	!c reminders time <HH:MM>: Set when nudges are sent (20:00 by default)
+	!c reminders days <N>: Nudge after N days without logging (1 by default)
+	!c reminders quiet <HH:MM-HH:MM|off>: Hours no nudge is sent in
 
 Aliases:
 	• set-default-currency, sdc
+	• set-timezone, stz
+	• set-language, sl
+	• set-number-format, snf
+	• reminders, rem
+
+Number formats:
+	• en: 1,234.56 ($1,234.56)
+	• eu: 1.234,56 (1.234,56 €)
+	• br: 1.234,56 (R$ 1.234,56)
+	• fr: 1 234,56 (1 234,56 €)
+	• ch: 1'234.56 (CHF 1'234.56)
+	• auto: the usual one for your language
+
+	Until you set one, an amount like 1,250 or 1.250 is rejected as it
+	could be either 1250 or 1.25: write 1250, or add the decimals
+	(e.g. 1,250.00 or 1.250,00).
 
 Examples:
 	!c set-default-currency USD
 	!c sdc NZD
+	!c stz Pacific/Auckland
+	!c sl es
+	!c snf eu
+	!c reminders on
+	!c rem time 21:30
+	!c rem quiet 22:00-08:00
 
 Note:
 	This currency will be used for all transactions when you don't
 	specify a currency explicitly. Use !help currencies for supported codes.
+	The timezone defaults to UTC.
+	The language follows your Telegram app (auto), English if unsupported.
+	Amounts like 12,50 are read as 12.50 whatever the number format.
+	Reminders follow your timezone, setting their time or days turns them on.
+	`,
+	{Command: Receipt}: `
+Command Name: receipt (aliases: rc)
+
+Usage:
+	!receipt <ID>: Send back the files attached to a transaction
+	!receipt <ID> (as a photo caption): Attach the photo to a transaction
+
+Examples:
+	[photo] G 45 Woolworths (Record the expense with its receipt)
+	[photo] !receipt 42 (Attach the receipt to #42)
+	!receipt 42 (Get the receipts of #42 back)
+
+Note:
+	Photos and documents work alike, the caption is read like a message.
+	`,
+	{Command: Accounts}: `
+Command Names: account (aliases: acc), transfer (aliases: tf), balances (aliases: bal)
+
+Usage:
+	!account add <name> <type> <opening?> $<currency?>: Create an account
+	!account rm <name>: Remove an account without transactions
+	!transfer <amount> @<from> @<to> <notes?>: Move money between accounts
+	!balances: Show the current balance of each account
+
+Types:
+	• cash, debit, credit, savings
+
+Examples:
+	!account add visa credit -250 (Credit card owing 250)
+	!account add wallet cash 40 $USD
+	!add G 45 Woolworths @visa (Groceries paid by card)
+	!transfer 100 @debit @savings (Not counted as spending)
+
+Note:
+	• Transactions marked with @<account> use the account's currency
+	• Income adds to the balance, every other category takes from it
+	• In group chats, !balances shows the group's debts instead
+	`,
+	{Command: Debts}: `
+Command Names: lend, borrow, repay, debts (aliases: iou)
+
+Usage:
+	!lend <person> <amount> <notes?> $<currency?>: Money someone owes you
+	!borrow <person> <amount> <notes?> $<currency?>: Money you owe someone
+	!repay <person> <amount> <category?> $<currency?>: Record a repayment
+	!debts <person?>: Show outstanding balances
+
+Examples:
+	!lend bob 50 Concert tickets (Bob owes you 50)
+	!borrow alice 20 Taxi $USD (You owe Alice 20 USD)
+	!repay bob 20 (Bob paid back 20, 30 still owed)
+	!repay alice 20 T $USD (Paid Alice back, recorded as Transport)
+
+Note:
+	• People don't need to use the bot
+	• Repayments settle the oldest debts first
+	• Adding a category also records the repayment as a transaction
+	`,
+	{Command: Portfolio}: `
+Command Names: buy, sell, price, portfolio (aliases: pf), trade
+
+Usage:
+	!buy <instrument> <units> <price> $<currency?>: Record units bought
+	!sell <instrument> <units> <price> $<currency?>: Record units sold
+	!price <instrument> <price> $<currency?>: Enter the latest price
+	!portfolio <instrument?>: Show holdings, their value and gains
+	!trade rm <id>: Remove a trade, along with its transaction
+
+Examples:
+	!buy AAPL 10 185.20 $USD (10 shares at 185.20 USD each)
+	!buy BTC 0.015 61250 $USD (Fractional units work too)
+	!sell AAPL 4 192 (Sold at 192, in the currency it was bought in)
+	!price AAPL 190.50
+	!portfolio AAPL
+	!trade rm 12
+
+Note:
+	• Prices are per unit, units can have up to 8 decimals
+	• Trades are also recorded as Investment (INV) transactions
+	• Sales take the oldest units first (FIFO) to work out the gains
+	• An instrument is traded in a single currency
+	• Holdings are valued at the latest of your !price and the bot's prices file
+	• A trade's transaction can't be edited or removed on its own, remove the trade with !trade rm
+	• A purchase can't be removed while later sales took units from it
+	`,
+	{Command: Goals}: `
+Command Name: goal (aliases: goals)
+
+Usage:
+	!goals: Show the progress of every goal
+	!goal add <name> <target> <deadline?> $<currency?>: Create a goal
+	!goal rm <name>: Remove a goal, its savings are kept
+	!add S <amount> #<goal>: Save towards a goal
+
+Deadlines:
+	31/12/2027 or 2027-12-31, or 12/2027 for the end of the month
+
+Examples:
+	!goal add house 20000 12/2027 (20,000 by the end of 2027)
+	!goal add bike 1500 $USD (No deadline)
+	!add S 500 #house (500 towards the house)
+	!add S -200 #house (Took 200 back out)
+
+Note:
+	• Only savings (S) count towards goals, in the goal's currency
+	• The monthly amount is what's left divided by the months to the deadline
+	• The projection follows your pace since the goal was created
+	`,
+	{Command: Bills}: `
+Command Name: bill (aliases: bills)
+
+Usage:
+	!bills: Show every bill and when it's next due
+	!bill add <name> <amount> due <day> <category?> <remind N?> $<currency?>: Add a monthly bill
+	!bill paid <name>: Record the next payment and move on to the month after
+	!bill skip <name>: Move on to the month after without recording anything
+	!bill rm <name>: Remove a bill, its payments are kept
+
+Categories:
+	U (Utilities, by default), R (Rent) or SUB (Subscriptions)
+
+Examples:
+	!bill add power 180 due 15th (Utilities, reminded 3 days before)
+	!bill add rent 450 due 1st R remind 5 (Reminded 5 days before)
+	!bill add netflix 15.99 due 3rd SUB $USD
+	!bill paid power
+
+Note:
+	• Reminders are sent from 9:00 in your timezone, outside your quiet hours (see !help config)
+	• Tap Paid on a reminder to record the payment as a transaction
+	• Bills due on the 29th to 31st are due on the last day of shorter months
+	`,
+	{Command: Invites}: `
+Command Names: invite, join
+
+Usage:
+	!invite <days?>: Create an invite code, valid for 7 days by default (admins only)
+	!join <code>: Start using an invite-only bot
+
+Examples:
+	!invite (Valid for a week)
+	!invite 30 (Valid for 30 days)
+	!join 7GQ2LX4M
+
+Note:
+	• Each code lets a single new user in
+	• Links like t.me/<bot>?start=<code> work too
+	`,
+	{Command: Admin}: `
+Command Name: admin (admins only)
+
+Usage:
+	!admin stats: Users, transactions and weekly active users
+	!admin users: List every user
+	!admin block <user>: Turn a user away from the bot
+	!admin unblock <user>: Let a blocked user back in
+	!admin broadcast <message>: Send an announcement to every user
+
+Examples:
+	!admin block @bob
+	!admin unblock 123456789 (By Telegram ID)
+	!admin broadcast The bot will be down for maintenance tonight.
+
+Note:
+	• Announcements are delivered gradually, you're told once they're done
+	• Only users who started a private chat with the bot receive them
+	`,
+	{Command: Help, Subtopic: "Groups"}: `
+Group Ledgers
+
+Add the bot to a group chat to share expenses with its members.
+Members are known to the bot once they send a command in the group.
+
+Usage:
+	!add <category> <amount> <notes?> split <@members> $<currency?>
+	!balances: Show who owes whom (aliases: bal)
+	!settle: Suggest the payments that clear all debts
+	!settle @member <amount> $<currency?>: Record a payment you made
+
+Splitting (you are included unless you mention @me):
+	split @alice @bob: Equal shares
+	split @alice:2 @bob:1: By number of shares
+	split @alice=30 @bob=20: Exact amounts, you cover the rest
+
+Examples:
+	!add G 90 Groceries split @alice @bob (30 each)
+	!add GO 100 Dinner split @me:2 @alice:1 (66.67 and 33.33)
+	!settle @alice 30
+
+Note:
+	Only commands starting with ! are handled in groups.
 	`,
-	{Command: Help, Subtopic: "Categories"}: categoriesHelpMessage,
-	{Command: Help, Subtopic: "Currencies"}: currenciesHelpMessage,
 }
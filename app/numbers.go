@@ -0,0 +1,206 @@
+package app
+
+import (
+	"fmt"
+	"math/big"
+	"regexp"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	. "remind0/db"
+
+	telegramClient "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+/**
+ * How a user writes numbers: which separators amounts are read with, and
+ * how they're shown back along with the currency's symbol.
+ *
+ * Reading is lenient so habits carry over: a separator that can't be a
+ * thousands separator where it stands is taken as the decimal one, so
+ * 12,50 and 0,500 are decimals in every format. 1,250 is 1250 in en, but
+ * only once the user picked it: to many it reads as 1.25, so it's rejected
+ * when the format is just the usual one for their language.
+ */
+type NumberFormat struct {
+	Name        string
+	Decimal     byte   // Decimal separator
+	Group       string // Thousands separator
+	SymbolFirst bool   // Whether the currency symbol goes before the amount
+	SymbolSpace bool   // Whether a space separates the symbol from the amount
+	Chosen      bool   // Whether the user picked it, rather than it going by their language
+}
+
+var numberFormats = map[string]NumberFormat{
+	"en": {Name: "en", Decimal: '.', Group: ",", SymbolFirst: true},                    // $1,234.56
+	"eu": {Name: "eu", Decimal: ',', Group: ".", SymbolSpace: true},                    // 1.234,56 €
+	"br": {Name: "br", Decimal: ',', Group: ".", SymbolFirst: true, SymbolSpace: true}, // R$ 1.234,56
+	"fr": {Name: "fr", Decimal: ',', Group: "\u202f", SymbolSpace: true},               // 1 234,56 €
+	"ch": {Name: "ch", Decimal: '.', Group: "'", SymbolFirst: true, SymbolSpace: true}, // CHF 1'234.56
+}
+
+// Languages whose speakers mostly write 1.234,56.
+var commaDecimalLanguages = map[string]bool{
+	"es": true, "pt": true, "de": true, "it": true, "nl": true, "tr": true, "id": true, "da": true,
+}
+
+/**
+ * Number format of a user: the one they picked, else the usual one for
+ * the language they use.
+ */
+func userNumberFormat(user *User, sender *telegramClient.User) NumberFormat {
+	code := ""
+	if sender != nil {
+		code = strings.ToLower(strings.ReplaceAll(sender.LanguageCode, "_", "-"))
+	}
+
+	if user != nil {
+		if format, ok := numberFormats[user.NumberFormat]; ok {
+			format.Chosen = true
+			return format
+		}
+		// A language picked in the bot wins over the app's, region and all.
+		if base, _, _ := strings.Cut(code, "-"); user.Language != "" && user.Language != base {
+			code = user.Language
+		}
+	}
+
+	base, _, _ := strings.Cut(code, "-")
+	switch {
+	case code == "pt-br":
+		return numberFormats["br"]
+	case code == "de-ch" || code == "fr-ch" || code == "it-ch":
+		return numberFormats["ch"]
+	case base == "fr":
+		return numberFormats["fr"]
+	case commaDecimalLanguages[base]:
+		return numberFormats["eu"]
+	default:
+		return numberFormats["en"]
+	}
+}
+
+// Thousands separator accepted when reading, if it can be typed in a word.
+func (f NumberFormat) inputGroup() byte {
+	if len(f.Group) == 1 {
+		return f.Group[0]
+	}
+	return 0
+}
+
+var digitsPattern = regexp.MustCompile(`^\d*\.?\d*$`)
+
+/**
+ * Parse an unsigned number written with this format's separators.
+ */
+func (f NumberFormat) parseNumber(number string) (*big.Rat, error) {
+	normalised := number
+
+	if group := f.inputGroup(); group != 0 && strings.IndexByte(normalised, group) >= 0 {
+		whole, fraction, hasDecimal := strings.Cut(normalised, string(f.Decimal))
+		grouped := validGrouping(whole, group) && strings.IndexByte(fraction, group) < 0
+		single := strings.Count(normalised, string(group)) == 1 && !hasDecimal
+		switch {
+		case grouped && single && !f.Chosen:
+			return nil, fmt.Errorf("ambiguous number %q, either grouped or decimal", number)
+		case grouped:
+			normalised = strings.ReplaceAll(normalised, string(group), "")
+		case single:
+			normalised = strings.Replace(normalised, string(group), ".", 1)
+		default:
+			return nil, fmt.Errorf("misplaced %q in %q", group, number)
+		}
+	}
+
+	if f.Decimal != '.' {
+		// Either separator can be the decimal one, but not both.
+		if strings.IndexByte(normalised, f.Decimal) >= 0 && strings.Contains(normalised, ".") {
+			return nil, fmt.Errorf("invalid number %q", number)
+		}
+		normalised = strings.ReplaceAll(normalised, string(f.Decimal), ".")
+	} else if strings.Count(normalised, ",") == 1 && !strings.Contains(normalised, ".") {
+		normalised = strings.Replace(normalised, ",", ".", 1)
+	}
+
+	if !digitsPattern.MatchString(normalised) || strings.Trim(normalised, ".") == "" {
+		return nil, fmt.Errorf("invalid number %q", number)
+	}
+
+	value, ok := new(big.Rat).SetString(normalised)
+	if !ok {
+		return nil, fmt.Errorf("invalid number %q", number)
+	}
+	return value, nil
+}
+
+/**
+ * Whether digits are grouped in threes (e.g. 1,234,567), the first group
+ * being shorter. A first group of 0 isn't a group (e.g. 0,500).
+ */
+func validGrouping(whole string, group byte) bool {
+	groups := strings.Split(whole, string(group))
+	if len(groups[0]) < 1 || len(groups[0]) > 3 || groups[0][0] == '0' {
+		return false
+	}
+	for _, g := range groups[1:] {
+		if len(g) != 3 {
+			return false
+		}
+	}
+	return true
+}
+
+/**
+ * Minor units as a number with this format's separators (e.g. 1,234.56).
+ */
+func (f NumberFormat) formatNumber(amount int64, currency string) string {
+	return f.formatDecimal(formatMoney(amount, currency))
+}
+
+/**
+ * A plain decimal (e.g. -1234.56) with this format's separators.
+ */
+func (f NumberFormat) formatDecimal(plain string) string {
+	sign := ""
+	if strings.HasPrefix(plain, "-") {
+		sign, plain = "-", plain[1:]
+	}
+
+	whole, fraction, hasFraction := strings.Cut(plain, ".")
+	for i := len(whole) - 3; i > 0; i -= 3 {
+		whole = whole[:i] + f.Group + whole[i:]
+	}
+	if hasFraction {
+		whole += string(f.Decimal) + fraction
+	}
+	return sign + whole
+}
+
+/**
+ * Minor units with the currency's symbol, placed the way this format does
+ * (e.g. $1,234.56 or 1.234,56 €).
+ */
+func (f NumberFormat) formatAmount(amount int64, currency string) string {
+	return f.withSymbol(f.formatNumber(amount, currency), currency)
+}
+
+// Place the currency's symbol next to a formatted number.
+func (f NumberFormat) withSymbol(number string, currency string) string {
+	symbol := currencySymbol(currency)
+	sign := ""
+	if strings.HasPrefix(number, "-") {
+		sign, number = "-", number[1:]
+	}
+
+	if !f.SymbolFirst {
+		return sign + number + " " + symbol
+	}
+
+	// Letters would run into the digits without a space (e.g. CHF 12.00).
+	last, _ := utf8.DecodeLastRuneInString(symbol)
+	if f.SymbolSpace || unicode.IsLetter(last) {
+		return sign + symbol + " " + number
+	}
+	return sign + symbol + number
+}
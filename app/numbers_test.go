@@ -0,0 +1,100 @@
+package app
+
+import (
+	"math/big"
+	"testing"
+)
+
+func TestParseNumber(t *testing.T) {
+	chosen := func(name string) NumberFormat {
+		format := numberFormats[name]
+		format.Chosen = true
+		return format
+	}
+
+	tests := []struct {
+		format NumberFormat
+		input  string
+		want   string // Exact value, "" when rejected.
+	}{
+		// en, going by the user's language.
+		{numberFormats["en"], "12", "12"},
+		{numberFormats["en"], "12.50", "12.5"},
+		{numberFormats["en"], "12,50", "12.5"},
+		{numberFormats["en"], "0,500", "0.5"},
+		{numberFormats["en"], "0.500", "0.5"},
+		{numberFormats["en"], "1,250", ""},
+		{numberFormats["en"], "12,500", ""},
+		{numberFormats["en"], "1,250.00", "1250"},
+		{numberFormats["en"], "1,234,567", "1234567"},
+		{numberFormats["en"], "1,234,567.89", "1234567.89"},
+		{numberFormats["en"], "01,250", "1.25"},
+		{numberFormats["en"], "1,25,0", ""},
+		{numberFormats["en"], "1.250,00", ""},
+		{numberFormats["en"], ".5", "0.5"},
+		{numberFormats["en"], ".", ""},
+		{numberFormats["en"], "", ""},
+		{numberFormats["en"], "1a", ""},
+
+		// en, picked by the user.
+		{chosen("en"), "1,250", "1250"},
+		{chosen("en"), "12,500", "12500"},
+		{chosen("en"), "0,500", "0.5"},
+		{chosen("en"), "12,50", "12.5"},
+
+		// eu, going by the user's language.
+		{numberFormats["eu"], "12,50", "12.5"},
+		{numberFormats["eu"], "12.50", "12.5"},
+		{numberFormats["eu"], "0.500", "0.5"},
+		{numberFormats["eu"], "0,500", "0.5"},
+		{numberFormats["eu"], "1.250", ""},
+		{numberFormats["eu"], "1.250,00", "1250"},
+		{numberFormats["eu"], "1.234.567,89", "1234567.89"},
+		{numberFormats["eu"], "1,250.00", ""},
+		{numberFormats["eu"], "1,2,5", ""},
+
+		// eu, picked by the user.
+		{chosen("eu"), "1.250", "1250"},
+		{chosen("eu"), "0.500", "0.5"},
+
+		// br reads like eu.
+		{numberFormats["br"], "1.250", ""},
+		{numberFormats["br"], "1.250,99", "1250.99"},
+		{chosen("br"), "1.250", "1250"},
+
+		// fr groups with a narrow space, which can't be typed in a word.
+		{numberFormats["fr"], "1234,56", "1234.56"},
+		{numberFormats["fr"], "12.50", "12.5"},
+		{numberFormats["fr"], "1.234,56", ""},
+
+		// ch groups with an apostrophe, so 1,250 is a decimal.
+		{numberFormats["ch"], "1'250", ""},
+		{chosen("ch"), "1'250", "1250"},
+		{numberFormats["ch"], "1'234.56", "1234.56"},
+		{numberFormats["ch"], "0'500", "0.5"},
+		{numberFormats["ch"], "1,250", "1.25"},
+		{numberFormats["ch"], "1'2'3", ""},
+	}
+
+	for _, test := range tests {
+		name := test.format.Name
+		if test.format.Chosen {
+			name += " (chosen)"
+		}
+
+		got, err := test.format.parseNumber(test.input)
+		if test.want == "" {
+			if err == nil {
+				t.Errorf("%s: parseNumber(%q) = %s, want an error", name, test.input, got.RatString())
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: parseNumber(%q) failed: %v", name, test.input, err)
+			continue
+		}
+		if want, _ := new(big.Rat).SetString(test.want); got.Cmp(want) != 0 {
+			t.Errorf("%s: parseNumber(%q) = %s, want %s", name, test.input, got.RatString(), test.want)
+		}
+	}
+}
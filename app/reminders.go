@@ -0,0 +1,267 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	. "remind0/db"
+	r "remind0/repository"
+	"strconv"
+	"strings"
+	"time"
+
+	telegramClient "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+/**
+ *                  _
+ *  _ __  _   _  __| | __ _  ___  ___
+ * | '_ \| | | |/ _` |/ _` |/ _ \/ __|
+ * | | | | |_| | (_| | (_| |  __/\__ \
+ * |_| |_|\__,_|\__,_|\__, |\___||___/
+ *                    |___/
+ *
+ * Users who ask for it get a nudge at their chosen local time when they
+ * haven't logged anything that day, or for the last few days. When each
+ * user was last nudged is stored along with the user, so restarts don't
+ * nudge anyone twice, and nothing is sent during their quiet hours.
+ */
+
+const (
+	reminderInterval = time.Minute // How often users due a nudge are looked for.
+	maxReminderDays  = 30
+)
+
+// Minutes past midnight of a time of day written as HH:MM.
+func minuteOfDay(expr string) (int, bool) {
+	t, ok := parseClock(expr, time.Time{})
+	return t.Hour()*60 + t.Minute(), ok
+}
+
+func formatClock(minutes int) string {
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
+
+// Quiet hours are written as HH:MM-HH:MM and can go past midnight (e.g. 22:00-07:00).
+func parseQuietHours(expr string) (start int, end int, ok bool) {
+	from, to, found := strings.Cut(expr, "-")
+	if !found {
+		return 0, 0, false
+	}
+	start, okStart := minuteOfDay(from)
+	end, okEnd := minuteOfDay(to)
+	return start, end, okStart && okEnd && start != end
+}
+
+// Whether a time of day, in minutes past midnight, falls within quiet hours.
+func inQuietHours(quiet string, minute int) bool {
+	start, end, ok := parseQuietHours(quiet)
+	switch {
+	case !ok:
+		return false
+	case start < end:
+		return minute >= start && minute < end
+	default:
+		return minute >= start || minute < end
+	}
+}
+
+/**
+ * Whether a user is due a nudge at now, in their timezone: past their
+ * reminder time, outside their quiet hours, with nothing logged and no
+ * nudge sent in the last ReminderDays days, today included.
+ */
+func nudgeDue(user *User, lastLogged *time.Time, now time.Time) bool {
+	at, ok := minuteOfDay(user.ReminderTime)
+	minute := now.Hour()*60 + now.Minute()
+	if !ok || minute < at || inQuietHours(user.QuietHours, minute) {
+		return false
+	}
+
+	year, month, day := now.Date()
+	since := time.Date(year, month, day-max(user.ReminderDays, 1)+1, 0, 0, 0, 0, now.Location())
+	if lastLogged != nil && !lastLogged.Before(since) {
+		return false
+	}
+	return user.LastNudgedAt == nil || user.LastNudgedAt.Before(since)
+}
+
+func nudgeMessage(user *User, lang Language) string {
+	msg := lang.T("👋 Nothing logged today yet.")
+	if user.ReminderDays > 1 {
+		msg = lang.Tf("👋 Nothing logged in the last %d days.", user.ReminderDays)
+	}
+	return msg + "\n" + lang.T("Record an expense with e.g. !add G 12.50 Lunch, or turn these reminders off with !c reminders off.")
+}
+
+/**
+ * Sends nudges and bill reminders in the background until shut down.
+ */
+type Reminders struct {
+	bot  *telegramClient.BotAPI
+	stop chan struct{}
+	done chan struct{}
+}
+
+func StartReminders(bot *telegramClient.BotAPI) *Reminders {
+	reminders := &Reminders{bot: bot, stop: make(chan struct{}), done: make(chan struct{})}
+	go reminders.run()
+	return reminders
+}
+
+func (reminders *Reminders) run() {
+	defer close(reminders.done)
+
+	ticker := time.NewTicker(reminderInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			reminders.nudge(time.Now())
+			reminders.remindBills(time.Now())
+		case <-reminders.stop:
+			return
+		}
+	}
+}
+
+/**
+ * Nudge every user due one, at the pace broadcasts are delivered. The nudge
+ * is stored before it's sent: one lost to an error beats one sent twice.
+ * Nudges are in the language the user picked, English if they follow
+ * their Telegram app's, which is only known while they're talking to us.
+ */
+func (reminders *Reminders) nudge(now time.Time) {
+	users, err := r.UserRepo().GetWithReminders()
+	if err != nil {
+		slog.Error("Error loading the users to remind", "error", err)
+		return
+	}
+
+	pace := time.NewTicker(broadcastInterval)
+	defer pace.Stop()
+
+	for _, user := range users {
+		lastLogged, err := r.EventRepo().LastCreatedAt(user.ID)
+		if err != nil {
+			slog.Error("Error checking when the user last logged", "user_id", user.ID, "error", err)
+			continue
+		}
+		if !nudgeDue(user, lastLogged, now.In(userLocation(user))) {
+			continue
+		}
+
+		if err := r.UserRepo().SetNudgedAt(user.ID, now); err != nil {
+			slog.Error("Error storing the nudge", "user_id", user.ID, "error", err)
+			continue
+		}
+
+		select {
+		case <-pace.C:
+		case <-reminders.stop:
+			return
+		}
+
+		message := nudgeMessage(user, userLanguage(user, nil))
+		if _, err := reminders.bot.Send(telegramClient.NewMessage(user.UserID, message)); err != nil {
+			slog.Warn("Error sending a nudge", "user_id", user.ID, "error", err)
+			continue
+		}
+		slog.Info("Nudged user", "user_id", user.ID, "days", user.ReminderDays)
+	}
+}
+
+/**
+ * Stop looking for users to remind, waiting for the reminder being sent.
+ */
+func (reminders *Reminders) Shutdown(ctx context.Context) error {
+	close(reminders.stop)
+
+	select {
+	case <-reminders.done:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+/**
+ * Format: on|off|time <HH:MM>|days <N>|quiet <HH:MM-HH:MM|off>
+ * Setting when nudges are sent turns them on.
+ */
+func configureReminders(args []string, ctx MessageContext) CommandResult {
+	invalid := CommandResult{
+		Command:   Configuration,
+		Error:     fmt.Errorf("invalid reminder settings: %v", args),
+		UserError: "Use the format: !c reminders on|off|time <HH:MM>|days <N>|quiet <HH:MM-HH:MM|off>. Use !help config for guidance.",
+	}
+
+	user, err := ctx.Repos.UserRepo().GetByID(ctx.UserID)
+	if err != nil {
+		return CommandResult{Command: Configuration, Error: err, UserError: userErrors[Unknown]}
+	}
+
+	switch action := strings.ToLower(args[0]); {
+	case action == "on" && len(args) == 1:
+		user.Reminders = true
+	case action == "off" && len(args) == 1:
+		user.Reminders = false
+	case action == "time" && len(args) == 2:
+		at, ok := minuteOfDay(args[1])
+		if !ok {
+			return invalid
+		}
+		user.Reminders, user.ReminderTime = true, formatClock(at)
+	case action == "days" && len(args) == 2:
+		days, err := strconv.Atoi(args[1])
+		if err != nil || days < 1 || days > maxReminderDays {
+			return invalid
+		}
+		user.Reminders, user.ReminderDays = true, days
+	case action == "quiet" && len(args) == 2:
+		if strings.EqualFold(args[1], "off") {
+			user.QuietHours = ""
+			break
+		}
+		start, end, ok := parseQuietHours(args[1])
+		if !ok {
+			return invalid
+		}
+		user.QuietHours = formatClock(start) + "-" + formatClock(end)
+	default:
+		return invalid
+	}
+
+	// A nudge due during quiet hours would never be sent.
+	if at, _ := minuteOfDay(user.ReminderTime); inQuietHours(user.QuietHours, at) {
+		return CommandResult{
+			Command:   Configuration,
+			Error:     fmt.Errorf("reminder time %s within quiet hours %s", user.ReminderTime, user.QuietHours),
+			UserError: "Reminders can't be sent during quiet hours, pick a time outside them.",
+		}
+	}
+
+	if err := ctx.Repos.UserRepo().Update(user, "reminders", "reminder_time", "reminder_days", "quiet_hours"); err != nil {
+		return CommandResult{Command: Configuration, Error: err, UserError: userErrors[Unknown]}
+	}
+
+	return CommandResult{Command: Configuration, UserInfo: describeReminders(user, ctx.Language)}
+}
+
+func describeReminders(user *User, lang Language) string {
+	var msg string
+	switch {
+	case !user.Reminders:
+		msg = lang.T("🔕 Reminders off")
+	case user.ReminderDays > 1:
+		msg = lang.Tf("🔔 Reminders on: at %s after %d days without logging anything", user.ReminderTime, user.ReminderDays)
+	default:
+		msg = lang.Tf("🔔 Reminders on: at %s when nothing was logged that day", user.ReminderTime)
+	}
+
+	if user.QuietHours != "" {
+		msg += "\n" + lang.Tf("🌙 Quiet hours: %s", user.QuietHours)
+	}
+	return msg
+}
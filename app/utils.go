@@ -1,6 +1,8 @@
 package app
 
 import (
+	"errors"
+	"math/big"
 	"strconv"
 	"strings"
 
@@ -55,21 +57,26 @@ func findCategory(code string) (string, bool) {
 
 type AggregatedTransactions struct {
 	Category string
-	Total    float64
+	Currency string
+	Total    int64 // In minor units of the currency.
 	Count    int
 }
 
+// Totals per category, kept apart per currency as they can't be added up.
 func aggregateCategories(txs []*db.Transaction) []AggregatedTransactions {
-	aggMap := make(map[string]AggregatedTransactions)
+	type key struct{ category, currency string }
+	aggMap := make(map[key]AggregatedTransactions)
 
 	for _, tx := range txs {
-		if agg, exists := aggMap[tx.Category]; exists {
+		k := key{tx.Category, tx.Currency}
+		if agg, exists := aggMap[k]; exists {
 			agg.Total += tx.Amount
 			agg.Count++
-			aggMap[tx.Category] = agg
+			aggMap[k] = agg
 		} else {
-			aggMap[tx.Category] = AggregatedTransactions{
+			aggMap[k] = AggregatedTransactions{
 				Category: tx.Category,
+				Currency: tx.Currency,
 				Total:    tx.Amount,
 				Count:    1,
 			}
@@ -91,27 +98,52 @@ func aggregateCategories(txs []*db.Transaction) []AggregatedTransactions {
 /* ooooooooooooooooooooooooooooooooooooooooooo~~~~.88~ooooooooooooooooo */
 /*                                            d8888P                    */
 
+// Limits of a message: each of its lines is handled as a request of its own.
+const (
+	maxLines      = 50
+	maxLineLength = 160
+)
+
 /**
  * Validate the message length and content.
  */
 func validateMessage(message string) bool {
-	if message == "" || len(message) > 160 {
+	lines := messageLines(message)
+	if len(lines) == 0 || len(lines) > maxLines {
 		return false
 	}
+	for _, line := range lines {
+		if len(line) > maxLineLength {
+			return false
+		}
+	}
 	return true
 }
 
+// Split a message into its non-blank lines.
+func messageLines(message string) []string {
+	lines := []string{}
+	for line := range strings.SplitSeq(message, "\n") {
+		if line = strings.TrimSpace(line); line != "" {
+			lines = append(lines, line)
+		}
+	}
+	return lines
+}
+
 /**
  * Generate a SHA-256 hash of the message combined with its timestamp.
  * This helps to uniquely identify messages and prevent duplicates.
  * The batchIndex parameter ensures that duplicate amounts in batch adds generate unique hashes.
  * The currency parameter ensures same amount in different currencies are treated as different transactions.
+ * The timestamp is when the message was sent rather than when the transaction happened, so back-dated
+ * entries are told apart by their message while a redelivered message still collides with itself.
  */
-func generateMessageHash(category string, amount float64, notes string, timestamp time.Time, userId uint, batchIndex int, currency string) string {
+func generateMessageHash(category string, amount int64, notes string, timestamp time.Time, userId uint, batchIndex int, currency string) string {
 	hash := sha256.New()
 
 	hash.Write([]byte(category))
-	hash.Write([]byte(fmt.Sprintf("%f", amount)))
+	hash.Write([]byte(fmt.Sprintf("%d", amount)))
 	hash.Write([]byte(notes))
 	hash.Write([]byte(fmt.Sprintf("%d", timestamp.Unix())))
 	hash.Write([]byte(fmt.Sprintf("%d", userId)))
@@ -122,6 +154,16 @@ func generateMessageHash(category string, amount float64, notes string, timestam
 	return hex.EncodeToString(hashBytes)
 }
 
+/**
+ * Hash of a transaction recorded along with something else (e.g. a repayment
+ * or a trade). The record describes what it was recorded against (e.g. the
+ * debts repaid and what they owed), so the same one can't be recorded twice
+ * while identical ones sent in the same second are kept apart.
+ */
+func generateRecordHash(record string, category string, amount int64, notes string, timestamp time.Time, userId uint, currency string) string {
+	return generateMessageHash(category, amount, notes+"\x00"+record, timestamp, userId, 0, currency)
+}
+
 /*   dP                                                    dP  oo                          */
 /*   88                                                    88                              */
 /* d8888P88d888b..d8888b.88d888b..d8888b..d8888b..d8888b.d8888PdP.d8888b.88d888b..d8888b.  */
@@ -130,46 +172,115 @@ func generateMessageHash(category string, amount float64, notes string, timestam
 /*   dP  dP      `88888P8dP    dP`88888P'`88888P8`88888P'  dP  dP`88888P'dP    dP`88888P'  */
 /* ooooooooooooooooooooooooooooooooooooooooooooooooooooooooooooooooooooooooooooooooooooooo */
 
-// String to float64, handling commas and dots.
-func stringToFloat(amountStr string) (float64, error) {
-	return strconv.ParseFloat(strings.ReplaceAll(amountStr, ",", "."), 64)
-}
+// Batch amounts are enclosed in brackets and separated by semicolons, e.g. [12.5;8*2].
+const (
+	batchStart     = "["
+	batchEnd       = "]"
+	batchSeparator = ";"
+)
 
-// Parse multiple amounts from a string, handling batch amounts enclosed in parentheses.
-func processBatchAmounts(amountsStr string) ([]float64, error) {
-	var amounts []float64
+// Batches used to be written in parentheses, e.g. (12.5-8), which now reads as a subtraction.
+var errParenthesisedBatch = errors.New("batches are now written as [a;b]")
 
-	for amt := range strings.SplitSeq(amountsStr, "-") {
-		amount, err := stringToFloat(amt)
-		if err != nil {
-			return nil, err
-		}
-		amounts = append(amounts, amount)
+/**
+ * Whether an amount is a batch the way it used to be written: numbers
+ * separated by dashes, or by commas that don't make a number, all in
+ * parentheses (e.g. (12.5-8) or (3,4,5)).
+ */
+func parenthesisedBatch(amountStr string, numbers NumberFormat) bool {
+	inner, ok := strings.CutPrefix(amountStr, "(")
+	if !ok {
+		return false
+	}
+	inner, ok = strings.CutSuffix(inner, ")")
+	if !ok || strings.ContainsAny(inner, "()") {
+		return false
 	}
 
-	return amounts, nil
+	for _, separator := range []string{"-", ","} {
+		parts := strings.Split(inner, separator)
+		if len(parts) < 2 {
+			continue
+		}
+		if _, err := numbers.parseNumber(inner); separator == "," && err == nil {
+			continue
+		}
+		plain := true
+		for _, part := range parts {
+			if _, err := numbers.parseNumber(part); err != nil {
+				plain = false
+			}
+		}
+		if plain {
+			return true
+		}
+	}
+	return false
 }
 
-// Parse amounts which can be either a single amount or a batch enclosed in parentheses.
-func parseAmounts(amountStr string) ([]float64, error) {
+/**
+ * Parse amounts which can be either a single expression or a batch of them.
+ * Amounts are kept exact, they are only rounded once their currency is known,
+ * and numbers are read with the user's separators.
+ * Alongside each amount comes the expression it was worked out from, which is
+ * empty for plain numbers.
+ */
+func parseAmounts(amountStr string, numbers NumberFormat) ([]*big.Rat, []string, error) {
+	if parenthesisedBatch(amountStr, numbers) {
+		return nil, nil, errParenthesisedBatch
+	}
+
+	exprs := []string{amountStr}
 
-	// Handle batch amounts enclosed in parentheses
-	if strings.ContainsAny(amountStr, "()") {
-		return processBatchAmounts(strings.Trim(amountStr, "()"))
+	// Handle batch amounts enclosed in brackets
+	if batch, ok := strings.CutPrefix(amountStr, batchStart); ok {
+		batch, ok = strings.CutSuffix(batch, batchEnd)
+		if !ok {
+			return nil, nil, fmt.Errorf("missing closing %q", batchEnd)
+		}
+		exprs = strings.Split(batch, batchSeparator)
 	}
 
-	// Handle single amount
-	amount, err := stringToFloat(amountStr)
-	if err != nil {
-		return nil, err
+	amounts := make([]*big.Rat, 0, len(exprs))
+	expressions := make([]string, 0, len(exprs))
+
+	for _, expr := range exprs {
+		value, err := evaluateExpression(expr, numbers)
+		if err != nil {
+			return nil, nil, fmt.Errorf("%q: %w", expr, err)
+		}
+
+		// Plain numbers don't need their working shown.
+		if moneyPattern.MatchString(expr) {
+			expr = ""
+		}
+
+		amounts = append(amounts, value)
+		expressions = append(expressions, expr)
 	}
-	return []float64{amount}, nil
+
+	return amounts, expressions, nil
+}
+
+type ParsedTx struct {
+	Category         string
+	Amounts          []*big.Rat
+	Expressions      []string // Expression each amount was worked out from, empty for plain numbers.
+	Notes            string
+	Currency         string
+	ExplicitCurrency bool      // Whether the currency was given in the message.
+	Account          string    // Name of the account marked with @, if any.
+	Mentions         []string  // Other @names, kept in the notes as they aren't accounts.
+	Goal             string    // Name of the savings goal marked with #, if any.
+	Timestamp        time.Time // When it happened, now unless back-dated.
+	BackDated        bool      // Whether a date was given in the message.
 }
 
 /**
- * Validate and process an add transaction message.
+ * Validate and process an add transaction message, given the names of the
+ * user's accounts.
  */
-func parseAddTx(msg string, preferredCurrency string) (string, []float64, string, string, error) {
+func parseAddTx(msg string, preferredCurrency string, now time.Time, numbers NumberFormat, accounts map[string]bool) (ParsedTx, error) {
 
 	/**
 	 * Split the message into parts divided by spaces,
@@ -177,7 +288,7 @@ func parseAddTx(msg string, preferredCurrency string) (string, []float64, string
 	 */
 	parts := strings.Fields(msg)
 	if len(parts) < 2 {
-		return "", nil, "", "", fmt.Errorf("invalid message format")
+		return ParsedTx{}, fmt.Errorf("invalid message format")
 	}
 
 	category := parts[0]
@@ -188,45 +299,127 @@ func parseAddTx(msg string, preferredCurrency string) (string, []float64, string
 	if categoryName, exists := findCategory(category); exists {
 		category = categoryName
 	} else {
-		return "", nil, "", "", fmt.Errorf("invalid category alias")
+		return ParsedTx{}, fmt.Errorf("invalid category alias")
 	}
 
 	/**
-	 * Parse the transaction amount and ensure it's a valid float.
+	 * Parse the transaction amount(s) and ensure they are valid expressions.
 	 */
-	amounts, err := parseAmounts(parts[1])
+	amounts, expressions, err := parseAmounts(parts[1], numbers)
 	if err != nil {
-		return "", nil, "", "", fmt.Errorf("failed to parse amount %q: %w", parts[1], err)
+		return ParsedTx{}, fmt.Errorf("failed to parse amount %q: %w", parts[1], err)
 	}
 
 	// At least one valid amount is required
 	if len(amounts) == 0 {
-		return "", nil, "", "", fmt.Errorf("no valid amounts found")
+		return ParsedTx{}, fmt.Errorf("no valid amounts found")
 	}
 
 	/**
-	 * Extract transaction notes and preferred currency if they exist.
+	 * Pick the date (e.g. @yesterday, @2025-03-12 19:30) and account
+	 * (e.g. @visa) markers out of the notes. New accounts can't be named
+	 * like dates, but those named before a marker existed (e.g. @y) are
+	 * still theirs. Any other @ stays in the notes. Savings can also be
+	 * marked with the goal they count towards (e.g. #house).
 	 */
-	notes := ""
-	currency := preferredCurrency
+	account := ""
+	goal := ""
+	timestamp := now
+	backDated := false
+	mentions := []string{}
+	rest := []string{}
+
+	for i := 2; i < len(parts); i++ {
+		if name, ok := strings.CutPrefix(parts[i], "#"); ok && category == savingsCategory() && goalNamePattern.MatchString(strings.ToLower(name)) {
+			if goal != "" {
+				return ParsedTx{}, fmt.Errorf("more than one goal given")
+			}
+			goal = strings.ToLower(name)
+			continue
+		}
 
-	if len(parts) > 2 {
-		notesParts := parts[2:]
+		name, ok := strings.CutPrefix(parts[i], "@")
+		if !ok || name == "" {
+			rest = append(rest, parts[i])
+			continue
+		}
 
-		// Check if last part is a currency code starting with $
-		lastPart := notesParts[len(notesParts)-1]
-		if strings.HasPrefix(lastPart, "$") {
-			currencyCode := strings.ToUpper(strings.TrimPrefix(lastPart, "$"))
-			if isValidCurrency(currencyCode) {
-				currency = currencyCode
-				notesParts = notesParts[:len(notesParts)-1]
+		if accounts[strings.ToLower(name)] {
+			if account != "" {
+				return ParsedTx{}, fmt.Errorf("more than one account given")
+			}
+			account = strings.ToLower(name)
+			continue
+		}
+
+		if date, isDate := parseDateExpression(name, now); isDate {
+			if backDated {
+				return ParsedTx{}, fmt.Errorf("more than one date given")
 			}
+			timestamp, backDated = date, true
+
+			// An optional time of day (HH:MM) can follow the date.
+			if i+1 < len(parts) {
+				if withClock, isClock := parseClock(parts[i+1], date); isClock {
+					timestamp = withClock
+					i++
+				}
+			}
+			continue
 		}
 
-		notes = strings.Join(notesParts, " ")
+		// Not an account of theirs either: a mention (e.g. dinner with @bob), or a typo they're warned about.
+		mentions = append(mentions, parts[i])
+		rest = append(rest, parts[i])
+	}
+
+	if timestamp.After(now) {
+		return ParsedTx{}, fmt.Errorf("date %s is in the future", timestamp.Format(time.DateTime))
+	}
+
+	/**
+	 * Extract transaction notes and preferred currency if they exist.
+	 */
+	notesParts, currency := extractCurrency(rest, "")
+	explicit := currency != ""
+	if !explicit {
+		currency = preferredCurrency
+	}
+
+	return ParsedTx{
+		Category:         category,
+		Amounts:          amounts,
+		Expressions:      expressions,
+		Notes:            strings.Join(notesParts, " "),
+		Currency:         currency,
+		ExplicitCurrency: explicit,
+		Account:          account,
+		Mentions:         mentions,
+		Goal:             goal,
+		Timestamp:        timestamp,
+		BackDated:        backDated,
+	}, nil
+}
+
+/**
+ * Take the currency code off the end of a message if there's one (e.g. $USD),
+ * falling back to the given currency otherwise.
+ */
+func extractCurrency(parts []string, fallback string) ([]string, string) {
+	if len(parts) == 0 {
+		return parts, fallback
+	}
+
+	// Check if last part is a currency code starting with $
+	lastPart := parts[len(parts)-1]
+	if strings.HasPrefix(lastPart, "$") {
+		currencyCode := strings.ToUpper(strings.TrimPrefix(lastPart, "$"))
+		if isValidCurrency(currencyCode) {
+			return parts[:len(parts)-1], currencyCode
+		}
 	}
 
-	return category, amounts, notes, currency, nil
+	return parts, fallback
 }
 
 /**
@@ -302,7 +495,7 @@ func parseListOptions(args []string, timestamp time.Time) (ListOptions, error) {
 		}
 
 		// Try date filter
-		if t, err := time.Parse(dateLayout, arg); err == nil {
+		if t, err := time.ParseInLocation(dateLayout, arg, timestamp.Location()); err == nil {
 			opts.FromTime = t
 			continue
 		}
@@ -346,3 +539,73 @@ func beginningOfMonth(t time.Time) time.Time {
 		return time.Date(t.Year(), t.Month(), 28, 0, 0, 0, 0, t.Location()).AddDate(0, -1, 0)
 	}
 }
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "sunday": time.Sunday,
+	"mon": time.Monday, "monday": time.Monday,
+	"tue": time.Tuesday, "tuesday": time.Tuesday,
+	"wed": time.Wednesday, "wednesday": time.Wednesday,
+	"thu": time.Thursday, "thursday": time.Thursday,
+	"fri": time.Friday, "friday": time.Friday,
+	"sat": time.Saturday, "saturday": time.Saturday,
+}
+
+/**
+ * Resolve a date expression relative to now, in now's location:
+ * today, yesterday, a weekday (its last occurrence before today),
+ * DD/MM (this year, or last year if that is still to come),
+ * DD/MM/YYYY or YYYY-MM-DD. The time of day is kept from now.
+ */
+func parseDateExpression(expr string, now time.Time) (time.Time, bool) {
+	expr = strings.ToLower(expr)
+	clock := time.Duration(now.Hour())*time.Hour + time.Duration(now.Minute())*time.Minute + time.Duration(now.Second())*time.Second
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+
+	switch expr {
+	case "today", "tod":
+		return today.Add(clock), true
+	case "yesterday", "yday", "y":
+		return today.AddDate(0, 0, -1).Add(clock), true
+	}
+
+	if weekday, ok := weekdays[expr]; ok {
+		days := (int(today.Weekday()) - int(weekday) + 7) % 7
+		if days == 0 {
+			days = 7
+		}
+		return today.AddDate(0, 0, -days).Add(clock), true
+	}
+
+	for _, layout := range []string{"2/1/2006", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, expr, now.Location()); err == nil {
+			return t.Add(clock), true
+		}
+	}
+
+	if t, err := time.ParseInLocation("2/1", expr, now.Location()); err == nil {
+		t = time.Date(now.Year(), t.Month(), t.Day(), 0, 0, 0, 0, now.Location())
+		if t.After(today) {
+			t = t.AddDate(-1, 0, 0)
+		}
+		return t.Add(clock), true
+	}
+
+	return time.Time{}, false
+}
+
+// Set the time of day (HH:MM) of a date.
+func parseClock(clock string, date time.Time) (time.Time, bool) {
+	t, err := time.Parse("15:04", clock)
+	if err != nil {
+		return time.Time{}, false
+	}
+	return time.Date(date.Year(), date.Month(), date.Day(), t.Hour(), t.Minute(), 0, 0, date.Location()), true
+}
+
+// Resolve a user's configured timezone, falling back to UTC.
+func userLocation(user *db.User) *time.Location {
+	if loc, err := time.LoadLocation(user.Timezone); err == nil && user.Timezone != "" {
+		return loc
+	}
+	return time.UTC
+}
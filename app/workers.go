@@ -0,0 +1,150 @@
+package app
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	. "remind0/db"
+	"sync"
+
+	telegramClient "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+)
+
+/**
+ *                    _
+ *                   | |
+ * __      _____  _ __| | _____ _ __ ___
+ * \ \ /\ / / _ \| '__| |/ / _ \ '__/ __|
+ *  \ V  V / (_) | |  |   <  __/ |  \__ \
+ *   \_/\_/ \___/|_|  |_|\_\___|_|  |___/
+ *
+ * Updates are handled by a fixed set of workers, so a slow one only holds
+ * up its own chat. Each chat always goes to the same worker, which handles
+ * its updates one at a time in the order they were submitted.
+ *
+ * Queues are bounded: once a worker's queue is full, Submit waits, which
+ * in turn stops updates from being read from Telegram until it catches up.
+ * The offset isn't affected by the order in which updates are handled, it
+ * moves as soon as they're stored in the inbox.
+ *
+ * When shutting down, workers finish the update they're on and leave the
+ * queued ones in the inbox for the next run.
+ */
+
+type UpdatePool struct {
+	bot    *telegramClient.BotAPI
+	shards []chan *InboxUpdate
+
+	mu       sync.Mutex
+	inFlight map[int]bool // Updates submitted and not handled yet, by update ID.
+
+	stop    chan struct{}
+	workers sync.WaitGroup
+}
+
+func NewUpdatePool(bot *telegramClient.BotAPI, workers int, queueSize int) *UpdatePool {
+	pool := &UpdatePool{
+		bot:      bot,
+		shards:   make([]chan *InboxUpdate, workers),
+		inFlight: map[int]bool{},
+		stop:     make(chan struct{}),
+	}
+
+	for i := range pool.shards {
+		pool.shards[i] = make(chan *InboxUpdate, queueSize)
+		pool.workers.Add(1)
+		go pool.work(pool.shards[i])
+	}
+
+	return pool
+}
+
+/**
+ * Queue an update for its chat's worker, waiting if the queue is full.
+ * Updates already queued or being handled are ignored, and so is every
+ * update once shutting down.
+ */
+func (pool *UpdatePool) Submit(item *InboxUpdate) {
+	pool.mu.Lock()
+	if pool.inFlight[item.UpdateID] {
+		pool.mu.Unlock()
+		return
+	}
+	pool.inFlight[item.UpdateID] = true
+	pool.mu.Unlock()
+
+	shard := item.ChatID % int64(len(pool.shards))
+	if shard < 0 {
+		shard = -shard // Group chats have negative IDs.
+	}
+	select {
+	case pool.shards[shard] <- item:
+	case <-pool.stop:
+		pool.release(item)
+	}
+}
+
+/**
+ * Stop the workers, waiting until they're done with their current update
+ * or ctx expires.
+ */
+func (pool *UpdatePool) Shutdown(ctx context.Context) error {
+	close(pool.stop)
+
+	done := make(chan struct{})
+	go func() {
+		pool.workers.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("workers still busy: %w", ctx.Err())
+	}
+}
+
+func (pool *UpdatePool) release(item *InboxUpdate) {
+	pool.mu.Lock()
+	delete(pool.inFlight, item.UpdateID)
+	pool.mu.Unlock()
+}
+
+func (pool *UpdatePool) work(queue chan *InboxUpdate) {
+	defer pool.workers.Done()
+
+	// Chats with an update that failed: their later updates must wait for
+	// it to be retried, so they're put back in the inbox until the queue
+	// runs dry.
+	held := map[int64]bool{}
+
+	for {
+		var item *InboxUpdate
+		select {
+		case <-pool.stop:
+			return
+		case item = <-queue:
+		}
+
+		// Both may be ready, stopping comes first.
+		select {
+		case <-pool.stop:
+			pool.release(item)
+			return
+		default:
+		}
+
+		if held[item.ChatID] {
+			slog.Info("Holding update until an earlier one of its chat is retried", "update_id", item.UpdateID, "chat_id", item.ChatID)
+		} else if !processUpdate(pool.bot, item) {
+			held[item.ChatID] = true
+		}
+
+		pool.release(item)
+
+		if len(queue) == 0 {
+			clear(held)
+		}
+	}
+}
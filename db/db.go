@@ -2,7 +2,9 @@ package db
 
 import (
 	"fmt"
-	"log"
+	"log/slog"
+	"remind0/logging"
+	"remind0/monitoring"
 
 	_ "github.com/tursodatabase/libsql-client-go/libsql"
 
@@ -16,25 +18,39 @@ import (
 
 var DBClient *gorm.DB
 
-func InitialiseDB(DSN string) (*gorm.DB, error) {
+func ConnectDB(DSN string) (*gorm.DB, error) {
 	var err error
 
 	// Setup custom dialector for the sqlite provider:
 	tursoDialector := sqlite.Config{DriverName: "libsql", DSN: DSN}
 
 	// Connect to the database:
-	DBClient, err = gorm.Open(sqlite.New(tursoDialector), &gorm.Config{})
+	DBClient, err = gorm.Open(sqlite.New(tursoDialector), &gorm.Config{Logger: logging.Gorm()})
 	if err != nil {
 		return nil, err
 	}
-	log.Println("✅ Database connection established")
+	if err = DBClient.Use(monitoring.QueryTimer{}); err != nil {
+		return nil, err
+	}
+	if err = DBClient.Use(transientErrors{}); err != nil {
+		return nil, err
+	}
+	slog.Info("Database connection established")
 
-	// Run required migrations:
-	err = DBClient.AutoMigrate(&User{}, &Transaction{}, &Offset{})
+	return DBClient, nil
+}
+
+func InitialiseDB(DSN string) (*gorm.DB, error) {
+	db, err := ConnectDB(DSN)
 	if err != nil {
+		return nil, err
+	}
+
+	// Run pending migrations:
+	if _, err = MigrateUp(db); err != nil {
 		return nil, fmt.Errorf("⚠️ Migration failed: %v", err)
 	}
-	log.Println("✅ Database migrated successfully")
+	slog.Info("Database migrated")
 
-	return DBClient, nil
+	return db, nil
 }
@@ -0,0 +1,48 @@
+package db
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+/**
+ * Marks database errors worth trying again (e.g. the database was unreachable
+ * or busy). Missing records and constraint violations are left unmarked, as
+ * they would fail the same way every time.
+ */
+var ErrTransient = errors.New("transient database error")
+
+// Whether err is a unique constraint violation, from sqlite or Turso alike.
+func IsDuplicate(err error) bool {
+	return err != nil && strings.Contains(err.Error(), "UNIQUE constraint failed")
+}
+
+type transientErrors struct{}
+
+func (transientErrors) Name() string {
+	return "db:transient_errors"
+}
+
+func (transientErrors) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	return errors.Join(
+		callbacks.Create().After("gorm:create").Register("db:mark_create", markTransient),
+		callbacks.Query().After("gorm:query").Register("db:mark_query", markTransient),
+		callbacks.Update().After("gorm:update").Register("db:mark_update", markTransient),
+		callbacks.Delete().After("gorm:delete").Register("db:mark_delete", markTransient),
+		callbacks.Row().After("gorm:row").Register("db:mark_row", markTransient),
+		callbacks.Raw().After("gorm:raw").Register("db:mark_raw", markTransient),
+	)
+}
+
+func markTransient(db *gorm.DB) {
+	err := db.Error
+	if err == nil || errors.Is(err, ErrTransient) || errors.Is(err, gorm.ErrRecordNotFound) ||
+		strings.Contains(err.Error(), "constraint failed") {
+		return
+	}
+	db.Error = fmt.Errorf("%w: %w", ErrTransient, err)
+}
@@ -0,0 +1,151 @@
+package db
+
+import (
+	"fmt"
+	"log/slog"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+/**
+ * Versioned schema migrations.
+ *
+ * Each migration has a number, an up step and (ideally) a down step. The
+ * versions applied so far are recorded in the schema_migrations table, so
+ * each migration runs exactly once. Every step runs in its own transaction
+ * together with its record, so a failing step leaves nothing behind.
+ *
+ * Migrations must never depend on the current models, which keep changing:
+ * they describe the schema as it was when they were written.
+ */
+
+type Migration struct {
+	Version uint
+	Name    string
+	Up      func(tx *gorm.DB) error
+	Down    func(tx *gorm.DB) error // Nil when the migration can't be rolled back.
+}
+
+type MigrationStatus struct {
+	Migration
+	AppliedAt *time.Time // Nil while pending.
+}
+
+// Create the table keeping track of the applied migrations, if needed.
+func prepareMigrations(db *gorm.DB) error {
+	return db.AutoMigrate(&SchemaMigration{})
+}
+
+func appliedMigrations(db *gorm.DB) (map[uint]SchemaMigration, error) {
+	var records []SchemaMigration
+	if err := db.Find(&records).Error; err != nil {
+		return nil, err
+	}
+
+	applied := map[uint]SchemaMigration{}
+	for _, record := range records {
+		applied[record.Version] = record
+	}
+	return applied, nil
+}
+
+/**
+ * Apply every pending migration, in order. Returns the ones applied.
+ */
+func MigrateUp(db *gorm.DB) ([]Migration, error) {
+	if err := prepareMigrations(db); err != nil {
+		return nil, err
+	}
+
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	done := []Migration{}
+	for _, m := range migrations {
+		if _, exists := applied[m.Version]; exists {
+			continue
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Up(tx); err != nil {
+				return err
+			}
+			return tx.Create(&SchemaMigration{Version: m.Version, Name: m.Name}).Error
+		})
+		if err != nil {
+			return done, fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, err)
+		}
+
+		slog.Info("Applied migration", "version", m.Version, "migration", m.Name)
+		done = append(done, m)
+	}
+
+	return done, nil
+}
+
+/**
+ * Roll back the latest applied migration. Returns nil if none was applied.
+ */
+func MigrateDown(db *gorm.DB) (*Migration, error) {
+	if err := prepareMigrations(db); err != nil {
+		return nil, err
+	}
+
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := len(migrations) - 1; i >= 0; i-- {
+		m := migrations[i]
+		if _, exists := applied[m.Version]; !exists {
+			continue
+		}
+
+		if m.Down == nil {
+			return nil, fmt.Errorf("migration %d (%s) can't be rolled back", m.Version, m.Name)
+		}
+
+		err := db.Transaction(func(tx *gorm.DB) error {
+			if err := m.Down(tx); err != nil {
+				return err
+			}
+			return tx.Delete(&SchemaMigration{}, m.Version).Error
+		})
+		if err != nil {
+			return nil, fmt.Errorf("migration %d (%s): %w", m.Version, m.Name, err)
+		}
+
+		slog.Info("Rolled back migration", "version", m.Version, "migration", m.Name)
+		return &m, nil
+	}
+
+	return nil, nil
+}
+
+/**
+ * Every known migration along with when it was applied, if it was.
+ */
+func GetMigrationStatus(db *gorm.DB) ([]MigrationStatus, error) {
+	if err := prepareMigrations(db); err != nil {
+		return nil, err
+	}
+
+	applied, err := appliedMigrations(db)
+	if err != nil {
+		return nil, err
+	}
+
+	status := make([]MigrationStatus, 0, len(migrations))
+	for _, m := range migrations {
+		s := MigrationStatus{Migration: m}
+		if record, exists := applied[m.Version]; exists {
+			s.AppliedAt = &record.AppliedAt
+		}
+		status = append(status, s)
+	}
+	return status, nil
+}
@@ -0,0 +1,549 @@
+package db
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+/**
+ * Every schema migration, in order. Append new ones at the end, with the
+ * next version number, and never change one that has been released.
+ */
+var migrations = []Migration{
+	{Version: 1, Name: "baseline", Up: baselineUp},
+	{Version: 2, Name: "update inbox", Up: inboxUp, Down: inboxDown},
+	{Version: 3, Name: "inbox chat", Up: inboxChatUp, Down: inboxChatDown},
+	{Version: 4, Name: "invites", Up: invitesUp, Down: invitesDown},
+	{Version: 5, Name: "user blocking", Up: userBlockingUp, Down: userBlockingDown},
+	{Version: 6, Name: "user language", Up: userLanguageUp, Down: userLanguageDown},
+	{Version: 7, Name: "user number format", Up: userNumberFormatUp, Down: userNumberFormatDown},
+	{Version: 8, Name: "custom currencies", Up: customCurrenciesUp, Down: customCurrenciesDown},
+	{Version: 9, Name: "investments", Up: investmentsUp, Down: investmentsDown},
+	{Version: 10, Name: "savings goals", Up: goalsUp, Down: goalsDown},
+	{Version: 11, Name: "logging reminders", Up: remindersUp, Down: remindersDown},
+	{Version: 12, Name: "bills", Up: billsUp, Down: billsDown},
+}
+
+/**
+ * The schema as it was before versioned migrations, when AutoMigrate ran on
+ * every start. Databases created back then are brought up to date (missing
+ * tables and columns, amounts in minor units) and fresh ones are created.
+ * Rolling it back would mean dropping everything, so it can't be.
+ */
+func baselineUp(tx *gorm.DB) error {
+	// Amounts must be converted while their columns are still real.
+	if err := convertToMinorUnits(tx); err != nil {
+		return err
+	}
+
+	return tx.AutoMigrate(&v1User{}, &v1Transaction{}, &v1Offset{}, &v1TransactionEvent{}, &v1Group{}, &v1GroupMember{}, &v1Split{}, &v1Settlement{}, &v1Debt{}, &v1DebtRepayment{}, &v1Account{}, &v1Transfer{}, &v1Attachment{})
+}
+
+/**
+ * Amounts used to be floating point numbers of the major unit (e.g. 12.5
+ * dollars), they are now whole numbers of the minor unit (e.g. 1250 cents).
+ * Each money column still declared as real is scaled by its currency, its
+ * type is then changed by the baseline. JPY and KRW were the supported
+ * currencies without minor units.
+ */
+func convertToMinorUnits(tx *gorm.DB) error {
+	scale := func(currency string) string {
+		return fmt.Sprintf("(CASE WHEN %s IN ('JPY', 'KRW') THEN 1 ELSE 100 END)", currency)
+	}
+
+	columns := []struct {
+		table    string
+		column   string
+		currency string // SQL expression for the currency of each row
+	}{
+		{"transactions", "amount", "currency"},
+		{"splits", "amount", "(SELECT currency FROM transactions WHERE transactions.id = splits.transaction_id)"},
+		{"settlements", "amount", "currency"},
+		{"debts", "amount", "currency"},
+		{"debt_repayments", "amount", "(SELECT currency FROM debts WHERE debts.id = debt_repayments.debt_id)"},
+		{"accounts", "opening_balance", "currency"},
+		{"transfers", "amount", "currency"},
+	}
+
+	for _, c := range columns {
+		isReal, err := isRealColumn(tx, c.table, c.column)
+		if err != nil {
+			return err
+		}
+		if !isReal {
+			continue
+		}
+
+		err = tx.Exec(fmt.Sprintf(
+			"UPDATE %s SET %s = CAST(ROUND(%s * %s) AS INTEGER)", c.table, c.column, c.column, scale(c.currency),
+		)).Error
+		if err != nil {
+			return err
+		}
+
+		// Snapshots in the audit log follow the transactions they describe.
+		if c.table == "transactions" && tx.Migrator().HasTable("transaction_events") {
+			for _, value := range []string{"old_value", "new_value"} {
+				err = tx.Exec(fmt.Sprintf(
+					"UPDATE transaction_events SET %[1]s = json_set(%[1]s, '$.amount', CAST(ROUND(json_extract(%[1]s, '$.amount') * %[2]s) AS INTEGER)) WHERE %[1]s != ''",
+					value, scale(fmt.Sprintf("json_extract(%s, '$.currency')", value)),
+				)).Error
+				if err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+func isRealColumn(tx *gorm.DB, table string, column string) (bool, error) {
+	if !tx.Migrator().HasTable(table) {
+		return false, nil
+	}
+
+	columnTypes, err := tx.Migrator().ColumnTypes(table)
+	if err != nil {
+		return false, err
+	}
+	for _, columnType := range columnTypes {
+		if columnType.Name() == column {
+			return strings.HasPrefix(strings.ToLower(columnType.DatabaseTypeName()), "real"), nil
+		}
+	}
+	return false, nil
+}
+
+/**
+ * Snapshot of the models at the time of the baseline.
+ */
+type v1User struct {
+	ID                uint            `gorm:"primaryKey"`
+	UserID            int64           `gorm:"uniqueIndex"`
+	FirstName         string          `gorm:"index"`
+	LastName          string          `gorm:"index"`
+	Username          string          `gorm:"uniqueIndex"`
+	PreferredCurrency string          `gorm:"default:'NZD'"`
+	Timezone          string          `gorm:"default:'UTC'"`
+	Expenses          []v1Transaction `gorm:"foreignKey:UserID"`
+}
+
+type v1Account struct {
+	ID             uint   `gorm:"primaryKey"`
+	UserID         uint   `gorm:"uniqueIndex:idx_user_account"`
+	User           v1User `gorm:"constraint:OnDelete:CASCADE"`
+	Name           string `gorm:"uniqueIndex:idx_user_account"`
+	Type           string
+	OpeningBalance int64
+	Currency       string `gorm:"default:'NZD'"`
+	CreatedAt      time.Time
+}
+
+type v1Attachment struct {
+	ID            uint `gorm:"primaryKey"`
+	TransactionID uint `gorm:"index"`
+	UserID        uint `gorm:"index"`
+	Kind          string
+	FileID        string
+	FileUniqueID  string
+	FileName      string
+	MimeType      string
+	Timestamp     time.Time `gorm:"autoCreateTime"`
+}
+
+type v1Split struct {
+	ID            uint   `gorm:"primaryKey"`
+	TransactionID uint   `gorm:"index"`
+	UserID        uint   `gorm:"index"`
+	User          v1User `gorm:"constraint:OnDelete:CASCADE"`
+	Amount        int64
+}
+
+type v1Transaction struct {
+	ID          uint   `gorm:"primaryKey"`
+	UserID      uint   `gorm:"index"`
+	User        v1User `gorm:"constraint:OnDelete:CASCADE"`
+	Category    string `gorm:"index"`
+	Amount      int64
+	Currency    string `gorm:"default:'NZD';index"`
+	Notes       string
+	Timestamp   time.Time `gorm:"autoCreateTime"`
+	Hash        string    `gorm:"uniqueIndex"`
+	GroupID     *uint     `gorm:"index"`
+	Splits      []v1Split `gorm:"foreignKey:TransactionID"`
+	AccountID   *uint     `gorm:"index"`
+	Account     *v1Account
+	Attachments []v1Attachment `gorm:"foreignKey:TransactionID"`
+}
+
+type v1Offset struct {
+	ID     uint `gorm:"primaryKey"`
+	Offset int
+}
+
+type v1TransactionEvent struct {
+	ID            uint `gorm:"primaryKey"`
+	TransactionID uint `gorm:"index"`
+	UserID        uint `gorm:"index"`
+	Action        string
+	Source        string
+	OldValue      string
+	NewValue      string
+	Timestamp     time.Time `gorm:"autoCreateTime"`
+}
+
+type v1Group struct {
+	ID     uint  `gorm:"primaryKey"`
+	ChatID int64 `gorm:"uniqueIndex"`
+	Title  string
+}
+
+type v1GroupMember struct {
+	ID      uint   `gorm:"primaryKey"`
+	GroupID uint   `gorm:"uniqueIndex:idx_group_member"`
+	UserID  uint   `gorm:"uniqueIndex:idx_group_member"`
+	User    v1User `gorm:"constraint:OnDelete:CASCADE"`
+}
+
+type v1Settlement struct {
+	ID         uint `gorm:"primaryKey"`
+	GroupID    uint `gorm:"index"`
+	FromUserID uint `gorm:"index"`
+	ToUserID   uint `gorm:"index"`
+	Amount     int64
+	Currency   string
+	Timestamp  time.Time `gorm:"autoCreateTime"`
+}
+
+type v1DebtRepayment struct {
+	ID            uint `gorm:"primaryKey"`
+	DebtID        uint `gorm:"index"`
+	Amount        int64
+	TransactionID *uint     `gorm:"index"`
+	Timestamp     time.Time `gorm:"autoCreateTime"`
+}
+
+type v1Debt struct {
+	ID           uint   `gorm:"primaryKey"`
+	UserID       uint   `gorm:"index"`
+	User         v1User `gorm:"constraint:OnDelete:CASCADE"`
+	Counterparty string `gorm:"index"`
+	Kind         string
+	Amount       int64
+	Currency     string `gorm:"default:'NZD'"`
+	Notes        string
+	Timestamp    time.Time         `gorm:"autoCreateTime"`
+	Repayments   []v1DebtRepayment `gorm:"foreignKey:DebtID"`
+}
+
+type v1Transfer struct {
+	ID            uint `gorm:"primaryKey"`
+	UserID        uint `gorm:"index"`
+	FromAccountID uint `gorm:"index"`
+	ToAccountID   uint `gorm:"index"`
+	Amount        int64
+	Currency      string
+	Notes         string
+	Timestamp     time.Time `gorm:"autoCreateTime"`
+}
+
+func (v1User) TableName() string             { return "users" }
+func (v1Account) TableName() string          { return "accounts" }
+func (v1Attachment) TableName() string       { return "attachments" }
+func (v1Split) TableName() string            { return "splits" }
+func (v1Transaction) TableName() string      { return "transactions" }
+func (v1Offset) TableName() string           { return "offsets" }
+func (v1TransactionEvent) TableName() string { return "transaction_events" }
+func (v1Group) TableName() string            { return "groups" }
+func (v1GroupMember) TableName() string      { return "group_members" }
+func (v1Settlement) TableName() string       { return "settlements" }
+func (v1DebtRepayment) TableName() string    { return "debt_repayments" }
+func (v1Debt) TableName() string             { return "debts" }
+func (v1Transfer) TableName() string         { return "transfers" }
+
+/**
+ * Incoming updates are stored before being handled.
+ */
+type v2InboxUpdate struct {
+	UpdateID      int `gorm:"primaryKey;autoIncrement:false"`
+	Payload       string
+	Status        string `gorm:"index"`
+	Attempts      int
+	LastError     string
+	NextAttemptAt time.Time `gorm:"index"`
+	ReceivedAt    time.Time `gorm:"autoCreateTime"`
+	ProcessedAt   *time.Time
+}
+
+func (v2InboxUpdate) TableName() string { return "inbox_updates" }
+
+func inboxUp(tx *gorm.DB) error {
+	return tx.Migrator().CreateTable(&v2InboxUpdate{})
+}
+
+func inboxDown(tx *gorm.DB) error {
+	return tx.Migrator().DropTable(&v2InboxUpdate{})
+}
+
+/**
+ * Updates are handled concurrently, but in order within each chat.
+ */
+type v3InboxUpdate struct {
+	ChatID int64 `gorm:"index"`
+}
+
+func (v3InboxUpdate) TableName() string { return "inbox_updates" }
+
+func inboxChatUp(tx *gorm.DB) error {
+	if err := tx.Migrator().AddColumn(&v3InboxUpdate{}, "ChatID"); err != nil {
+		return err
+	}
+	if err := tx.Migrator().CreateIndex(&v3InboxUpdate{}, "ChatID"); err != nil {
+		return err
+	}
+
+	// Only messages were handled so far.
+	return tx.Exec("UPDATE inbox_updates SET chat_id = COALESCE(json_extract(payload, '$.message.chat.id'), 0)").Error
+}
+
+func inboxChatDown(tx *gorm.DB) error {
+	if err := tx.Migrator().DropIndex(&v3InboxUpdate{}, "ChatID"); err != nil {
+		return err
+	}
+	return tx.Migrator().DropColumn(&v3InboxUpdate{}, "ChatID")
+}
+
+/**
+ * Invite codes letting new users in when the bot is invite-only.
+ */
+type v4Invite struct {
+	ID          uint   `gorm:"primaryKey"`
+	Code        string `gorm:"uniqueIndex"`
+	CreatedByID uint   `gorm:"index"`
+	UsedByID    *uint  `gorm:"index"`
+	UsedAt      *time.Time
+	ExpiresAt   time.Time
+	CreatedAt   time.Time `gorm:"autoCreateTime"`
+}
+
+func (v4Invite) TableName() string { return "invites" }
+
+func invitesUp(tx *gorm.DB) error {
+	return tx.Migrator().CreateTable(&v4Invite{})
+}
+
+func invitesDown(tx *gorm.DB) error {
+	return tx.Migrator().DropTable(&v4Invite{})
+}
+
+/**
+ * Admins can block users from using the bot.
+ */
+type v5User struct {
+	Blocked bool `gorm:"default:false"`
+}
+
+func (v5User) TableName() string { return "users" }
+
+func userBlockingUp(tx *gorm.DB) error {
+	return tx.Migrator().AddColumn(&v5User{}, "Blocked")
+}
+
+func userBlockingDown(tx *gorm.DB) error {
+	return tx.Migrator().DropColumn(&v5User{}, "Blocked")
+}
+
+/**
+ * Users can pick the language the bot replies in.
+ */
+type v6User struct {
+	Language string `gorm:"default:''"`
+}
+
+func (v6User) TableName() string { return "users" }
+
+func userLanguageUp(tx *gorm.DB) error {
+	return tx.Migrator().AddColumn(&v6User{}, "Language")
+}
+
+func userLanguageDown(tx *gorm.DB) error {
+	return tx.Migrator().DropColumn(&v6User{}, "Language")
+}
+
+/**
+ * Users can pick how amounts are written (e.g. 1,234.56 or 1.234,56).
+ */
+type v7User struct {
+	NumberFormat string `gorm:"default:''"`
+}
+
+func (v7User) TableName() string { return "users" }
+
+func userNumberFormatUp(tx *gorm.DB) error {
+	return tx.Migrator().AddColumn(&v7User{}, "NumberFormat")
+}
+
+func userNumberFormatDown(tx *gorm.DB) error {
+	return tx.Migrator().DropColumn(&v7User{}, "NumberFormat")
+}
+
+/**
+ * Currencies defined by users, besides the ISO 4217 ones.
+ */
+type v8CustomCurrency struct {
+	ID          uint   `gorm:"primaryKey"`
+	Code        string `gorm:"uniqueIndex"`
+	Name        string
+	Decimals    int
+	CreatedByID uint      `gorm:"index"`
+	CreatedAt   time.Time `gorm:"autoCreateTime"`
+}
+
+func (v8CustomCurrency) TableName() string { return "custom_currencies" }
+
+func customCurrenciesUp(tx *gorm.DB) error {
+	return tx.Migrator().CreateTable(&v8CustomCurrency{})
+}
+
+func customCurrenciesDown(tx *gorm.DB) error {
+	return tx.Migrator().DropTable(&v8CustomCurrency{})
+}
+
+/**
+ * Investments bought and sold, and the prices users enter for them.
+ */
+type v9Trade struct {
+	ID            uint   `gorm:"primaryKey"`
+	UserID        uint   `gorm:"index:idx_user_instrument"`
+	Instrument    string `gorm:"index:idx_user_instrument"`
+	Kind          string
+	Units         int64
+	Amount        int64
+	Currency      string
+	TransactionID *uint
+	Timestamp     time.Time
+}
+
+type v9InstrumentPrice struct {
+	ID         uint   `gorm:"primaryKey"`
+	UserID     uint   `gorm:"uniqueIndex:idx_user_price"`
+	Instrument string `gorm:"uniqueIndex:idx_user_price"`
+	Price      string
+	Currency   string
+	UpdatedAt  time.Time
+}
+
+func (v9Trade) TableName() string           { return "trades" }
+func (v9InstrumentPrice) TableName() string { return "instrument_prices" }
+
+func investmentsUp(tx *gorm.DB) error {
+	return tx.Migrator().CreateTable(&v9Trade{}, &v9InstrumentPrice{})
+}
+
+func investmentsDown(tx *gorm.DB) error {
+	return tx.Migrator().DropTable(&v9Trade{}, &v9InstrumentPrice{})
+}
+
+/**
+ * Savings goals, which savings transactions can count towards.
+ */
+type v10Goal struct {
+	ID        uint   `gorm:"primaryKey"`
+	UserID    uint   `gorm:"uniqueIndex:idx_user_goal"`
+	Name      string `gorm:"uniqueIndex:idx_user_goal"`
+	Target    int64
+	Currency  string
+	Deadline  *time.Time
+	CreatedAt time.Time
+}
+
+type v10Transaction struct {
+	GoalID *uint `gorm:"index"`
+}
+
+func (v10Goal) TableName() string        { return "goals" }
+func (v10Transaction) TableName() string { return "transactions" }
+
+func goalsUp(tx *gorm.DB) error {
+	if err := tx.Migrator().CreateTable(&v10Goal{}); err != nil {
+		return err
+	}
+	if err := tx.Migrator().AddColumn(&v10Transaction{}, "GoalID"); err != nil {
+		return err
+	}
+	return tx.Migrator().CreateIndex(&v10Transaction{}, "GoalID")
+}
+
+func goalsDown(tx *gorm.DB) error {
+	if err := tx.Migrator().DropIndex(&v10Transaction{}, "GoalID"); err != nil {
+		return err
+	}
+	if err := tx.Migrator().DropColumn(&v10Transaction{}, "GoalID"); err != nil {
+		return err
+	}
+	return tx.Migrator().DropTable(&v10Goal{})
+}
+
+/**
+ * Users can be nudged when they haven't logged anything for a while.
+ */
+type v11User struct {
+	Reminders    bool   `gorm:"default:false"`
+	ReminderTime string `gorm:"default:'20:00'"`
+	ReminderDays int    `gorm:"default:1"`
+	QuietHours   string `gorm:"default:''"`
+	LastNudgedAt *time.Time
+}
+
+func (v11User) TableName() string { return "users" }
+
+var v11UserColumns = []string{"Reminders", "ReminderTime", "ReminderDays", "QuietHours", "LastNudgedAt"}
+
+func remindersUp(tx *gorm.DB) error {
+	for _, column := range v11UserColumns {
+		if err := tx.Migrator().AddColumn(&v11User{}, column); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func remindersDown(tx *gorm.DB) error {
+	for _, column := range v11UserColumns {
+		if err := tx.Migrator().DropColumn(&v11User{}, column); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/**
+ * Bills paid every month, with reminders before they're due.
+ */
+type v12Bill struct {
+	ID         uint   `gorm:"primaryKey"`
+	UserID     uint   `gorm:"uniqueIndex:idx_user_bill"`
+	Name       string `gorm:"uniqueIndex:idx_user_bill"`
+	Amount     int64
+	Currency   string
+	Category   string
+	DueDay     int
+	NextDue    time.Time `gorm:"index"`
+	RemindDays int
+	RemindedAt *time.Time
+	CreatedAt  time.Time
+}
+
+func (v12Bill) TableName() string { return "bills" }
+
+func billsUp(tx *gorm.DB) error {
+	return tx.Migrator().CreateTable(&v12Bill{})
+}
+
+func billsDown(tx *gorm.DB) error {
+	return tx.Migrator().DropTable(&v12Bill{})
+}
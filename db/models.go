@@ -15,7 +15,16 @@ type User struct {
 	LastName          string        `gorm:"index"`             // Index last names
 	Username          string        `gorm:"uniqueIndex"`       // Index usernames
 	PreferredCurrency string        `gorm:"default:'NZD'"`     // User's preferred currency
+	Timezone          string        `gorm:"default:'UTC'"`     // IANA timezone dates are interpreted in
+	Blocked           bool          `gorm:"default:false"`     // Blocked users are turned away by the bot
+	Language          string        `gorm:"default:''"`        // Language replies are in, the one Telegram reports if empty
+	NumberFormat      string        `gorm:"default:''"`        // Separators amounts are written with, the language's if empty
+	Reminders         bool          `gorm:"default:false"`     // Nudge the user when nothing was logged
+	ReminderTime      string        `gorm:"default:'20:00'"`   // Local time (HH:MM) nudges are sent from
+	ReminderDays      int           `gorm:"default:1"`         // Days without logging before a nudge
+	QuietHours        string        `gorm:"default:''"`        // Local HH:MM-HH:MM span without nudges, none if empty
 	Expenses          []Transaction `gorm:"foreignKey:UserID"` // One-to-Many Relationship
+	LastNudgedAt      *time.Time    // When the user was last nudged, so nudges survive restarts
 }
 
 /*
@@ -25,15 +34,198 @@ type User struct {
  *
  */
 type Transaction struct {
-	ID        uint   `gorm:"primaryKey"`
-	UserID    uint   `gorm:"index"`
-	User      User   `gorm:"constraint:OnDelete:CASCADE"`
-	Category  string `gorm:"index"`
-	Amount    float64
-	Currency  string `gorm:"default:'NZD';index"` // ISO 4217 currency code
-	Notes     string
-	Timestamp time.Time `gorm:"autoCreateTime"`
-	Hash      string    `gorm:"uniqueIndex"`
+	ID          uint   `gorm:"primaryKey"`
+	UserID      uint   `gorm:"index"`
+	User        User   `gorm:"constraint:OnDelete:CASCADE"`
+	Category    string `gorm:"index"`
+	Amount      int64  // In minor units of the currency (e.g. cents)
+	Currency    string `gorm:"default:'NZD';index"` // ISO 4217 currency code
+	Notes       string
+	Timestamp   time.Time `gorm:"autoCreateTime"`
+	Hash        string    `gorm:"uniqueIndex"`
+	GroupID     *uint     `gorm:"index"`                    // Group ledger the transaction belongs to, if any
+	Splits      []Split   `gorm:"foreignKey:TransactionID"` // How a group expense is shared
+	AccountID   *uint     `gorm:"index"`                    // Account the money moved through, if any
+	Account     *Account
+	GoalID      *uint `gorm:"index"` // Savings goal the transaction counts towards, if any
+	Goal        *Goal
+	Attachments []Attachment `gorm:"foreignKey:TransactionID"` // Receipts sent along with the transaction
+	Expression  string       `gorm:"-"`                        // Arithmetic the amount was worked out from, only echoed back
+	Mentions    []string     `gorm:"-"`                        // @names that aren't accounts, only echoed back as a warning
+}
+
+/*
+ * 							Attachment Model
+ *
+ * This model is used to store the photos and documents (e.g. receipts)
+ * sent along with a transaction. Files stay on Telegram's servers and
+ * are sent back using their file ID.
+ *
+ */
+type Attachment struct {
+	ID            uint           `gorm:"primaryKey"`
+	TransactionID uint           `gorm:"index"`
+	UserID        uint           `gorm:"index"`
+	Kind          AttachmentKind // Whether it was sent as a photo or a document
+	FileID        string         // Telegram file ID, used to send the file back
+	FileUniqueID  string         // Telegram ID that is stable across bots
+	FileName      string         // Only available for documents
+	MimeType      string         // Only available for documents
+	Timestamp     time.Time      `gorm:"autoCreateTime"`
+}
+
+type AttachmentKind string
+
+const (
+	AttachmentPhoto    AttachmentKind = "photo"
+	AttachmentDocument AttachmentKind = "document"
+)
+
+/*
+ * 							Group Model
+ *
+ * This model is used to store the group chats the bot is part of,
+ * each of them holding a shared ledger between its members.
+ *
+ */
+type Group struct {
+	ID     uint  `gorm:"primaryKey"`
+	ChatID int64 `gorm:"uniqueIndex"` // Telegram group chat ID
+	Title  string
+}
+
+/*
+ * 							GroupMember Model
+ *
+ * This model is used to store the users known to take part in a group,
+ * recorded the first time they talk to the bot in that group.
+ *
+ */
+type GroupMember struct {
+	ID      uint `gorm:"primaryKey"`
+	GroupID uint `gorm:"uniqueIndex:idx_group_member"`
+	UserID  uint `gorm:"uniqueIndex:idx_group_member"`
+	User    User `gorm:"constraint:OnDelete:CASCADE"`
+}
+
+/*
+ * 							Split Model
+ *
+ * This model is used to store the share each member owes of a group
+ * expense. The payer's own share is stored too, so shares always add
+ * up to the transaction amount.
+ *
+ */
+type Split struct {
+	ID            uint  `gorm:"primaryKey"`
+	TransactionID uint  `gorm:"index"`
+	UserID        uint  `gorm:"index"`
+	User          User  `gorm:"constraint:OnDelete:CASCADE"`
+	Amount        int64 // In minor units of the transaction's currency
+}
+
+/*
+ * 							Settlement Model
+ *
+ * This model is used to store payments made between group members
+ * to clear their debts.
+ *
+ */
+type Settlement struct {
+	ID         uint  `gorm:"primaryKey"`
+	GroupID    uint  `gorm:"index"`
+	FromUserID uint  `gorm:"index"`
+	ToUserID   uint  `gorm:"index"`
+	Amount     int64 // In minor units of the currency
+	Currency   string
+	Timestamp  time.Time `gorm:"autoCreateTime"`
+}
+
+/*
+ * 							Debt Model
+ *
+ * This model is used to store money lent to or borrowed from people,
+ * who don't need to be users of the bot.
+ *
+ */
+type Debt struct {
+	ID           uint     `gorm:"primaryKey"`
+	UserID       uint     `gorm:"index"`
+	User         User     `gorm:"constraint:OnDelete:CASCADE"`
+	Counterparty string   `gorm:"index"` // Lowercased name of the other person
+	Kind         DebtKind // Whether the money was lent or borrowed
+	Amount       int64    // Original amount in minor units, always positive
+	Currency     string   `gorm:"default:'NZD'"` // ISO 4217 currency code
+	Notes        string
+	Timestamp    time.Time       `gorm:"autoCreateTime"`
+	Repayments   []DebtRepayment `gorm:"foreignKey:DebtID"` // One-to-Many Relationship
+}
+
+type DebtKind string
+
+const (
+	DebtLent     DebtKind = "lent"
+	DebtBorrowed DebtKind = "borrowed"
+)
+
+/*
+ * 							DebtRepayment Model
+ *
+ * This model is used to store partial or full repayments of a debt,
+ * optionally linked to the transaction recording the money movement.
+ *
+ */
+type DebtRepayment struct {
+	ID            uint      `gorm:"primaryKey"`
+	DebtID        uint      `gorm:"index"`
+	Amount        int64     // In minor units of the debt's currency
+	TransactionID *uint     `gorm:"index"` // Linked transaction, if any
+	Timestamp     time.Time `gorm:"autoCreateTime"`
+}
+
+/*
+ * 							Account Model
+ *
+ * This model is used to store the accounts (wallets, cards, savings...)
+ * money moves through, each of them holding a single currency.
+ *
+ */
+type Account struct {
+	ID             uint        `gorm:"primaryKey"`
+	UserID         uint        `gorm:"uniqueIndex:idx_user_account"`
+	User           User        `gorm:"constraint:OnDelete:CASCADE"`
+	Name           string      `gorm:"uniqueIndex:idx_user_account"` // Lowercased, referenced as @name
+	Type           AccountType // Kind of account
+	OpeningBalance int64       // Balance before any recorded transaction, in minor units
+	Currency       string      `gorm:"default:'NZD'"` // ISO 4217 currency code
+	CreatedAt      time.Time
+}
+
+type AccountType string
+
+const (
+	AccountCash    AccountType = "cash"
+	AccountDebit   AccountType = "debit"
+	AccountCredit  AccountType = "credit"
+	AccountSavings AccountType = "savings"
+)
+
+/*
+ * 							Transfer Model
+ *
+ * This model is used to store money moved between two accounts of the
+ * same user, which is neither income nor spending.
+ *
+ */
+type Transfer struct {
+	ID            uint  `gorm:"primaryKey"`
+	UserID        uint  `gorm:"index"`
+	FromAccountID uint  `gorm:"index"`
+	ToAccountID   uint  `gorm:"index"`
+	Amount        int64 // In minor units of the currency
+	Currency      string
+	Notes         string
+	Timestamp     time.Time `gorm:"autoCreateTime"`
 }
 
 /*
@@ -47,3 +239,194 @@ type Offset struct {
 	ID     uint `gorm:"primaryKey"`
 	Offset int
 }
+
+/*
+ * 							InboxUpdate Model
+ *
+ * This model is used to store every incoming Telegram update before
+ * it's handled, so none is lost if the bot stops halfway through.
+ * Updates are marked as done in the same database transaction as
+ * their effects, failed ones are retried until they're dead.
+ *
+ */
+type InboxUpdate struct {
+	UpdateID      int         `gorm:"primaryKey;autoIncrement:false"` // Telegram update ID
+	Payload       string      // JSON of the Telegram update
+	ChatID        int64       `gorm:"index"` // Updates of a chat are handled in order
+	Status        InboxStatus `gorm:"index"`
+	Attempts      int         // Number of failed attempts so far
+	LastError     string
+	NextAttemptAt time.Time `gorm:"index"` // When a pending update can be tried (again)
+	ReceivedAt    time.Time `gorm:"autoCreateTime"`
+	ProcessedAt   *time.Time
+}
+
+type InboxStatus string
+
+const (
+	InboxPending InboxStatus = "pending"
+	InboxDone    InboxStatus = "done"
+	InboxDead    InboxStatus = "dead" // Gave up after too many failed attempts
+)
+
+/*
+ * 							Invite Model
+ *
+ * This model is used to store the invite codes admins hand out, which
+ * let new users in when the bot is invite-only. Each can be used once.
+ *
+ */
+type Invite struct {
+	ID          uint   `gorm:"primaryKey"`
+	Code        string `gorm:"uniqueIndex"`
+	CreatedByID uint   `gorm:"index"` // Admin who generated the code
+	UsedByID    *uint  `gorm:"index"` // User who joined with it, nil while unused
+	UsedAt      *time.Time
+	ExpiresAt   time.Time
+	CreatedAt   time.Time `gorm:"autoCreateTime"`
+}
+
+/*
+ * 							Custom Currency Model
+ *
+ * This model is used to store the currencies users define besides the
+ * ISO 4217 ones (e.g. BTC or airline miles). Every user can use them.
+ *
+ */
+type CustomCurrency struct {
+	ID          uint   `gorm:"primaryKey"`
+	Code        string `gorm:"uniqueIndex"`
+	Name        string
+	Decimals    int       // Digits of the minor unit (e.g. 8 for BTC, 0 for miles)
+	CreatedByID uint      `gorm:"index"` // User who defined it
+	CreatedAt   time.Time `gorm:"autoCreateTime"`
+}
+
+/*
+ * 							Goal Model
+ *
+ * This model is used to store what users are saving towards, savings
+ * transactions marked with the goal count towards its target.
+ *
+ */
+type Goal struct {
+	ID        uint       `gorm:"primaryKey"`
+	UserID    uint       `gorm:"uniqueIndex:idx_user_goal"`
+	Name      string     `gorm:"uniqueIndex:idx_user_goal"` // Lowercased, referenced as #name
+	Target    int64      // In minor units of the currency
+	Currency  string     // ISO 4217 currency code
+	Deadline  *time.Time // When the target should be reached, if ever
+	CreatedAt time.Time
+}
+
+/*
+ * 							Bill Model
+ *
+ * This model is used to store the bills users pay every month, to remind
+ * them before each is due and record it once paid.
+ *
+ */
+type Bill struct {
+	ID         uint       `gorm:"primaryKey"`
+	UserID     uint       `gorm:"uniqueIndex:idx_user_bill"`
+	Name       string     `gorm:"uniqueIndex:idx_user_bill"` // Lowercased
+	Amount     int64      // In minor units of the currency
+	Currency   string     // ISO 4217 currency code
+	Category   string     // What the payment is recorded as: Utilities, Rent or Subscriptions
+	DueDay     int        // Day of the month it's due, the last one in shorter months
+	NextDue    time.Time  `gorm:"index"` // Next day it's due, as a date at midnight UTC
+	RemindDays int        // How many days before it's due the user is reminded
+	RemindedAt *time.Time // When the user was reminded of the next due date, nil if not yet
+	CreatedAt  time.Time
+}
+
+/*
+ * 							Trade Model
+ *
+ * This model is used to store the investments bought and sold (shares,
+ * funds, crypto...), which make up the holdings and their cost basis.
+ *
+ */
+type Trade struct {
+	ID            uint      `gorm:"primaryKey"`
+	UserID        uint      `gorm:"index:idx_user_instrument"`
+	Instrument    string    `gorm:"index:idx_user_instrument"` // Uppercased ticker or name (e.g. AAPL)
+	Kind          TradeKind // Whether units were bought or sold
+	Units         int64     // In hundred-millionths of a unit, to allow fractional shares
+	Amount        int64     // Total paid or received, in minor units of the currency
+	Currency      string
+	TransactionID *uint // Investment transaction recorded alongside
+	Timestamp     time.Time
+}
+
+type TradeKind string
+
+const (
+	TradeBuy  TradeKind = "buy"
+	TradeSell TradeKind = "sell"
+)
+
+/*
+ * 							InstrumentPrice Model
+ *
+ * This model is used to store the latest price of an instrument as
+ * entered by a user, to value their holdings.
+ *
+ */
+type InstrumentPrice struct {
+	ID         uint   `gorm:"primaryKey"`
+	UserID     uint   `gorm:"uniqueIndex:idx_user_price"`
+	Instrument string `gorm:"uniqueIndex:idx_user_price"`
+	Price      string // Per unit, as an exact decimal (e.g. 185.2)
+	Currency   string
+	UpdatedAt  time.Time
+}
+
+/*
+ * 							SchemaMigration Model
+ *
+ * This model is used to store the versions of the schema migrations
+ * applied to the database, see migrate.go.
+ *
+ */
+type SchemaMigration struct {
+	Version   uint `gorm:"primaryKey;autoIncrement:false"`
+	Name      string
+	AppliedAt time.Time `gorm:"autoCreateTime"`
+}
+
+/*
+ * 							TransactionEvent Model
+ *
+ * This model is an append-only audit log of every change made to a
+ * transaction. Events are never updated nor deleted, and outlive the
+ * transaction they describe so deletions can still be traced.
+ *
+ */
+type TransactionEvent struct {
+	ID            uint        `gorm:"primaryKey"`
+	TransactionID uint        `gorm:"index"`
+	UserID        uint        `gorm:"index"`
+	Action        EventAction // What happened to the transaction
+	Source        EventSource // Where the change came from
+	OldValue      string      // JSON snapshot before the change, empty on create
+	NewValue      string      // JSON snapshot after the change, empty on delete
+	Timestamp     time.Time   `gorm:"autoCreateTime"`
+}
+
+type EventAction string
+
+const (
+	ActionCreate EventAction = "create"
+	ActionEdit   EventAction = "edit"
+	ActionDelete EventAction = "delete"
+)
+
+type EventSource string
+
+const (
+	SourceChat      EventSource = "chat"
+	SourceImport    EventSource = "import"
+	SourceAPI       EventSource = "api"
+	SourceScheduler EventSource = "scheduler"
+)
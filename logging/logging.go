@@ -0,0 +1,101 @@
+package logging
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"strings"
+	"time"
+
+	gormLogger "gorm.io/gorm/logger"
+)
+
+/**
+ * Structured logging, shared by every package through slog's default logger.
+ *
+ * Messages carry financial data, so when redacting (the default) the values
+ * of sensitive attributes are masked whatever logs them: message bodies,
+ * names, notes, amounts and raw Telegram traffic. Log them under these keys,
+ * never inside the message itself.
+ */
+
+var sensitiveKeys = map[string]bool{
+	"body":     true, // Text of a message
+	"name":     true, // Full name of a user
+	"username": true,
+	"notes":    true,
+	"amount":   true,
+	"traffic":  true, // Raw Telegram API requests and responses
+}
+
+const mask = "[redacted]"
+
+type Options struct {
+	Level  slog.Level `yaml:"level"`
+	Format string     `yaml:"format"` // text or json
+	Redact bool       `yaml:"redact"` // Mask the values of sensitive attributes.
+}
+
+var redact = true
+
+/**
+ * Make slog's default logger (and the standard logger, which goes through
+ * it) write with the given options.
+ */
+func Setup(opts Options) {
+	redact = opts.Redact
+	slog.SetDefault(slog.New(newHandler(os.Stderr, opts)))
+}
+
+func newHandler(w io.Writer, opts Options) slog.Handler {
+	handlerOpts := &slog.HandlerOptions{Level: opts.Level}
+	if opts.Redact {
+		handlerOpts.ReplaceAttr = maskSensitive
+	}
+
+	if opts.Format == "json" {
+		return slog.NewJSONHandler(w, handlerOpts)
+	}
+	return slog.NewTextHandler(w, handlerOpts)
+}
+
+func maskSensitive(groups []string, attr slog.Attr) slog.Attr {
+	if sensitiveKeys[attr.Key] && attr.Value.Kind() != slog.KindGroup {
+		return slog.String(attr.Key, mask)
+	}
+	return attr
+}
+
+/**
+ * Logger for the Telegram client: errors are warnings, debug output is raw
+ * API traffic.
+ */
+type TelegramLogger struct{}
+
+func (TelegramLogger) Println(v ...any) {
+	slog.Warn("Telegram client", "error", strings.TrimSpace(fmt.Sprintln(v...)))
+}
+
+func (TelegramLogger) Printf(format string, v ...any) {
+	slog.Debug("Telegram API", "traffic", strings.TrimSpace(fmt.Sprintf(format, v...)))
+}
+
+/**
+ * Logger for gorm: errors and slow queries only. Query parameters are left
+ * out when redacting, they hold the amounts and notes.
+ */
+func Gorm() gormLogger.Interface {
+	return gormLogger.New(gormWriter{}, gormLogger.Config{
+		SlowThreshold:             time.Second,
+		LogLevel:                  gormLogger.Warn,
+		IgnoreRecordNotFoundError: true,
+		ParameterizedQueries:      redact,
+	})
+}
+
+type gormWriter struct{}
+
+func (gormWriter) Printf(format string, v ...any) {
+	slog.Warn("Database", "detail", strings.TrimSpace(fmt.Sprintf(format, v...)))
+}
@@ -1,64 +1,282 @@
 package main
 
 import (
-	"log"
+	"context"
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/signal"
 	. "remind0/app"
 	DB "remind0/db"
+	"remind0/logging"
+	"remind0/monitoring"
 	r "remind0/repository"
+	"syscall"
+	"time"
+	_ "time/tzdata" // Embed timezones, the runtime image doesn't ship them.
 
 	telegramClient "github.com/go-telegram-bot-api/telegram-bot-api/v5"
+	"gorm.io/gorm"
 )
 
 func main() {
 
 	// Provision application env vars.
-	config, err := LoadConfig()
+	config, args, err := LoadConfig(os.Args[1:])
 	if err != nil {
-		log.Panicf("⚠️ Configuration loading error: %v", err)
+		fatal("Configuration loading error", err)
+	}
+
+	// Structured logs, without financial data unless asked to.
+	logging.Setup(config.Log)
+	slog.Info("Configuration loaded", "level", config.Log.Level, "redact", config.Log.Redact)
+
+	// Manage the schema by hand: remind0 [flags] migrate up|down|status
+	if len(args) > 0 && args[0] == "migrate" {
+		db, err := DB.ConnectDB(config.DatabaseDSN())
+		if err != nil {
+			fatal("Database connection error", err)
+		}
+		if err := migrate(db, args[1:]); err != nil {
+			fatal("Migration error", err)
+		}
+		return
 	}
 
 	// Initialize database connection and run migrations.
-	db, err := DB.InitialiseDB(config.TursoDSN + "?authToken=" + config.TursoAuthToken)
+	db, err := DB.InitialiseDB(config.DatabaseDSN())
 	if err != nil {
-		log.Panicf("⚠️ Database initialization error: %v", err)
+		fatal("Database initialization error", err)
+	}
+
+	// Decide who gets to use the bot.
+	ConfigureAccess(config)
+
+	// Value holdings with the shared prices file, if any.
+	ConfigurePrices(config)
+
+	// Health checks and metrics, if asked for.
+	var server *http.Server
+	if config.Monitoring.Addr != "" {
+		server = monitoring.Serve(config.Monitoring.Addr, db, config.Telegram.PollTimeout)
 	}
 
 	// Start-up all repositories, yeehaw!
 	r.InitRepositories(db)
 
+	// Make the currencies users defined available.
+	if err := LoadCustomCurrencies(r.CurrencyRepo()); err != nil {
+		fatal("Custom currencies loading error", err)
+	}
+
 	// Setup tg bot instance.
-	bot, err := telegramClient.NewBotAPI(config.TelegramToken)
+	telegramClient.SetLogger(logging.TelegramLogger{})
+	httpClient := &http.Client{Transport: monitoring.TelegramTransport(http.DefaultTransport)}
+	bot, err := telegramClient.NewBotAPIWithClient(config.Telegram.Token, telegramClient.APIEndpoint, httpClient)
 	if err != nil {
-		log.Panicf("⚠️ Telegram bot initialization error: %v", err)
+		fatal("Telegram bot initialization error", err)
 	}
 
-	// Well... what it says.
-	bot.Debug = true
+	// Dump the API traffic, at debug level.
+	bot.Debug = config.Telegram.Debug
 
 	// Initialise conversation's offset tracking.
 	o := r.OffsetRepo()
 	offset, _ := o.GetOrCreate()
 
-	// Start the bot and listen for updates indefinitely.
-	for {
-		updates := ConnectBot(bot, offset)
+	// Stop on docker stop (SIGTERM) or Ctrl+C (SIGINT), from here on gracefully.
+	ctx, stopSignals := signal.NotifyContext(context.Background(), syscall.SIGTERM, syscall.SIGINT)
+	defer stopSignals()
+
+	// Updates are handled concurrently, in order within each chat.
+	pool := NewUpdatePool(bot, updateWorkers, updateQueueSize)
+
+	// Handle whatever a previous run left in the inbox.
+	ProcessInbox(pool)
+
+	// Nudge users who haven't logged anything for a while.
+	reminders := StartReminders(bot)
+
+	monitoring.SetReady(true)
+
+	// Failed updates are retried even when no new ones come in.
+	retries := time.NewTicker(inboxRetryInterval)
+	defer retries.Stop()
+
+	// Listen for updates until asked to stop.
+	updates := ConnectBot(bot, offset, config.Telegram.PollTimeout)
+	for ctx.Err() == nil {
+		select {
+		case update, ok := <-updates:
+			if !ok {
+				slog.Warn("Update channel closed, reconnecting")
+				updates = ConnectBot(bot, offset, config.Telegram.PollTimeout)
+				continue
+			}
+
+			// Only store unhandled updates, the offset keeps track of them.
+			if update.UpdateID > offset.Offset && receive(ctx, offset, update) {
+				ProcessInbox(pool)
+			}
+
+		case <-retries.C:
+			ProcessInbox(pool)
+
+		case <-ctx.Done():
+		}
+	}
+
+	stopSignals() // A second signal kills the bot right away.
+	os.Exit(shutdown(bot, updates, offset, pool, reminders, server, db))
+}
+
+const (
+	inboxRetryInterval = 30 * time.Second
+	updateWorkers      = 8
+	updateQueueSize    = 16              // Per worker, beyond it reading updates waits.
+	shutdownTimeout    = 8 * time.Second // Docker kills the bot 10 seconds after asking it to stop.
+)
+
+/**
+ * Store an update in the inbox, insisting until it works or ctx ends: the
+ * update channel has already moved on, so it wouldn't be delivered again
+ * in this run. Returns whether it was stored.
+ */
+func receive(ctx context.Context, offset *DB.Offset, update telegramClient.Update) bool {
+	for delay := time.Second; ; delay = min(2*delay, time.Minute) {
+		err := ReceiveUpdate(offset, update)
+		if err == nil {
+			return true
+		}
+		slog.Error("Error storing update", "update_id", update.UpdateID, "retry_in", delay, "error", err)
+
+		select {
+		case <-time.After(delay):
+		case <-ctx.Done():
+			return false
+		}
+	}
+}
 
-		// Listen to new messages.
-		for update := range updates {
+/**
+ * Stop polling, store the updates already received so the offset moves past
+ * them, let the workers finish what they're on, stop broadcasts and nudging
+ * users and close the database, all within shutdownTimeout. Anything left
+ * over stays in the inbox or is delivered again by Telegram on the next
+ * start. Returns the exit status.
+ */
+func shutdown(bot *telegramClient.BotAPI, updates telegramClient.UpdatesChannel, offset *DB.Offset, pool *UpdatePool, reminders *Reminders, server *http.Server, db *gorm.DB) int {
+	slog.Info("Shutting down", "timeout", shutdownTimeout)
+	monitoring.SetReady(false)
 
-			// Only process unhandled messages.
-			if update.UpdateID > offset.Offset {
+	ctx, cancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer cancel()
 
-				// Update to keep track of the already processed transactions.
-				o.UpdateLastSeen(offset, update.UpdateID)
+	status := 0
 
-				// Handle the message if it's a valid update.
-				if update.Message != nil {
-					HandleTelegramMessage(bot, update)
-				}
+	// The poll in progress ends on its own, its updates aren't confirmed
+	// to Telegram until the next one so they aren't lost.
+	bot.StopReceivingUpdates()
+	stored := 0
+	for buffered := true; buffered; {
+		select {
+		case update, ok := <-updates:
+			if !ok {
+				buffered = false
+				continue
+			}
+			if update.UpdateID <= offset.Offset {
+				continue
 			}
+			if !receive(ctx, offset, update) {
+				slog.Error("Gave up storing the received updates", "offset", offset.Offset)
+				status, buffered = 1, false
+				continue
+			}
+			stored++
+		default:
+			buffered = false
+		}
+	}
+	slog.Info("Stored the received updates", "stored", stored, "offset", offset.Offset)
+
+	if err := pool.Shutdown(ctx); err != nil {
+		slog.Error("Error draining the workers", "error", err)
+		status = 1
+	}
+
+	// Broadcasts are only started by the workers, all done by now.
+	if err := ShutdownBroadcasts(ctx); err != nil {
+		slog.Error("Error stopping the broadcasts", "error", err)
+		status = 1
+	}
+
+	if err := reminders.Shutdown(ctx); err != nil {
+		slog.Error("Error stopping the reminders", "error", err)
+		status = 1
+	}
+
+	if server != nil {
+		if err := server.Shutdown(ctx); err != nil {
+			slog.Error("Error stopping the monitoring server", "error", err)
 		}
+	}
 
-		log.Println("⚠️ Channel closed. Reconnecting...")
+	if sqlDB, err := db.DB(); err == nil {
+		err = sqlDB.Close()
+		if err != nil {
+			slog.Error("Error closing the database", "error", err)
+			status = 1
+		}
 	}
+
+	slog.Info("Stopped", "status", status)
+	return status
+}
+
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
+}
+
+func migrate(db *gorm.DB, args []string) error {
+	if len(args) != 1 {
+		return fmt.Errorf("usage: remind0 migrate up|down|status")
+	}
+
+	switch args[0] {
+	case "up":
+		applied, err := DB.MigrateUp(db)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("Applied %d migration(s)\n", len(applied))
+	case "down":
+		rolledBack, err := DB.MigrateDown(db)
+		if err != nil {
+			return err
+		}
+		if rolledBack == nil {
+			fmt.Println("No migration to roll back")
+		} else {
+			fmt.Printf("Rolled back %d (%s)\n", rolledBack.Version, rolledBack.Name)
+		}
+	case "status":
+		status, err := DB.GetMigrationStatus(db)
+		if err != nil {
+			return err
+		}
+		for _, s := range status {
+			applied := "pending"
+			if s.AppliedAt != nil {
+				applied = "applied " + s.AppliedAt.Format("2006-01-02 15:04:05")
+			}
+			fmt.Printf("%4d  %-24s %s\n", s.Version, s.Name, applied)
+		}
+	default:
+		return fmt.Errorf("unknown migrate action %q, expected up, down or status", args[0])
+	}
+
+	return nil
 }
@@ -0,0 +1,84 @@
+package monitoring
+
+import (
+	"errors"
+	"runtime"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+/**
+ * Gorm plugin timing every query, labelled with the repository method that
+ * ran it (e.g. transaction, GetAll). Queries run from elsewhere, such as
+ * migrations, are labelled as other.
+ */
+type QueryTimer struct{}
+
+const (
+	startKey          = "monitoring:start"
+	repositoryPackage = "remind0/repository."
+)
+
+func (QueryTimer) Name() string {
+	return "monitoring:query_timer"
+}
+
+func (QueryTimer) Initialize(db *gorm.DB) error {
+	callbacks := db.Callback()
+	errs := []error{
+		callbacks.Create().Before("gorm:create").Register("monitoring:before_create", startTimer),
+		callbacks.Create().After("gorm:create").Register("monitoring:after_create", observeQuery),
+		callbacks.Query().Before("gorm:query").Register("monitoring:before_query", startTimer),
+		callbacks.Query().After("gorm:query").Register("monitoring:after_query", observeQuery),
+		callbacks.Update().Before("gorm:update").Register("monitoring:before_update", startTimer),
+		callbacks.Update().After("gorm:update").Register("monitoring:after_update", observeQuery),
+		callbacks.Delete().Before("gorm:delete").Register("monitoring:before_delete", startTimer),
+		callbacks.Delete().After("gorm:delete").Register("monitoring:after_delete", observeQuery),
+		callbacks.Row().Before("gorm:row").Register("monitoring:before_row", startTimer),
+		callbacks.Row().After("gorm:row").Register("monitoring:after_row", observeQuery),
+		callbacks.Raw().Before("gorm:raw").Register("monitoring:before_raw", startTimer),
+		callbacks.Raw().After("gorm:raw").Register("monitoring:after_raw", observeQuery),
+	}
+	return errors.Join(errs...)
+}
+
+func startTimer(db *gorm.DB) {
+	db.InstanceSet(startKey, time.Now())
+}
+
+func observeQuery(db *gorm.DB) {
+	start, ok := db.InstanceGet(startKey)
+	if !ok {
+		return
+	}
+	repository, method := repositoryMethod()
+	queryDuration.WithLabelValues(repository, method).Observe(time.Since(start.(time.Time)).Seconds())
+}
+
+/**
+ * Find the repository method up the call stack, e.g.
+ * remind0/repository.(*transactionRepository).GetAll gives transaction, GetAll.
+ */
+func repositoryMethod() (string, string) {
+	pcs := make([]uintptr, 32)
+	frames := runtime.CallersFrames(pcs[:runtime.Callers(3, pcs)])
+
+	for {
+		frame, more := frames.Next()
+		if name, ok := strings.CutPrefix(frame.Function, repositoryPackage); ok {
+			// Closures, e.g. of transactions, belong to the method they're in.
+			name, _, _ = strings.Cut(name, ".func")
+
+			receiver, method, found := strings.Cut(name, ").")
+			if found {
+				receiver = strings.TrimSuffix(strings.TrimPrefix(receiver, "(*"), "Repository")
+				return receiver, method
+			}
+		}
+		if !more {
+			return "other", "other"
+		}
+	}
+}
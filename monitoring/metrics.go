@@ -0,0 +1,56 @@
+package monitoring
+
+import (
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+)
+
+/**
+ * Prometheus metrics, exposed on /metrics when the monitoring server runs.
+ */
+
+var (
+	updatesReceived = promauto.NewCounter(prometheus.CounterOpts{
+		Name: "remind0_updates_received_total",
+		Help: "Telegram updates stored in the inbox.",
+	})
+
+	updatesHandled = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "remind0_updates_handled_total",
+		Help: "Attempts at handling stored updates, by outcome (done, failed or dead).",
+	}, []string{"outcome"})
+
+	commands = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "remind0_commands_total",
+		Help: "Commands handled, by command and outcome (success, user_error, or error when given up on).",
+	}, []string{"command", "outcome"})
+
+	queryDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Name:    "remind0_db_query_duration_seconds",
+		Help:    "Duration of the database queries, by repository method.",
+		Buckets: []float64{.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5},
+	}, []string{"repository", "method"})
+
+	telegramFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Name: "remind0_telegram_failures_total",
+		Help: "Failed Telegram API requests, by API method (e.g. sendMessage).",
+	}, []string{"method"})
+
+	lastPoll = promauto.NewGauge(prometheus.GaugeOpts{
+		Name: "remind0_telegram_last_poll_timestamp_seconds",
+		Help: "When updates were last successfully polled from Telegram.",
+	})
+)
+
+func UpdateReceived() {
+	updatesReceived.Inc()
+}
+
+// Outcome of an attempt at handling a stored update.
+func UpdateHandled(outcome string) {
+	updatesHandled.WithLabelValues(outcome).Inc()
+}
+
+func CommandHandled(command string, outcome string) {
+	commands.WithLabelValues(command, outcome).Inc()
+}
@@ -0,0 +1,113 @@
+package monitoring
+
+import (
+	"context"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"sync/atomic"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"gorm.io/gorm"
+)
+
+/**
+ * Optional HTTP server for the orchestrator and Prometheus:
+ *
+ *	/healthz: the database answers and updates were polled recently
+ *	/readyz: start-up is over and the bot is handling updates
+ *	/metrics: Prometheus metrics
+ */
+
+const (
+	pingTimeout = 2 * time.Second
+	// Long polls return at least every poll timeout, a few missed ones mean trouble.
+	missedPolls = 3
+)
+
+var ready atomic.Bool
+var readySince atomic.Int64
+
+// Whether the bot is handling updates.
+func SetReady(isReady bool) {
+	if isReady && !ready.Load() {
+		readySince.Store(time.Now().Unix())
+	}
+	ready.Store(isReady)
+}
+
+/**
+ * Serve the endpoints on addr (e.g. :9090) in the background.
+ */
+func Serve(addr string, db *gorm.DB, pollTimeout time.Duration) *http.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, req *http.Request) {
+		healthz(w, req, db, missedPolls*pollTimeout)
+	})
+	mux.HandleFunc("/readyz", readyz)
+	mux.Handle("/metrics", promhttp.Handler())
+
+	server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 5 * time.Second}
+	go func() {
+		slog.Info("Monitoring server listening", "addr", addr)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			slog.Error("Monitoring server stopped", "error", err)
+		}
+	}()
+	return server
+}
+
+type healthStatus struct {
+	Database string    `json:"database"`
+	LastPoll time.Time `json:"last_poll"`
+	Polling  string    `json:"polling"`
+}
+
+func healthz(w http.ResponseWriter, req *http.Request, db *gorm.DB, maxPollAge time.Duration) {
+	status := healthStatus{Database: "ok", Polling: "ok", LastPoll: LastPoll()}
+	healthy := true
+
+	ctx, cancel := context.WithTimeout(req.Context(), pingTimeout)
+	defer cancel()
+	if err := ping(ctx, db); err != nil {
+		status.Database, healthy = err.Error(), false
+	}
+
+	// Updates may not have been polled yet right after start-up.
+	polledSince := status.LastPoll
+	if polledSince.IsZero() {
+		polledSince = time.Unix(readySince.Load(), 0)
+	}
+	if ready.Load() && time.Since(polledSince) > maxPollAge {
+		status.Polling, healthy = "stale", false
+	}
+
+	code := http.StatusOK
+	if !healthy {
+		code = http.StatusServiceUnavailable
+	}
+	writeJSON(w, code, status)
+}
+
+func ping(ctx context.Context, db *gorm.DB) error {
+	sqlDB, err := db.DB()
+	if err != nil {
+		return err
+	}
+	return sqlDB.PingContext(ctx)
+}
+
+func readyz(w http.ResponseWriter, req *http.Request) {
+	if !ready.Load() {
+		writeJSON(w, http.StatusServiceUnavailable, map[string]bool{"ready": false})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]bool{"ready": true})
+}
+
+func writeJSON(w http.ResponseWriter, code int, body any) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(code)
+	json.NewEncoder(w).Encode(body)
+}
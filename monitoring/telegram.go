@@ -0,0 +1,47 @@
+package monitoring
+
+import (
+	"net/http"
+	"path"
+	"sync/atomic"
+	"time"
+)
+
+/**
+ * HTTP transport of the Telegram client, keeping track of failed requests
+ * and of when updates were last polled.
+ */
+type telegramTransport struct {
+	next http.RoundTripper
+}
+
+func TelegramTransport(next http.RoundTripper) http.RoundTripper {
+	return &telegramTransport{next: next}
+}
+
+var lastPollUnix atomic.Int64
+
+func (t *telegramTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	method := path.Base(req.URL.Path) // e.g. /bot<token>/sendMessage
+
+	resp, err := t.next.RoundTrip(req)
+	if err != nil || resp.StatusCode >= 400 {
+		telegramFailures.WithLabelValues(method).Inc()
+		return resp, err
+	}
+
+	if method == "getUpdates" {
+		now := time.Now()
+		lastPollUnix.Store(now.Unix())
+		lastPoll.Set(float64(now.Unix()))
+	}
+	return resp, nil
+}
+
+// When updates were last polled, zero if they never were.
+func LastPoll() time.Time {
+	if unix := lastPollUnix.Load(); unix != 0 {
+		return time.Unix(unix, 0)
+	}
+	return time.Time{}
+}
@@ -0,0 +1,119 @@
+package repository
+
+import (
+	"errors"
+	. "remind0/db"
+
+	"gorm.io/gorm"
+)
+
+var ErrAccountInUse = errors.New("account has transactions or transfers")
+
+type accountRepository struct {
+	dbClient *gorm.DB
+}
+
+type IAccountRepository interface {
+	Create(account *Account) error
+	// Delete an account, only if no transaction nor transfer refers to it.
+	Delete(account *Account) error
+
+	GetByName(userId uint, name string) (*Account, error)
+	GetAll(userId uint) ([]*Account, error)
+
+	CreateTransfer(transfer *Transfer) error
+
+	// Net movement of money per account: income minus spending, plus transfers in minus transfers out.
+	GetMovements(userId uint, incomeCategory string) (map[uint]int64, error)
+}
+
+// Factory method to initialise a repository.
+func AccountRepositoryImpl(dbClient *gorm.DB) IAccountRepository {
+	return &accountRepository{dbClient: dbClient}
+}
+
+func (r *accountRepository) Create(account *Account) error {
+	return r.dbClient.Create(account).Error
+}
+
+func (r *accountRepository) Delete(account *Account) error {
+	return r.dbClient.Transaction(func(db *gorm.DB) error {
+		var used int64
+		if err := db.Model(&Transaction{}).Where("account_id = ?", account.ID).Count(&used).Error; err != nil {
+			return err
+		}
+		if used == 0 {
+			if err := db.Model(&Transfer{}).Where("from_account_id = ? or to_account_id = ?", account.ID, account.ID).Count(&used).Error; err != nil {
+				return err
+			}
+		}
+		if used > 0 {
+			return ErrAccountInUse
+		}
+		return db.Delete(account).Error
+	})
+}
+
+func (r *accountRepository) GetByName(userId uint, name string) (*Account, error) {
+	var account Account
+	result := r.dbClient.Where("user_id = ? and name = ?", userId, name).First(&account)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &account, nil
+}
+
+func (r *accountRepository) GetAll(userId uint) ([]*Account, error) {
+	var accounts []*Account
+	result := r.dbClient.Where("user_id = ?", userId).Order("name ASC").Find(&accounts)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return accounts, nil
+}
+
+func (r *accountRepository) CreateTransfer(transfer *Transfer) error {
+	return r.dbClient.Create(transfer).Error
+}
+
+func (r *accountRepository) GetMovements(userId uint, incomeCategory string) (map[uint]int64, error) {
+	type movement struct {
+		AccountID uint
+		Total     int64
+	}
+
+	var txs, in, out []movement
+
+	result := r.dbClient.Model(&Transaction{}).
+		Select("account_id, SUM(CASE WHEN category = ? THEN amount ELSE -amount END) AS total", incomeCategory).
+		Where("user_id = ? and account_id IS NOT NULL", userId).
+		Group("account_id").
+		Scan(&txs)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	result = r.dbClient.Model(&Transfer{}).
+		Select("to_account_id AS account_id, SUM(amount) AS total").
+		Where("user_id = ?", userId).
+		Group("to_account_id").
+		Scan(&in)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	result = r.dbClient.Model(&Transfer{}).
+		Select("from_account_id AS account_id, -SUM(amount) AS total").
+		Where("user_id = ?", userId).
+		Group("from_account_id").
+		Scan(&out)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	movements := map[uint]int64{}
+	for _, m := range append(append(txs, in...), out...) {
+		movements[m.AccountID] += m.Total
+	}
+	return movements, nil
+}
@@ -0,0 +1,37 @@
+package repository
+
+import (
+	. "remind0/db"
+
+	"gorm.io/gorm"
+)
+
+type attachmentRepository struct {
+	dbClient *gorm.DB
+}
+
+type IAttachmentRepository interface {
+	Create(attachments []*Attachment) error
+	GetByTransaction(txId uint, userId uint) ([]*Attachment, error)
+}
+
+// Factory method to initialise a repository.
+func AttachmentRepositoryImpl(dbClient *gorm.DB) IAttachmentRepository {
+	return &attachmentRepository{dbClient: dbClient}
+}
+
+func (r *attachmentRepository) Create(attachments []*Attachment) error {
+	return r.dbClient.Create(&attachments).Error
+}
+
+func (r *attachmentRepository) GetByTransaction(txId uint, userId uint) ([]*Attachment, error) {
+	var attachments []*Attachment
+	result := r.dbClient.
+		Where("transaction_id = ? and user_id = ?", txId, userId).
+		Order("id ASC").
+		Find(&attachments)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return attachments, nil
+}
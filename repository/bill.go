@@ -0,0 +1,92 @@
+package repository
+
+import (
+	. "remind0/db"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type billRepository struct {
+	dbClient *gorm.DB
+}
+
+type IBillRepository interface {
+	Create(bill *Bill) error
+	Update(bill *Bill) error
+	Delete(bill *Bill) error
+
+	GetByID(id uint, userId uint) (*Bill, error)
+	GetByName(userId uint, name string) (*Bill, error)
+	// Every bill of the user, soonest due first.
+	GetAll(userId uint) ([]*Bill, error)
+
+	// Bills of every user due by a date and not reminded of it yet, soonest due first.
+	GetUnreminded(dueBy time.Time) ([]*Bill, error)
+	// Record when the user was reminded of a bill due on a date, leaving the rest
+	// of it untouched. Returns false when it was paid, skipped or reminded of since.
+	SetRemindedAt(id uint, due time.Time, at time.Time) (bool, error)
+}
+
+// Factory method to initialise a repository.
+func BillRepositoryImpl(dbClient *gorm.DB) IBillRepository {
+	return &billRepository{dbClient: dbClient}
+}
+
+func (r *billRepository) Create(bill *Bill) error {
+	return r.dbClient.Create(bill).Error
+}
+
+func (r *billRepository) Update(bill *Bill) error {
+	return r.dbClient.Save(bill).Error
+}
+
+func (r *billRepository) Delete(bill *Bill) error {
+	return r.dbClient.Delete(bill).Error
+}
+
+func (r *billRepository) GetByID(id uint, userId uint) (*Bill, error) {
+	var bill Bill
+	result := r.dbClient.Where("id = ? and user_id = ?", id, userId).First(&bill)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &bill, nil
+}
+
+func (r *billRepository) GetByName(userId uint, name string) (*Bill, error) {
+	var bill Bill
+	result := r.dbClient.Where("user_id = ? and name = ?", userId, name).First(&bill)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &bill, nil
+}
+
+func (r *billRepository) GetAll(userId uint) ([]*Bill, error) {
+	var bills []*Bill
+	result := r.dbClient.Where("user_id = ?", userId).Order("next_due ASC, name ASC").Find(&bills)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return bills, nil
+}
+
+func (r *billRepository) GetUnreminded(dueBy time.Time) ([]*Bill, error) {
+	var bills []*Bill
+	result := r.dbClient.
+		Where("reminded_at IS NULL AND next_due <= ?", dueBy).
+		Order("next_due ASC, id ASC").
+		Find(&bills)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return bills, nil
+}
+
+func (r *billRepository) SetRemindedAt(id uint, due time.Time, at time.Time) (bool, error) {
+	result := r.dbClient.Model(&Bill{}).
+		Where("id = ? AND next_due = ? AND reminded_at IS NULL", id, due).
+		Update("reminded_at", at)
+	return result.RowsAffected > 0, result.Error
+}
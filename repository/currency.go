@@ -0,0 +1,74 @@
+package repository
+
+import (
+	"errors"
+	. "remind0/db"
+
+	"gorm.io/gorm"
+)
+
+var ErrCurrencyInUse = errors.New("currency is used by a record or as a preferred currency")
+
+type currencyRepository struct {
+	dbClient *gorm.DB
+}
+
+type ICurrencyRepository interface {
+	Create(currency *CustomCurrency) error
+	// Delete a custom currency, only if nothing refers to it.
+	Delete(currency *CustomCurrency) error
+
+	GetByCode(code string) (*CustomCurrency, error)
+	GetAll() ([]*CustomCurrency, error)
+}
+
+// Factory method to initialise a repository.
+func CurrencyRepositoryImpl(dbClient *gorm.DB) ICurrencyRepository {
+	return &currencyRepository{dbClient: dbClient}
+}
+
+func (r *currencyRepository) Create(currency *CustomCurrency) error {
+	return r.dbClient.Create(currency).Error
+}
+
+func (r *currencyRepository) Delete(currency *CustomCurrency) error {
+	return r.dbClient.Transaction(func(db *gorm.DB) error {
+		for _, model := range []any{&Transaction{}, &Debt{}, &Settlement{}, &Account{}, &Transfer{}, &Trade{}, &InstrumentPrice{}, &Goal{}, &Bill{}} {
+			var used int64
+			if err := db.Model(model).Where("currency = ?", currency.Code).Count(&used).Error; err != nil {
+				return err
+			}
+			if used > 0 {
+				return ErrCurrencyInUse
+			}
+		}
+
+		var preferred int64
+		if err := db.Model(&User{}).Where("preferred_currency = ?", currency.Code).Count(&preferred).Error; err != nil {
+			return err
+		}
+		if preferred > 0 {
+			return ErrCurrencyInUse
+		}
+
+		return db.Delete(currency).Error
+	})
+}
+
+func (r *currencyRepository) GetByCode(code string) (*CustomCurrency, error) {
+	var currency CustomCurrency
+	result := r.dbClient.Where("code = ?", code).First(&currency)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &currency, nil
+}
+
+func (r *currencyRepository) GetAll() ([]*CustomCurrency, error) {
+	var currencies []*CustomCurrency
+	result := r.dbClient.Order("code ASC").Find(&currencies)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return currencies, nil
+}
@@ -0,0 +1,73 @@
+package repository
+
+import (
+	. "remind0/db"
+
+	"gorm.io/gorm"
+)
+
+type debtRepository struct {
+	dbClient *gorm.DB
+}
+
+type IDebtRepository interface {
+	Create(debt *Debt) error
+	// Record repayments, creating the linked transaction first if one is given.
+	Repay(repayments []*DebtRepayment, transaction *Transaction, source EventSource) error
+
+	// Get every debt of a user along with its repayments, oldest first.
+	GetAll(userId uint) ([]*Debt, error)
+	GetManyByCounterparty(userId uint, counterparty string, currency string) ([]*Debt, error)
+}
+
+// Factory method to initialise a repository.
+func DebtRepositoryImpl(dbClient *gorm.DB) IDebtRepository {
+	return &debtRepository{dbClient: dbClient}
+}
+
+func (r *debtRepository) Create(debt *Debt) error {
+	return r.dbClient.Create(debt).Error
+}
+
+func (r *debtRepository) Repay(repayments []*DebtRepayment, tx *Transaction, source EventSource) error {
+	return r.dbClient.Transaction(func(db *gorm.DB) error {
+		if tx != nil {
+			if err := db.Create(tx).Error; err != nil {
+				return err
+			}
+			if err := recordEvents(db, ActionCreate, source, nil, []*Transaction{tx}); err != nil {
+				return err
+			}
+			for _, repayment := range repayments {
+				repayment.TransactionID = &tx.ID
+			}
+		}
+		return db.Create(&repayments).Error
+	})
+}
+
+func (r *debtRepository) GetAll(userId uint) ([]*Debt, error) {
+	var debts []*Debt
+	result := r.dbClient.
+		Preload("Repayments").
+		Where("user_id = ?", userId).
+		Order("timestamp ASC, id ASC").
+		Find(&debts)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return debts, nil
+}
+
+func (r *debtRepository) GetManyByCounterparty(userId uint, counterparty string, currency string) ([]*Debt, error) {
+	var debts []*Debt
+	result := r.dbClient.
+		Preload("Repayments").
+		Where("user_id = ? and counterparty = ? and currency = ?", userId, counterparty, currency).
+		Order("timestamp ASC, id ASC").
+		Find(&debts)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return debts, nil
+}
@@ -0,0 +1,110 @@
+package repository
+
+import (
+	"encoding/json"
+	. "remind0/db"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+type eventRepository struct {
+	dbClient *gorm.DB
+}
+
+type IEventRepository interface {
+	// Get the full timeline of a transaction, oldest event first.
+	GetByTransaction(txId int64, userId uint) ([]*TransactionEvent, error)
+	// When a transaction of the user was last recorded, whatever its date; nil if never.
+	LastCreatedAt(userId uint) (*time.Time, error)
+}
+
+// Factory method to initialise a repository.
+func EventRepositoryImpl(dbClient *gorm.DB) IEventRepository {
+	return &eventRepository{dbClient: dbClient}
+}
+
+func (r *eventRepository) GetByTransaction(txId int64, userId uint) ([]*TransactionEvent, error) {
+	var events []*TransactionEvent
+	result := r.dbClient.
+		Where("transaction_id = ? and user_id = ?", txId, userId).
+		Order("timestamp ASC, id ASC").
+		Find(&events)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return events, nil
+}
+
+func (r *eventRepository) LastCreatedAt(userId uint) (*time.Time, error) {
+	var events []*TransactionEvent
+	result := r.dbClient.
+		Where("user_id = ? and action = ?", userId, ActionCreate).
+		Order("timestamp DESC").
+		Limit(1).
+		Find(&events)
+	if result.Error != nil || len(events) == 0 {
+		return nil, result.Error
+	}
+	return &events[0].Timestamp, nil
+}
+
+/**
+ * Point-in-time copy of the user-facing fields of a transaction,
+ * stored as JSON in the old/new values of an event.
+ */
+type TxSnapshot struct {
+	Category  string    `json:"category"`
+	Amount    int64     `json:"amount"` // In minor units of the currency
+	Currency  string    `json:"currency"`
+	Notes     string    `json:"notes"`
+	Timestamp time.Time `json:"timestamp"`
+	GoalID    *uint     `json:"goal_id,omitempty"` // Savings goal it counts towards, if any
+}
+
+func snapshot(tx *Transaction) string {
+	b, _ := json.Marshal(TxSnapshot{
+		Category:  tx.Category,
+		Amount:    tx.Amount,
+		Currency:  tx.Currency,
+		Notes:     tx.Notes,
+		Timestamp: tx.Timestamp,
+		GoalID:    tx.GoalID,
+	})
+	return string(b)
+}
+
+// Decode a snapshot previously stored in an event. Empty values decode to nil.
+func ParseSnapshot(value string) (*TxSnapshot, error) {
+	if value == "" {
+		return nil, nil
+	}
+	var s TxSnapshot
+	if err := json.Unmarshal([]byte(value), &s); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// Append one event per transaction using the given (transactional) client.
+func recordEvents(db *gorm.DB, action EventAction, source EventSource, before []*Transaction, after []*Transaction) error {
+	events := make([]*TransactionEvent, 0, max(len(before), len(after)))
+
+	for i := range max(len(before), len(after)) {
+		event := &TransactionEvent{Action: action, Source: source}
+		if i < len(before) {
+			event.TransactionID, event.UserID = before[i].ID, before[i].UserID
+			event.OldValue = snapshot(before[i])
+		}
+		if i < len(after) {
+			event.TransactionID, event.UserID = after[i].ID, after[i].UserID
+			event.NewValue = snapshot(after[i])
+		}
+		events = append(events, event)
+	}
+
+	if len(events) == 0 {
+		return nil
+	}
+	return db.Create(&events).Error
+}
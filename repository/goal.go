@@ -0,0 +1,88 @@
+package repository
+
+import (
+	. "remind0/db"
+
+	"gorm.io/gorm"
+)
+
+type goalRepository struct {
+	dbClient *gorm.DB
+}
+
+type IGoalRepository interface {
+	Create(goal *Goal) error
+	// Delete a goal, the transactions counting towards it are kept as plain savings.
+	Delete(goal *Goal, source EventSource) error
+
+	GetByName(userId uint, name string) (*Goal, error)
+	GetAll(userId uint) ([]*Goal, error)
+
+	// Transactions counting towards any of the user's goals, oldest first.
+	GetContributions(userId uint) ([]*Transaction, error)
+}
+
+// Factory method to initialise a repository.
+func GoalRepositoryImpl(dbClient *gorm.DB) IGoalRepository {
+	return &goalRepository{dbClient: dbClient}
+}
+
+func (r *goalRepository) Create(goal *Goal) error {
+	return r.dbClient.Create(goal).Error
+}
+
+func (r *goalRepository) Delete(goal *Goal, source EventSource) error {
+	return r.dbClient.Transaction(func(db *gorm.DB) error {
+		var before []*Transaction
+		if err := db.Where("goal_id = ?", goal.ID).Find(&before).Error; err != nil {
+			return err
+		}
+
+		if len(before) > 0 {
+			if err := db.Model(&Transaction{}).Where("goal_id = ?", goal.ID).Update("goal_id", nil).Error; err != nil {
+				return err
+			}
+			after := make([]*Transaction, len(before))
+			for i, tx := range before {
+				plain := *tx
+				plain.GoalID = nil
+				after[i] = &plain
+			}
+			if err := recordEvents(db, ActionEdit, source, before, after); err != nil {
+				return err
+			}
+		}
+
+		return db.Delete(goal).Error
+	})
+}
+
+func (r *goalRepository) GetByName(userId uint, name string) (*Goal, error) {
+	var goal Goal
+	result := r.dbClient.Where("user_id = ? and name = ?", userId, name).First(&goal)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &goal, nil
+}
+
+func (r *goalRepository) GetAll(userId uint) ([]*Goal, error) {
+	var goals []*Goal
+	result := r.dbClient.Where("user_id = ?", userId).Order("name ASC").Find(&goals)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return goals, nil
+}
+
+func (r *goalRepository) GetContributions(userId uint) ([]*Transaction, error) {
+	var transactions []*Transaction
+	result := r.dbClient.
+		Where("user_id = ? and goal_id IS NOT NULL", userId).
+		Order("timestamp ASC, id ASC").
+		Find(&transactions)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return transactions, nil
+}
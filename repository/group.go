@@ -0,0 +1,86 @@
+package repository
+
+import (
+	. "remind0/db"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type groupRepository struct {
+	dbClient *gorm.DB
+}
+
+type IGroupRepository interface {
+	// Get the existing group or create a new one if it doesn't exist.
+	GetOrCreate(chatId int64, title string) (*Group, error)
+	// Register a user as a member of the group, ignoring existing members.
+	AddMember(groupId uint, userId uint) error
+	// Get all the users known to take part in the group.
+	GetMembers(groupId uint) ([]*User, error)
+
+	// Get every expense of the group that is split between members.
+	GetSplitTransactions(groupId uint) ([]*Transaction, error)
+
+	CreateSettlement(settlement *Settlement) error
+	GetSettlements(groupId uint) ([]*Settlement, error)
+}
+
+// Factory method to initialise a repository.
+func GroupRepositoryImpl(dbClient *gorm.DB) IGroupRepository {
+	return &groupRepository{dbClient: dbClient}
+}
+
+func (r *groupRepository) GetOrCreate(chatId int64, title string) (*Group, error) {
+	group := Group{ChatID: chatId, Title: title}
+	result := r.dbClient.Where(Group{ChatID: chatId}).Attrs(Group{Title: title}).FirstOrCreate(&group)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &group, nil
+}
+
+func (r *groupRepository) AddMember(groupId uint, userId uint) error {
+	return r.dbClient.
+		Clauses(clause.OnConflict{DoNothing: true}).
+		Create(&GroupMember{GroupID: groupId, UserID: userId}).Error
+}
+
+func (r *groupRepository) GetMembers(groupId uint) ([]*User, error) {
+	var users []*User
+	result := r.dbClient.
+		Joins("JOIN group_members ON group_members.user_id = users.id").
+		Where("group_members.group_id = ?", groupId).
+		Order("users.id ASC").
+		Find(&users)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return users, nil
+}
+
+func (r *groupRepository) GetSplitTransactions(groupId uint) ([]*Transaction, error) {
+	var transactions []*Transaction
+	result := r.dbClient.
+		Preload("Splits").
+		Where("group_id = ? and id IN (?)", groupId, r.dbClient.Model(&Split{}).Select("transaction_id")).
+		Order("timestamp ASC, id ASC").
+		Find(&transactions)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return transactions, nil
+}
+
+func (r *groupRepository) CreateSettlement(settlement *Settlement) error {
+	return r.dbClient.Create(settlement).Error
+}
+
+func (r *groupRepository) GetSettlements(groupId uint) ([]*Settlement, error) {
+	var settlements []*Settlement
+	result := r.dbClient.Where("group_id = ?", groupId).Order("timestamp ASC, id ASC").Find(&settlements)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return settlements, nil
+}
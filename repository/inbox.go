@@ -0,0 +1,114 @@
+package repository
+
+import (
+	"errors"
+	. "remind0/db"
+	"time"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Another attempt at the update already finished with it.
+var ErrNotPending = errors.New("inbox update is no longer pending")
+
+type inboxRepository struct {
+	dbClient *gorm.DB
+}
+
+type IInboxRepository interface {
+	// Store an incoming update and move the offset past it, both or neither.
+	// Updates already stored are left untouched.
+	Receive(offset *Offset, update *InboxUpdate) error
+
+	// Pending updates due to be tried, oldest first. Updates waiting behind
+	// an earlier one of their chat, still to be retried, aren't due yet.
+	GetDue(now time.Time, limit int) ([]*InboxUpdate, error)
+
+	// Both fail with ErrNotPending when the update was already handled, e.g.
+	// by an attempt that was still in flight when it was read as due.
+	MarkDone(update *InboxUpdate) error
+	// Record a failed attempt, giving up on the update once it reaches maxAttempts.
+	MarkFailed(update *InboxUpdate, cause error, maxAttempts int, retryAt time.Time) error
+}
+
+// Factory method to initialise a repository.
+func InboxRepositoryImpl(dbClient *gorm.DB) IInboxRepository {
+	return &inboxRepository{dbClient: dbClient}
+}
+
+func (r *inboxRepository) Receive(offset *Offset, update *InboxUpdate) error {
+	err := r.dbClient.Transaction(func(db *gorm.DB) error {
+		if err := db.Clauses(clause.OnConflict{DoNothing: true}).Create(update).Error; err != nil {
+			return err
+		}
+		return db.Model(offset).Update("offset", update.UpdateID).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	offset.Offset = update.UpdateID
+	return nil
+}
+
+func (r *inboxRepository) GetDue(now time.Time, limit int) ([]*InboxUpdate, error) {
+	var updates []*InboxUpdate
+
+	result := r.dbClient.
+		Where("status = ? and next_attempt_at <= ?", InboxPending, now).
+		Where("NOT EXISTS (SELECT 1 FROM inbox_updates earlier WHERE earlier.chat_id = inbox_updates.chat_id AND earlier.update_id < inbox_updates.update_id AND earlier.status = ? AND earlier.next_attempt_at > ?)", InboxPending, now).
+		Order("update_id ASC").
+		Limit(limit).
+		Find(&updates)
+
+	if result.Error != nil {
+		return nil, result.Error
+	}
+
+	return updates, nil
+}
+
+func (r *inboxRepository) MarkDone(update *InboxUpdate) error {
+	now := time.Now()
+	result := r.dbClient.Model(update).
+		Where("status = ?", InboxPending).
+		Updates(map[string]any{"status": InboxDone, "processed_at": now})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotPending
+	}
+
+	update.Status, update.ProcessedAt = InboxDone, &now
+	return nil
+}
+
+func (r *inboxRepository) MarkFailed(update *InboxUpdate, cause error, maxAttempts int, retryAt time.Time) error {
+	status := InboxPending
+	if update.Attempts+1 >= maxAttempts {
+		status = InboxDead
+	}
+
+	result := r.dbClient.Model(update).
+		Where("status = ?", InboxPending).
+		Updates(map[string]any{
+			"status":          status,
+			"attempts":        update.Attempts + 1,
+			"last_error":      cause.Error(),
+			"next_attempt_at": retryAt,
+		})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrNotPending
+	}
+
+	update.Attempts++
+	update.LastError = cause.Error()
+	update.NextAttemptAt = retryAt
+	update.Status = status
+	return nil
+}
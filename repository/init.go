@@ -2,30 +2,164 @@ package repository
 
 import "gorm.io/gorm"
 
+/**
+ * Set of repositories sharing the same database handle: either the shared
+ * client, or a single transaction (see Atomically).
+ */
 type Repositories struct {
-	UserRepo        IUserRepository
-	OffsetRepo      IOffsetRepository
-	TransactionRepo ITransactionRepository
+	userRepo       IUserRepository
+	offsetRepo     IOffsetRepository
+	txRepo         ITransactionRepository
+	eventRepo      IEventRepository
+	groupRepo      IGroupRepository
+	debtRepo       IDebtRepository
+	accountRepo    IAccountRepository
+	attachmentRepo IAttachmentRepository
+	inboxRepo      IInboxRepository
+	inviteRepo     IInviteRepository
+	currencyRepo   ICurrencyRepository
+	investmentRepo IInvestmentRepository
+	goalRepo       IGoalRepository
+	billRepo       IBillRepository
 }
 
 var instance *Repositories
+var dbClient *gorm.DB
 
 func InitRepositories(db *gorm.DB) {
-	instance = &Repositories{
-		UserRepo:        UserRepositoryImpl(db),
-		OffsetRepo:      OffsetRepositoryImpl(db),
-		TransactionRepo: TransactionRepositoryImpl(db),
+	dbClient = db
+	instance = newRepositories(db)
+}
+
+func newRepositories(db *gorm.DB) *Repositories {
+	return &Repositories{
+		userRepo:       UserRepositoryImpl(db),
+		offsetRepo:     OffsetRepositoryImpl(db),
+		txRepo:         TransactionRepositoryImpl(db),
+		eventRepo:      EventRepositoryImpl(db),
+		groupRepo:      GroupRepositoryImpl(db),
+		debtRepo:       DebtRepositoryImpl(db),
+		accountRepo:    AccountRepositoryImpl(db),
+		attachmentRepo: AttachmentRepositoryImpl(db),
+		inboxRepo:      InboxRepositoryImpl(db),
+		inviteRepo:     InviteRepositoryImpl(db),
+		currencyRepo:   CurrencyRepositoryImpl(db),
+		investmentRepo: InvestmentRepositoryImpl(db),
+		goalRepo:       GoalRepositoryImpl(db),
+		billRepo:       BillRepositoryImpl(db),
 	}
 }
 
+/**
+ * Run fn with a set of repositories bound to a single database transaction,
+ * which is committed if fn succeeds and rolled back otherwise.
+ */
+func Atomically(fn func(repos *Repositories) error) error {
+	return dbClient.Transaction(func(tx *gorm.DB) error {
+		return fn(newRepositories(tx))
+	})
+}
+
+func (repos *Repositories) UserRepo() IUserRepository {
+	return repos.userRepo
+}
+
+func (repos *Repositories) OffsetRepo() IOffsetRepository {
+	return repos.offsetRepo
+}
+
+func (repos *Repositories) TxRepo() ITransactionRepository {
+	return repos.txRepo
+}
+
+func (repos *Repositories) EventRepo() IEventRepository {
+	return repos.eventRepo
+}
+
+func (repos *Repositories) GroupRepo() IGroupRepository {
+	return repos.groupRepo
+}
+
+func (repos *Repositories) DebtRepo() IDebtRepository {
+	return repos.debtRepo
+}
+
+func (repos *Repositories) AccountRepo() IAccountRepository {
+	return repos.accountRepo
+}
+
+func (repos *Repositories) AttachmentRepo() IAttachmentRepository {
+	return repos.attachmentRepo
+}
+
+func (repos *Repositories) InboxRepo() IInboxRepository {
+	return repos.inboxRepo
+}
+
+func (repos *Repositories) InviteRepo() IInviteRepository {
+	return repos.inviteRepo
+}
+
+func (repos *Repositories) CurrencyRepo() ICurrencyRepository {
+	return repos.currencyRepo
+}
+
+func (repos *Repositories) InvestmentRepo() IInvestmentRepository {
+	return repos.investmentRepo
+}
+
+func (repos *Repositories) GoalRepo() IGoalRepository {
+	return repos.goalRepo
+}
+
+func (repos *Repositories) BillRepo() IBillRepository {
+	return repos.billRepo
+}
+
 func UserRepo() IUserRepository {
-	return instance.UserRepo
+	return instance.UserRepo()
 }
 
 func OffsetRepo() IOffsetRepository {
-	return instance.OffsetRepo
+	return instance.OffsetRepo()
 }
 
 func TxRepo() ITransactionRepository {
-	return instance.TransactionRepo
+	return instance.TxRepo()
+}
+
+func EventRepo() IEventRepository {
+	return instance.EventRepo()
+}
+
+func GroupRepo() IGroupRepository {
+	return instance.GroupRepo()
+}
+
+func DebtRepo() IDebtRepository {
+	return instance.DebtRepo()
+}
+
+func AccountRepo() IAccountRepository {
+	return instance.AccountRepo()
+}
+
+func AttachmentRepo() IAttachmentRepository {
+	return instance.AttachmentRepo()
+}
+
+func InboxRepo() IInboxRepository {
+	return instance.InboxRepo()
+}
+
+func InviteRepo() IInviteRepository {
+	return instance.InviteRepo()
+}
+
+func CurrencyRepo() ICurrencyRepository {
+	return instance.CurrencyRepo()
+}
+
+func BillRepo() IBillRepository {
+	return instance.BillRepo()
 }
@@ -0,0 +1,99 @@
+package repository
+
+import (
+	. "remind0/db"
+
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+type investmentRepository struct {
+	dbClient *gorm.DB
+}
+
+type IInvestmentRepository interface {
+	// Record a trade, along with the investment transaction it comes with, if any.
+	RecordTrade(trade *Trade, tx *Transaction, source EventSource) error
+	// Trades of a user in the order they happened, of every instrument when none is given.
+	GetTrades(userId uint, instrument string) ([]*Trade, error)
+	GetTrade(id uint, userId uint) (*Trade, error)
+	// Trades recorded along with any of the transactions.
+	GetTradesOf(txIds []uint) ([]*Trade, error)
+	// Remove a trade, leaving the transaction recorded along with it to the caller.
+	DeleteTrade(trade *Trade) error
+
+	// Create or replace the price a user entered for an instrument.
+	SetPrice(price *InstrumentPrice) error
+	GetPrices(userId uint) ([]*InstrumentPrice, error)
+}
+
+// Factory method to initialise a repository.
+func InvestmentRepositoryImpl(dbClient *gorm.DB) IInvestmentRepository {
+	return &investmentRepository{dbClient: dbClient}
+}
+
+func (r *investmentRepository) RecordTrade(trade *Trade, tx *Transaction, source EventSource) error {
+	return r.dbClient.Transaction(func(db *gorm.DB) error {
+		if tx != nil {
+			if err := db.Create(tx).Error; err != nil {
+				return err
+			}
+			if err := recordEvents(db, ActionCreate, source, nil, []*Transaction{tx}); err != nil {
+				return err
+			}
+			trade.TransactionID = &tx.ID
+		}
+		return db.Create(trade).Error
+	})
+}
+
+func (r *investmentRepository) GetTrades(userId uint, instrument string) ([]*Trade, error) {
+	var trades []*Trade
+	query := r.dbClient.Where("user_id = ?", userId)
+	if instrument != "" {
+		query = query.Where("instrument = ?", instrument)
+	}
+	result := query.Order("timestamp ASC, id ASC").Find(&trades)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return trades, nil
+}
+
+func (r *investmentRepository) GetTrade(id uint, userId uint) (*Trade, error) {
+	var trade Trade
+	result := r.dbClient.Where("id = ? and user_id = ?", id, userId).First(&trade)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return &trade, nil
+}
+
+func (r *investmentRepository) GetTradesOf(txIds []uint) ([]*Trade, error) {
+	var trades []*Trade
+	result := r.dbClient.Where("transaction_id IN ?", txIds).Order("id ASC").Find(&trades)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return trades, nil
+}
+
+func (r *investmentRepository) DeleteTrade(trade *Trade) error {
+	return r.dbClient.Delete(trade).Error
+}
+
+func (r *investmentRepository) SetPrice(price *InstrumentPrice) error {
+	return r.dbClient.Clauses(clause.OnConflict{
+		Columns:   []clause.Column{{Name: "user_id"}, {Name: "instrument"}},
+		DoUpdates: clause.AssignmentColumns([]string{"price", "currency", "updated_at"}),
+	}).Create(price).Error
+}
+
+func (r *investmentRepository) GetPrices(userId uint) ([]*InstrumentPrice, error) {
+	var prices []*InstrumentPrice
+	result := r.dbClient.Where("user_id = ?", userId).Order("instrument ASC").Find(&prices)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	return prices, nil
+}
@@ -0,0 +1,61 @@
+package repository
+
+import (
+	"errors"
+	. "remind0/db"
+	"time"
+
+	"gorm.io/gorm"
+)
+
+var ErrInviteInvalid = errors.New("invite code is unknown, used or expired")
+
+type inviteRepository struct {
+	dbClient *gorm.DB
+}
+
+type IInviteRepository interface {
+	Create(invite *Invite) error
+	// Get an invite that can still be used.
+	GetUsable(code string, now time.Time) (*Invite, error)
+	// Use an invite up, unless someone else just did.
+	MarkUsed(invite *Invite, userId uint, now time.Time) error
+}
+
+// Factory method to initialise a repository.
+func InviteRepositoryImpl(dbClient *gorm.DB) IInviteRepository {
+	return &inviteRepository{dbClient: dbClient}
+}
+
+func (r *inviteRepository) Create(invite *Invite) error {
+	return r.dbClient.Create(invite).Error
+}
+
+func (r *inviteRepository) GetUsable(code string, now time.Time) (*Invite, error) {
+	var invite Invite
+	err := r.dbClient.
+		Where("code = ? and used_by_id IS NULL and expires_at > ?", code, now).
+		First(&invite).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, ErrInviteInvalid
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &invite, nil
+}
+
+func (r *inviteRepository) MarkUsed(invite *Invite, userId uint, now time.Time) error {
+	result := r.dbClient.Model(&Invite{}).
+		Where("id = ? and used_by_id IS NULL", invite.ID).
+		Updates(map[string]any{"used_by_id": userId, "used_at": now})
+	if result.Error != nil {
+		return result.Error
+	}
+	if result.RowsAffected == 0 {
+		return ErrInviteInvalid
+	}
+
+	invite.UsedByID, invite.UsedAt = &userId, &now
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"time"
 
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 )
 
 type transactionRepository struct {
@@ -12,8 +13,10 @@ type transactionRepository struct {
 }
 
 type ITransactionRepository interface {
-	Create(transaction []*Transaction) ([]*Transaction, error)
-	Delete(transaction []*Transaction) error
+	// Create, update and delete also append to the transaction's event log.
+	Create(transaction []*Transaction, source EventSource) ([]*Transaction, error)
+	Update(transaction *Transaction, source EventSource) error
+	Delete(transaction []*Transaction, source EventSource) error
 
 	GetById(id int64, userId uint) (*Transaction, error)
 	GetManyById(id []int64, userId uint) ([]*Transaction, error)
@@ -22,6 +25,12 @@ type ITransactionRepository interface {
 	GetAll(userId uint, timestamp time.Time, limit int) ([]*Transaction, error)
 	GetManyByCategory(userId uint, category string, timestamp time.Time, limit int) ([]*Transaction, error)
 	GetManyByCurrency(userId uint, currency string, fromTime time.Time, limit int) ([]*Transaction, error)
+
+	Count() (int64, error)
+	// Number of transactions of each user, by user ID.
+	CountByUser() (map[uint]int64, error)
+	// Number of users with transactions between from (included) and to (excluded).
+	CountActiveUsers(from time.Time, to time.Time) (int64, error)
 }
 
 // Factory method to initialise a repository.
@@ -29,25 +38,58 @@ func TransactionRepositoryImpl(dbClient *gorm.DB) ITransactionRepository {
 	return &transactionRepository{dbClient: dbClient}
 }
 
-func (r *transactionRepository) Create(txs []*Transaction) ([]*Transaction, error) {
-	result := r.dbClient.Create(&txs)
-	if result.Error != nil {
-		return nil, result.Error
+func (r *transactionRepository) Create(txs []*Transaction, source EventSource) ([]*Transaction, error) {
+	err := r.dbClient.Transaction(func(db *gorm.DB) error {
+		// Accounts, goals and members are attached for display only, they are saved through their IDs.
+		if err := db.Omit("Account", "Goal", "Splits.User").Create(&txs).Error; err != nil {
+			return err
+		}
+		return recordEvents(db, ActionCreate, source, nil, txs)
+	})
+	if err != nil {
+		return nil, err
 	}
 	return txs, nil
 }
 
-func (r *transactionRepository) Delete(txs []*Transaction) error {
-	result := r.dbClient.Delete(&txs)
-	if result.Error != nil || result.RowsAffected == 0 {
-		return result.Error
-	}
-	return nil
+func (r *transactionRepository) Update(tx *Transaction, source EventSource) error {
+	return r.dbClient.Transaction(func(db *gorm.DB) error {
+		var old Transaction
+		if err := db.Where("id = ? and user_id = ?", tx.ID, tx.UserID).First(&old).Error; err != nil {
+			return err
+		}
+		// Associations are loaded for display only, changes go through their IDs.
+		if err := db.Omit(clause.Associations).Save(tx).Error; err != nil {
+			return err
+		}
+		return recordEvents(db, ActionEdit, source, []*Transaction{&old}, []*Transaction{tx})
+	})
+}
+
+func (r *transactionRepository) Delete(txs []*Transaction, source EventSource) error {
+	return r.dbClient.Transaction(func(db *gorm.DB) error {
+		ids := make([]uint, 0, len(txs))
+		for _, tx := range txs {
+			ids = append(ids, tx.ID)
+		}
+		if err := db.Where("transaction_id IN ?", ids).Delete(&Split{}).Error; err != nil {
+			return err
+		}
+		if err := db.Where("transaction_id IN ?", ids).Delete(&Attachment{}).Error; err != nil {
+			return err
+		}
+
+		result := db.Delete(&txs)
+		if result.Error != nil || result.RowsAffected == 0 {
+			return result.Error
+		}
+		return recordEvents(db, ActionDelete, source, txs, nil)
+	})
 }
 
 func (r *transactionRepository) GetById(id int64, userId uint) (*Transaction, error) {
 	var transaction Transaction
-	result := r.dbClient.Where("id = ? and user_id = ?", id, userId).First(&transaction)
+	result := r.dbClient.Preload("Account").Preload("Goal").Where("id = ? and user_id = ?", id, userId).First(&transaction)
 	if result.Error != nil {
 		return nil, result.Error
 	}
@@ -77,6 +119,8 @@ func (r *transactionRepository) GetAll(userId uint, fromTime time.Time, limit in
 	var transactions []*Transaction
 
 	result := r.dbClient.
+		Preload("Account").
+		Preload("Goal").
 		Where("user_id = ? and timestamp >= ? and timestamp < ?", userId, fromTime, time.Now()).
 		Order("timestamp DESC, id DESC").
 		Limit(limit).
@@ -94,6 +138,8 @@ func (r *transactionRepository) GetManyByCategory(userId uint, category string,
 	var transactions []*Transaction
 
 	result := r.dbClient.
+		Preload("Account").
+		Preload("Goal").
 		Where("category = ? and user_id = ? and timestamp >= ? and timestamp < ?", category, userId, fromTime, time.Now()).
 		Order("timestamp DESC, id DESC").
 		Limit(limit).
@@ -111,6 +157,8 @@ func (r *transactionRepository) GetManyByCurrency(userId uint, currency string,
 	var transactions []*Transaction
 
 	result := r.dbClient.
+		Preload("Account").
+		Preload("Goal").
 		Where("currency = ? and user_id = ? and timestamp >= ? and timestamp < ?", currency, userId, fromTime, time.Now()).
 		Order("timestamp DESC, id DESC").
 		Limit(limit).
@@ -122,3 +170,38 @@ func (r *transactionRepository) GetManyByCurrency(userId uint, currency string,
 
 	return transactions, nil
 }
+
+func (r *transactionRepository) Count() (int64, error) {
+	var count int64
+	err := r.dbClient.Model(&Transaction{}).Count(&count).Error
+	return count, err
+}
+
+func (r *transactionRepository) CountByUser() (map[uint]int64, error) {
+	var rows []struct {
+		UserID uint
+		Count  int64
+	}
+	err := r.dbClient.Model(&Transaction{}).
+		Select("user_id, count(*) as count").
+		Group("user_id").
+		Scan(&rows).Error
+	if err != nil {
+		return nil, err
+	}
+
+	counts := make(map[uint]int64, len(rows))
+	for _, row := range rows {
+		counts[row.UserID] = row.Count
+	}
+	return counts, nil
+}
+
+func (r *transactionRepository) CountActiveUsers(from time.Time, to time.Time) (int64, error) {
+	var count int64
+	err := r.dbClient.Model(&Transaction{}).
+		Where("timestamp >= ? and timestamp < ?", from, to).
+		Distinct("user_id").
+		Count(&count).Error
+	return count, err
+}
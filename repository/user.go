@@ -1,8 +1,9 @@
 package repository
 
 import (
-	"log"
+	"log/slog"
 	. "remind0/db"
+	"time"
 
 	telegramClient "github.com/go-telegram-bot-api/telegram-bot-api/v5"
 	"gorm.io/gorm"
@@ -15,10 +16,23 @@ type userRepository struct {
 type IUserRepository interface {
 	// Get the existing user or create a new one if it doesn't exist.
 	GetOrCreate(userId int64, sender *telegramClient.User) (*User, error)
+	// Get user by Telegram ID, without creating it.
+	GetByTelegramID(userId int64) (*User, error)
 	// Get user by internal ID
 	GetByID(id uint) (*User, error)
-	// Update user
-	Update(user *User) error
+	// Get user by Telegram username, without the @.
+	GetByUsername(username string) (*User, error)
+	// Every user, oldest first.
+	GetAll() ([]*User, error)
+	Count() (int64, error)
+	SetBlocked(id uint, blocked bool) error
+	// Users who asked for logging reminders and aren't blocked.
+	GetWithReminders() ([]*User, error)
+	// Record when a user was nudged, leaving the rest of the user untouched.
+	SetNudgedAt(id uint, at time.Time) error
+	// Update the given columns of a user, leaving the rest untouched (e.g. when
+	// they were last nudged, which may have changed since the user was read).
+	Update(user *User, columns ...string) error
 }
 
 // Factory method to initialise a repository.
@@ -40,7 +54,7 @@ func (r *userRepository) GetOrCreate(userId int64, sender *telegramClient.User)
 			if err := r.dbClient.Create(&user).Error; err != nil {
 				return nil, err
 			}
-			log.Printf("✅ Created new user: %s (%d)", user.FirstName+" "+user.LastName, user.UserID)
+			slog.Info("Created user", "id", user.ID, "tg_user_id", user.UserID, "name", user.FirstName+" "+user.LastName)
 		} else {
 			return nil, result.Error
 		}
@@ -48,12 +62,58 @@ func (r *userRepository) GetOrCreate(userId int64, sender *telegramClient.User)
 	return &user, nil
 }
 
+func (r *userRepository) GetByTelegramID(userId int64) (*User, error) {
+	var user User
+	err := r.dbClient.Where("user_id = ?", userId).First(&user).Error
+	return &user, err
+}
+
 func (r *userRepository) GetByID(id uint) (*User, error) {
 	var user User
 	err := r.dbClient.Where("id = ?", id).First(&user).Error
 	return &user, err
 }
 
-func (r *userRepository) Update(user *User) error {
-	return r.dbClient.Save(user).Error
+func (r *userRepository) Update(user *User, columns ...string) error {
+	return r.dbClient.Model(user).Select(columns).Updates(user).Error
+}
+
+func (r *userRepository) GetByUsername(username string) (*User, error) {
+	var user User
+	err := r.dbClient.Where("lower(username) = lower(?)", username).First(&user).Error
+	return &user, err
+}
+
+func (r *userRepository) GetAll() ([]*User, error) {
+	var users []*User
+	if err := r.dbClient.Order("id ASC").Find(&users).Error; err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (r *userRepository) Count() (int64, error) {
+	var count int64
+	err := r.dbClient.Model(&User{}).Count(&count).Error
+	return count, err
+}
+
+func (r *userRepository) SetBlocked(id uint, blocked bool) error {
+	return r.dbClient.Model(&User{}).Where("id = ?", id).Update("blocked", blocked).Error
+}
+
+func (r *userRepository) GetWithReminders() ([]*User, error) {
+	var users []*User
+	err := r.dbClient.
+		Where("reminders = ? and blocked = ?", true, false).
+		Order("id ASC").
+		Find(&users).Error
+	if err != nil {
+		return nil, err
+	}
+	return users, nil
+}
+
+func (r *userRepository) SetNudgedAt(id uint, at time.Time) error {
+	return r.dbClient.Model(&User{}).Where("id = ?", id).Update("last_nudged_at", at).Error
 }